@@ -1,20 +1,25 @@
 package handlers
 
 import (
+	"errors"
 	"net/http"
 	"strconv"
 	"strings"
 
 	"github.com/gin-gonic/gin"
 	"github.com/google/uuid"
-	"github.com/terkoizmy/go-blog-api/internal/db"
+	"github.com/terkoizmy/go-blog-api/internal/auth"
 	"github.com/terkoizmy/go-blog-api/internal/models"
+	"github.com/terkoizmy/go-blog-api/internal/repository"
 )
 
-type CategoryHandler struct{}
+type CategoryHandler struct {
+	categories repository.CategoryRepository
+	posts      repository.PostRepository
+}
 
-func NewCategoryHandler() *CategoryHandler {
-	return &CategoryHandler{}
+func NewCategoryHandler(categories repository.CategoryRepository, posts repository.PostRepository) *CategoryHandler {
+	return &CategoryHandler{categories: categories, posts: posts}
 }
 
 func generateCategorySlug(title string) string {
@@ -51,15 +56,7 @@ func generateCategorySlug(title string) string {
 // @Failure 500 {object} map[string]string
 // @Router /categories [post]
 func (h *CategoryHandler) CreateCategory(c *gin.Context) {
-	// Only admins can create categories
-	role, exists := c.Get("role")
-	if !exists {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "unauthorized"})
-		return
-	}
-
-	roleStr, ok := role.(string)
-	if !ok || roleStr != "admin" {
+	if !auth.Can(c, auth.PermCategoriesManage) {
 		c.JSON(http.StatusForbidden, gin.H{"error": "permission denied"})
 		return
 	}
@@ -79,8 +76,7 @@ func (h *CategoryHandler) CreateCategory(c *gin.Context) {
 	}
 
 	// Check if slug already exists
-	var existingCategory models.Category
-	if result := db.DB.Where("slug = ?", slug).First(&existingCategory); result.RowsAffected > 0 {
+	if exists, _ := h.categories.SlugExists(slug, uuid.Nil); exists {
 		// Add a unique identifier to the slug
 		slug = slug + "-" + uuid.New().String()[:8]
 	}
@@ -91,7 +87,11 @@ func (h *CategoryHandler) CreateCategory(c *gin.Context) {
 		Slug: slug,
 	}
 
-	if result := db.DB.Create(&category); result.Error != nil {
+	if !h.setParent(c, &category, req.ParentID.Value) {
+		return
+	}
+
+	if err := h.categories.Create(&category); err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to create category"})
 		return
 	}
@@ -104,17 +104,42 @@ func (h *CategoryHandler) CreateCategory(c *gin.Context) {
 // @Tags categories
 // @Accept json
 // @Produce json
-// @Success 200 {array} models.Category
+// @Param page query int false "Page number"
+// @Param limit query int false "Items per page"
+// @Success 200 {object} models.PaginatedResponse{data=[]models.Category}
+// @Failure 400 {object} map[string]string
 // @Failure 500 {object} map[string]string
 // @Router /categories [get]
 func (h *CategoryHandler) GetAllCategories(c *gin.Context) {
-	var categories []models.Category
-	if result := db.DB.Find(&categories); result.Error != nil {
+	p, ok := parsePagination(c)
+	if !ok {
+		return
+	}
+
+	categories, total, err := h.categories.List(p.Repository())
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to get categories"})
+		return
+	}
+
+	writePage(c, categories, p, total)
+}
+
+// @Summary Get category tree
+// @Description Get all categories nested under their parents, each level ordered by name
+// @Tags categories
+// @Produce json
+// @Success 200 {array} models.CategoryNode
+// @Failure 500 {object} map[string]string
+// @Router /categories/tree [get]
+func (h *CategoryHandler) GetCategoryTree(c *gin.Context) {
+	tree, err := h.categories.Tree()
+	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to get categories"})
 		return
 	}
 
-	c.JSON(http.StatusOK, categories)
+	c.JSON(http.StatusOK, tree)
 }
 
 // @Summary Get category by ID
@@ -137,8 +162,8 @@ func (h *CategoryHandler) GetCategoryByID(c *gin.Context) {
 		return
 	}
 
-	var category models.Category
-	if result := db.DB.Where("id = ?", categoryUUID).First(&category); result.Error != nil {
+	category, err := h.categories.FindByID(categoryUUID)
+	if err != nil {
 		c.JSON(http.StatusNotFound, gin.H{"error": "category not found"})
 		return
 	}
@@ -159,8 +184,8 @@ func (h *CategoryHandler) GetCategoryByID(c *gin.Context) {
 func (h *CategoryHandler) GetCategoryBySlug(c *gin.Context) {
 	slug := c.Param("slug")
 
-	var category models.Category
-	if result := db.DB.Where("slug = ?", slug).First(&category); result.Error != nil {
+	category, err := h.categories.FindBySlug(slug)
+	if err != nil {
 		c.JSON(http.StatusNotFound, gin.H{"error": "category not found"})
 		return
 	}
@@ -176,11 +201,20 @@ func (h *CategoryHandler) GetCategoryBySlug(c *gin.Context) {
 // @Param id path string true "Category ID"
 // @Param page query int false "Page number"
 // @Param limit query int false "Items per page"
-// @Success 200 {array} models.Post
+// @Param cursor query string false "Cursor from next_cursor or prev_cursor, empty for the first page. Switches to cursor pagination ordered by publication date."
+// @Param content query string false "excerpt (default) leaves out the content of each post, full includes it"
+// @Param descendants query bool false "Also include posts of subcategories, at any depth"
+// @Success 200 {object} models.PaginatedResponse{data=[]models.Post} "models.CursorResponse when cursor is given"
+// @Failure 400 {object} map[string]string
 // @Failure 404 {object} map[string]string
 // @Failure 500 {object} map[string]string
 // @Router /categories/{id}/posts [get]
 func (h *CategoryHandler) GetPostsByCategory(c *gin.Context) {
+	full, ok := parseListContent(c)
+	if !ok {
+		return
+	}
+
 	id := c.Param("id")
 
 	// Parse the UUID
@@ -191,36 +225,72 @@ func (h *CategoryHandler) GetPostsByCategory(c *gin.Context) {
 	}
 
 	// Check if category exists
-
-	var category models.Category
-	if result := db.DB.Where("id = ?", categoryUUID).First(&category); result.Error != nil {
+	if _, err := h.categories.FindByID(categoryUUID); err != nil {
 		c.JSON(http.StatusNotFound, gin.H{"error": "category not found"})
 		return
 	}
 
-	// Pagination
-	page := 1
-	if pageQuery := c.Query("page"); pageQuery != "" {
-		if val, err := strconv.Atoi(pageQuery); err == nil && val > 0 {
-			page = val
+	// Posts of subcategories are included when asked for
+	filter := repository.PostFilter{Status: "published"}
+	if value := c.Query("descendants"); value != "" {
+		descendants, err := strconv.ParseBool(value)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "descendants must be true or false"})
+			return
+		}
+		if descendants {
+			ids, err := h.categories.Descendants(categoryUUID)
+			if err != nil {
+				c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to get posts"})
+				return
+			}
+			filter.CategoryIDs = append([]uuid.UUID{categoryUUID}, ids...)
 		}
 	}
 
-	limit := 10
-	if limitQuery := c.Query("limit"); limitQuery != "" {
-		if val, err := strconv.Atoi(limitQuery); err == nil && val > 0 {
-			limit = val
+	if usesCursor(c) {
+		page, ok := parseCursorPage(c)
+		if !ok {
+			return
+		}
+
+		var posts []models.Post
+		var cursors repository.Cursors
+		if len(filter.CategoryIDs) > 0 {
+			posts, cursors, err = h.posts.Feed(filter, page)
+		} else {
+			posts, cursors, err = h.posts.FeedByCategory(categoryUUID, filter, page)
 		}
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to get posts"})
+			return
+		}
+
+		for i := range posts {
+			posts[i].Author.Password = ""
+		}
+
+		trimContent(posts, full)
+		writeCursorPage(c, posts, page, cursors)
+		return
 	}
 
-	offset := (page - 1) * limit
+	p, ok := parsePagination(c)
+	if !ok {
+		return
+	}
 
 	// Get posts by category with pagination
+	filter.Offset = p.Offset()
+	filter.Limit = p.Limit
 	var posts []models.Post
-	if result := db.DB.Joins("JOIN post_categories ON posts.id = post_categories.post_id").
-		Where("post_categories.category_id = ? AND posts.status = ?", categoryUUID, "published").
-		Offset(offset).Limit(limit).Preload("Author").Preload("Categories").
-		Find(&posts); result.Error != nil {
+	var total int64
+	if len(filter.CategoryIDs) > 0 {
+		posts, total, err = h.posts.List(filter)
+	} else {
+		posts, total, err = h.posts.ListByCategory(categoryUUID, filter)
+	}
+	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to get posts"})
 		return
 	}
@@ -230,7 +300,8 @@ func (h *CategoryHandler) GetPostsByCategory(c *gin.Context) {
 		posts[i].Author.Password = ""
 	}
 
-	c.JSON(http.StatusOK, posts)
+	trimContent(posts, full)
+	writePage(c, posts, p, total)
 
 }
 
@@ -249,15 +320,7 @@ func (h *CategoryHandler) GetPostsByCategory(c *gin.Context) {
 // @Failure 500 {object} map[string]string
 // @Router /categories/{id} [put]
 func (h *CategoryHandler) UpdateCategory(c *gin.Context) {
-	// Only admins can update categories
-	role, exists := c.Get("role")
-	if !exists {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "unauthorized"})
-		return
-	}
-
-	roleStr, ok := role.(string)
-	if !ok || roleStr != "admin" {
+	if !auth.Can(c, auth.PermCategoriesManage) {
 		c.JSON(http.StatusForbidden, gin.H{"error": "permission denied"})
 		return
 	}
@@ -271,8 +334,8 @@ func (h *CategoryHandler) UpdateCategory(c *gin.Context) {
 		return
 	}
 
-	var category models.Category
-	if result := db.DB.Where("id = ?", categoryUUID).First(&category); result.Error != nil {
+	category, err := h.categories.FindByID(categoryUUID)
+	if err != nil {
 		c.JSON(http.StatusNotFound, gin.H{"error": "category not found"})
 		return
 	}
@@ -296,14 +359,18 @@ func (h *CategoryHandler) UpdateCategory(c *gin.Context) {
 	}
 
 	// Check if slug already exists
-	var existingCategory models.Category
-	if result := db.DB.Where("slug = ? AND id != ?", category.Slug, categoryUUID).First(&existingCategory); result.RowsAffected > 0 {
+	if exists, _ := h.categories.SlugExists(category.Slug, categoryUUID); exists {
 		// Add a unique identifier to the slug
 		category.Slug = category.Slug + "-" + uuid.New().String()[:8]
 	}
 
+	// Without parent_id the category stays where it is
+	if req.ParentID.Set && !h.setParent(c, &category, req.ParentID.Value) {
+		return
+	}
+
 	// Save the category
-	if result := db.DB.Save(&category); result.Error != nil {
+	if err := h.categories.Update(&category); err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to update category"})
 		return
 	}
@@ -325,15 +392,7 @@ func (h *CategoryHandler) UpdateCategory(c *gin.Context) {
 // @Failure 500 {object} map[string]string
 // @Router /categories/{id} [delete]
 func (h *CategoryHandler) DeleteCategory(c *gin.Context) {
-	// Only admins can delete categories
-	role, exists := c.Get("role")
-	if !exists {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "unauthorized"})
-		return
-	}
-
-	roleStr, ok := role.(string)
-	if !ok || roleStr != "admin" {
+	if !auth.Can(c, auth.PermCategoriesManage) {
 		c.JSON(http.StatusForbidden, gin.H{"error": "permission denied"})
 		return
 	}
@@ -347,20 +406,58 @@ func (h *CategoryHandler) DeleteCategory(c *gin.Context) {
 		return
 	}
 
-	var category models.Category
-	if result := db.DB.Where("id = ?", categoryUUID).First(&category); result.Error != nil {
+	category, err := h.categories.FindByID(categoryUUID)
+	if err != nil {
 		c.JSON(http.StatusNotFound, gin.H{"error": "category not found"})
 		return
 	}
 
-	// Remove category associations from posts first
-	db.DB.Model(&category).Association("Posts").Clear()
-
-	// Delete the category
-	if result := db.DB.Delete(&category); result.Error != nil {
+	// Delete the category along with its post associations
+	if err := h.categories.Delete(&category); err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to delete category"})
 		return
 	}
 
 	c.JSON(http.StatusOK, gin.H{"message": "category deleted successfully"})
 }
+
+// setParent nests the category under parentID, or makes it top level if
+// nil. A category can't go under itself or its own subcategories. Invalid
+// parents get a 400 response and false.
+func (h *CategoryHandler) setParent(c *gin.Context, category *models.Category, parentID *uuid.UUID) bool {
+	if parentID == nil {
+		category.ParentID = nil
+		return true
+	}
+
+	if *parentID == category.ID {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "a category can't be its own parent"})
+		return false
+	}
+
+	if _, err := h.categories.FindByID(*parentID); errors.Is(err, repository.ErrNotFound) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "parent category not found"})
+		return false
+	} else if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to get parent category"})
+		return false
+	}
+
+	// New categories have no subcategories yet
+	if category.ID != uuid.Nil {
+		descendants, err := h.categories.Descendants(category.ID)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to get subcategories"})
+			return false
+		}
+		for _, id := range descendants {
+			if id == *parentID {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "a category can't go under its own subcategory"})
+				return false
+			}
+		}
+	}
+
+	category.ParentID = parentID
+	return true
+}
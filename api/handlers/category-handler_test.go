@@ -0,0 +1,59 @@
+package handlers_test
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/terkoizmy/go-blog-api/internal/auth"
+	"github.com/terkoizmy/go-blog-api/internal/db"
+	"github.com/terkoizmy/go-blog-api/internal/models"
+)
+
+func TestUpdateCategoryParent(t *testing.T) {
+	api := newTestAPI(t)
+	editor := api.createUser(t, "editor", auth.RoleEditor)
+
+	create := func(body interface{}) models.Category {
+		t.Helper()
+		res := api.do(t, http.MethodPost, "/api/v1/categories", editor.token, body)
+		if res.Code != http.StatusCreated {
+			t.Fatalf("create category: got %d %s", res.Code, res.Body)
+		}
+		var category models.Category
+		decode(t, res, &category)
+		return category
+	}
+	update := func(id uuid.UUID, body interface{}) models.Category {
+		t.Helper()
+		res := api.do(t, http.MethodPut, "/api/v1/categories/"+id.String(), editor.token, body)
+		if res.Code != http.StatusOK {
+			t.Fatalf("update category: got %d %s", res.Code, res.Body)
+		}
+		var category models.Category
+		decode(t, res, &category)
+		return category
+	}
+
+	parent := create(map[string]interface{}{"name": "Languages"})
+	child := create(map[string]interface{}{"name": "Go", "parent_id": parent.ID})
+	if child.ParentID == nil || *child.ParentID != parent.ID {
+		t.Fatalf("created under %v, want %s", child.ParentID, parent.ID)
+	}
+
+	child = update(child.ID, map[string]interface{}{"name": "Golang"})
+	if child.ParentID == nil || *child.ParentID != parent.ID {
+		t.Errorf("update without parent_id moved the category under %v", child.ParentID)
+	}
+
+	child = update(child.ID, map[string]interface{}{"name": "Golang", "parent_id": nil})
+	if child.ParentID != nil {
+		t.Errorf("update with a null parent_id left the category under %s", child.ParentID)
+	}
+
+	unknown := uuid.New()
+	orphan := models.Category{Name: "Orphan", Slug: "orphan", ParentID: &unknown}
+	if err := db.DB.Create(&orphan).Error; err == nil {
+		t.Error("saved a category under a parent that doesn't exist")
+	}
+}
@@ -5,16 +5,20 @@ import (
 
 	"github.com/gin-gonic/gin"
 	"github.com/google/uuid"
-	"github.com/terkoizmy/go-blog-api/internal/db"
+	"github.com/terkoizmy/go-blog-api/internal/auth"
 	"github.com/terkoizmy/go-blog-api/internal/models"
+	"github.com/terkoizmy/go-blog-api/internal/repository"
 )
 
 // CommentHandler handles comment-related routes
-type CommentHandler struct{}
+type CommentHandler struct {
+	comments repository.CommentRepository
+	posts    repository.PostRepository
+}
 
-// NewCommentHandler creates a new CommentHandlerz
-func NewCommentHandler() *CommentHandler {
-	return &CommentHandler{}
+// NewCommentHandler creates a new CommentHandler
+func NewCommentHandler(comments repository.CommentRepository, posts repository.PostRepository) *CommentHandler {
+	return &CommentHandler{comments: comments, posts: posts}
 }
 
 // @Summary Create a new comment
@@ -41,8 +45,8 @@ func (h *CommentHandler) CreateComment(c *gin.Context) {
 	}
 
 	// Check if post exists and is published
-	var post models.Post
-	if result := db.DB.Where("id = ?", postUUID).First(&post); result.Error != nil {
+	post, err := h.posts.FindByID(postUUID)
+	if err != nil {
 		c.JSON(http.StatusNotFound, gin.H{"error": "post not found"})
 		return
 	}
@@ -82,8 +86,8 @@ func (h *CommentHandler) CreateComment(c *gin.Context) {
 
 	// If ParentID is provided, check if parent comment exists
 	if req.ParentID != nil {
-		var parentComment models.Comment
-		if result := db.DB.Where("id = ?", *req.ParentID).First(&parentComment); result.Error != nil {
+		parentComment, err := h.comments.FindByID(*req.ParentID)
+		if err != nil {
 			c.JSON(http.StatusBadRequest, gin.H{"error": "parent comment not found"})
 			return
 		}
@@ -98,7 +102,7 @@ func (h *CommentHandler) CreateComment(c *gin.Context) {
 	// Don't return the password
 	// comment.Author.Password = ""
 
-	if err := db.DB.Preload("Author").Preload("Parent").Create(&comment).Error; err != nil {
+	if err := h.comments.Create(&comment); err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
@@ -113,7 +117,11 @@ func (h *CommentHandler) CreateComment(c *gin.Context) {
 // @Accept json
 // @Produce json
 // @Param postId path string true "Post Id"
-// @Success 200 {object} models.Comment
+// @Param page query int false "Page number"
+// @Param limit query int false "Items per page"
+// @Param cursor query string false "Cursor from next_cursor or prev_cursor, empty for the first page. Switches to cursor pagination ordered by creation date."
+// @Success 200 {object} models.PaginatedResponse{data=[]models.Comment} "models.CursorResponse when cursor is given"
+// @Failure 400 {object} map[string]string
 // @Failure 404 {object} map[string]string
 // @Failure 500 {object} map[string]string
 // @Router /comment/posts/{postId} [get]
@@ -127,13 +135,34 @@ func (h *CommentHandler) GetAllCommentsFromPostId(c *gin.Context) {
 		return
 	}
 
-	var comments []models.Comment
-	if result := db.DB.Preload("Author").Preload("Parent").Where("post_id = ?", postUUID).Find(&comments); result.Error != nil {
+	if usesCursor(c) {
+		page, ok := parseCursorPage(c)
+		if !ok {
+			return
+		}
+
+		comments, cursors, err := h.comments.FeedByPost(postUUID, page)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to get comments"})
+			return
+		}
+
+		writeCursorPage(c, comments, page, cursors)
+		return
+	}
+
+	p, ok := parsePagination(c)
+	if !ok {
+		return
+	}
+
+	comments, total, err := h.comments.ListByPost(postUUID, p.Repository())
+	if err != nil {
 		c.JSON(http.StatusNotFound, gin.H{"error": "post not found"})
 		return
 	}
 
-	c.JSON(http.StatusOK, comments)
+	writePage(c, comments, p, total)
 }
 
 // @Summary Get comment by comment ID
@@ -156,8 +185,8 @@ func (h *CommentHandler) GetCommentById(c *gin.Context) {
 		return
 	}
 
-	var comment models.Comment
-	if result := db.DB.Preload("Author").Preload("Parent").Preload("Replies").Where("id = ?", commentUUID).First(&comment); result.Error != nil {
+	comment, err := h.comments.FindByIDWithReplies(commentUUID)
+	if err != nil {
 		c.JSON(http.StatusNotFound, gin.H{"error": "comment not found"})
 		return
 	}
@@ -190,25 +219,19 @@ func (h *CommentHandler) UpdateComment(c *gin.Context) {
 		return
 	}
 
-	var comment models.Comment
-	if result := db.DB.Preload("Author").Preload("Parent").Where("id = ?", commentUUID).First(&comment); result.Error != nil {
+	comment, err := h.comments.FindByID(commentUUID)
+	if err != nil {
 		c.JSON(http.StatusNotFound, gin.H{"error": "Comment not found"})
 		return
 	}
 
-	// Check if user is the author or admin
-	userID, exists := c.Get("userID")
-	userRole, roleExists := c.Get("role")
-
-	if !exists || !roleExists {
+	// Check if user is the author or can moderate comments
+	if _, exists := c.Get("userID"); !exists {
 		c.JSON(http.StatusUnauthorized, gin.H{"error": "unauthorized"})
 		return
 	}
 
-	authorID, idOk := userID.(uuid.UUID)
-	roleStr, roleOk := userRole.(string)
-
-	if !idOk || !roleOk || (comment.AuthorID != authorID && roleStr != "admin") {
+	if !auth.CanModify(c, comment.AuthorID, auth.PermCommentsModerate) {
 		c.JSON(http.StatusForbidden, gin.H{"error": "permission denied"})
 		return
 	}
@@ -225,13 +248,15 @@ func (h *CommentHandler) UpdateComment(c *gin.Context) {
 	}
 
 	// Save the comment
-	if result := db.DB.Save(&comment); result.Error != nil {
+	if err := h.comments.Update(&comment); err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to update comment"})
 		return
 	}
 
 	// Load updated comment with associations
-	db.DB.Preload("Author").Preload("Replies").Preload("Parent").Where("id = ?", commentUUID).First(&comment)
+	if updated, err := h.comments.FindByIDWithReplies(commentUUID); err == nil {
+		comment = updated
+	}
 
 	// Clean up sensitive information
 	comment.Author.Password = ""
@@ -263,31 +288,25 @@ func (h *CommentHandler) DeleteComment(c *gin.Context) {
 		return
 	}
 
-	var comment models.Comment
-	if result := db.DB.Preload("Author").Preload("Parent").Where("id = ?", commentUUID).First(&comment); result.Error != nil {
+	comment, err := h.comments.FindByID(commentUUID)
+	if err != nil {
 		c.JSON(http.StatusNotFound, gin.H{"error": "Comment not found"})
 		return
 	}
 
-	// Check if user is the author or admin
-	userID, exists := c.Get("userID")
-	userRole, roleExists := c.Get("role")
-
-	if !exists || !roleExists {
+	// Check if user is the author or can moderate comments
+	if _, exists := c.Get("userID"); !exists {
 		c.JSON(http.StatusUnauthorized, gin.H{"error": "unauthorized"})
 		return
 	}
 
-	authorID, idOk := userID.(uuid.UUID)
-	roleStr, roleOk := userRole.(string)
-
-	if !idOk || !roleOk || (comment.AuthorID != authorID && roleStr != "admin") {
+	if !auth.CanModify(c, comment.AuthorID, auth.PermCommentsModerate) {
 		c.JSON(http.StatusForbidden, gin.H{"error": "permission denied"})
 		return
 	}
 
 	// Delete the comment
-	if result := db.DB.Delete(&comment); result.Error != nil {
+	if err := h.comments.Delete(&comment); err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to delete comment"})
 		return
 	}
@@ -0,0 +1,73 @@
+package handlers
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/terkoizmy/go-blog-api/internal/repository"
+)
+
+// resolveCategory accepts a category ID or slug
+func resolveCategory(categories repository.CategoryRepository, value string) (uuid.UUID, error) {
+	if id, err := uuid.Parse(value); err == nil {
+		category, err := categories.FindByID(id)
+		return category.ID, err
+	}
+
+	category, err := categories.FindBySlug(value)
+	return category.ID, err
+}
+
+// resolveAuthor accepts a user ID or username
+func resolveAuthor(users repository.UserRepository, value string) (uuid.UUID, error) {
+	if id, err := uuid.Parse(value); err == nil {
+		user, err := users.FindByID(id)
+		return user.ID, err
+	}
+
+	user, err := users.FindByUsername(value)
+	return user.ID, err
+}
+
+// parseDateRange reads the from and to query parameters. Both take a date or
+// an RFC 3339 time; from is inclusive and the returned to is exclusive, so a
+// date includes the whole day. Invalid values get a 400 response and false.
+func parseDateRange(c *gin.Context) (from, to *time.Time, ok bool) {
+	if value := c.Query("from"); value != "" {
+		t, _, err := parseDate(value)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "from must be a date (YYYY-MM-DD) or RFC 3339 time"})
+			return nil, nil, false
+		}
+		from = &t
+	}
+
+	if value := c.Query("to"); value != "" {
+		t, dateOnly, err := parseDate(value)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "to must be a date (YYYY-MM-DD) or RFC 3339 time"})
+			return nil, nil, false
+		}
+		if dateOnly {
+			t = t.AddDate(0, 0, 1)
+		} else {
+			t = t.Add(time.Nanosecond)
+		}
+		to = &t
+	}
+
+	return from, to, true
+}
+
+// parseDate accepts a date or an RFC 3339 time and reports whether it was a
+// date
+func parseDate(value string) (time.Time, bool, error) {
+	if t, err := time.Parse(time.DateOnly, value); err == nil {
+		return t, true, nil
+	}
+
+	t, err := time.Parse(time.RFC3339, value)
+	return t, false, err
+}
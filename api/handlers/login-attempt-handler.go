@@ -0,0 +1,86 @@
+package handlers
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/terkoizmy/go-blog-api/internal/auth"
+)
+
+// @Summary List login attempts
+// @Description List recent failed login attempts on the current user's account. Pass all=true to include successful logins.
+// @Tags users
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param all query bool false "Include successful logins"
+// @Param limit query int false "Maximum number of attempts (default 50, max 200)"
+// @Success 200 {array} models.LoginAttempt
+// @Failure 401 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Router /users/me/login-attempts [get]
+func (h *UserHandler) GetLoginAttempts(c *gin.Context) {
+	userID, exists := c.Get("userID")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "unauthorized"})
+		return
+	}
+
+	id, ok := userID.(uuid.UUID)
+	if !ok {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "invalid user ID format"})
+		return
+	}
+
+	limit := 50
+	if limitQuery := c.Query("limit"); limitQuery != "" {
+		if val, err := strconv.Atoi(limitQuery); err == nil && val > 0 && val <= 200 {
+			limit = val
+		}
+	}
+
+	attempts, err := auth.ListLoginAttempts(id, c.Query("all") != "true", limit)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to get login attempts"})
+		return
+	}
+
+	c.JSON(http.StatusOK, attempts)
+}
+
+// @Summary Unlock user
+// @Description Lift a login lockout on a user's account and reset its failed attempt counter
+// @Tags users
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param id path string true "User ID"
+// @Success 200 {object} map[string]string
+// @Failure 400 {object} map[string]string
+// @Failure 401 {object} map[string]string
+// @Failure 403 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Router /users/{id}/unlock [post]
+func (h *UserHandler) UnlockUser(c *gin.Context) {
+	userUUID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid user ID format"})
+		return
+	}
+
+	user, err := h.users.FindByID(userUUID)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "user not found"})
+		return
+	}
+
+	if err := auth.UnlockAccount(user.ID); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to unlock user"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "user unlocked successfully"})
+}
@@ -0,0 +1,125 @@
+package handlers_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/terkoizmy/go-blog-api/api/routes"
+	"github.com/terkoizmy/go-blog-api/config"
+	"github.com/terkoizmy/go-blog-api/internal/auth"
+	"github.com/terkoizmy/go-blog-api/internal/dbtest"
+	"github.com/terkoizmy/go-blog-api/internal/models"
+	"github.com/terkoizmy/go-blog-api/internal/repository"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// testPassword is the password of every account made by createUser
+const testPassword = "secret123"
+
+// testAPI is the full router on a fresh in-memory database
+type testAPI struct {
+	router *gin.Engine
+	repos  *repository.Repositories
+}
+
+// testUser is an account with an access token
+type testUser struct {
+	user  models.User
+	token string
+}
+
+func newTestAPI(t *testing.T) *testAPI {
+	t.Helper()
+	gin.SetMode(gin.TestMode)
+
+	conn := dbtest.Open(t)
+	if err := auth.SeedRoles(); err != nil {
+		t.Fatalf("seed roles: %v", err)
+	}
+	if err := auth.InitKeys(config.Config{JWTSecret: "test-secret", JWTSigningMethod: "HS256"}); err != nil {
+		t.Fatalf("init keys: %v", err)
+	}
+
+	repos := repository.NewGormRepositories(conn)
+	router := gin.New()
+	routes.SetupUserRoutes(router, repos)
+	routes.SetupPostRoutes(router, repos)
+	routes.SetupCategoryRoutes(router, repos)
+	routes.SetupTagRoutes(router, repos)
+	routes.SetupCommentRoutes(router, repos)
+	routes.SetupSearchRoutes(router, repos)
+	routes.SetupRoleRoutes(router)
+
+	return &testAPI{router: router, repos: repos}
+}
+
+// createUser adds a verified account with testPassword and the given role
+func (api *testAPI) createUser(t *testing.T, username, role string) testUser {
+	t.Helper()
+
+	// The lowest cost keeps tests fast; logins accept any cost
+	hash, err := bcrypt.GenerateFromPassword([]byte(testPassword), bcrypt.MinCost)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	now := time.Now()
+	user := models.User{
+		Username:        username,
+		Email:           username + "@example.com",
+		Password:        string(hash),
+		Role:            role,
+		EmailVerifiedAt: &now,
+	}
+	if err := api.repos.Users.Create(&user); err != nil {
+		t.Fatalf("create user %s: %v", username, err)
+	}
+
+	token, err := auth.GenerateToken(user, time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return testUser{user: user, token: token}
+}
+
+// do sends a request with an optional bearer token and JSON body
+func (api *testAPI) do(t *testing.T, method, path, token string, body interface{}, cookies ...*http.Cookie) *httptest.ResponseRecorder {
+	t.Helper()
+
+	var reader io.Reader
+	if body != nil {
+		data, err := json.Marshal(body)
+		if err != nil {
+			t.Fatal(err)
+		}
+		reader = bytes.NewReader(data)
+	}
+
+	req := httptest.NewRequest(method, path, reader)
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	for _, cookie := range cookies {
+		req.AddCookie(cookie)
+	}
+
+	res := httptest.NewRecorder()
+	api.router.ServeHTTP(res, req)
+	return res
+}
+
+func decode(t *testing.T, res *httptest.ResponseRecorder, v interface{}) {
+	t.Helper()
+	if err := json.Unmarshal(res.Body.Bytes(), v); err != nil {
+		t.Fatalf("decode %s: %v", res.Body, err)
+	}
+}
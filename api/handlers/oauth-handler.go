@@ -0,0 +1,311 @@
+package handlers
+
+import (
+	"errors"
+	"log"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/terkoizmy/go-blog-api/config"
+	"github.com/terkoizmy/go-blog-api/internal/auth"
+	"github.com/terkoizmy/go-blog-api/internal/models"
+)
+
+// @Summary List identity providers
+// @Description List the OpenID Connect providers that can be used to log in
+// @Tags oauth
+// @Produce json
+// @Success 200 {array} string
+// @Router /oauth/providers [get]
+func (h *UserHandler) GetOAuthProviders(c *gin.Context) {
+	c.JSON(http.StatusOK, auth.OIDCProviderNames())
+}
+
+// @Summary Start social login
+// @Description Redirect to the identity provider's login page using the authorization code flow with PKCE. Pass redirect=false to get the URL as JSON instead.
+// @Tags oauth
+// @Produce json
+// @Param provider path string true "Provider name"
+// @Param redirect query bool false "Redirect to the provider (default true)"
+// @Success 200 {object} models.OAuthAuthorizationResponse
+// @Success 302
+// @Failure 404 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Router /oauth/{provider}/login [get]
+func (h *UserHandler) OAuthLogin(c *gin.Context) {
+	cfg, err := config.LoadConfig()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "couldn't load config"})
+		return
+	}
+
+	binding, err := oauthBinding(c, cfg)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to start login"})
+		return
+	}
+
+	authURL, err := auth.BeginOIDCLogin(c.Request.Context(), c.Param("provider"), binding, nil, cfg.OIDCStateTTL)
+	if err != nil {
+		writeOAuthError(c, err)
+		return
+	}
+
+	if c.Query("redirect") == "false" {
+		c.JSON(http.StatusOK, models.OAuthAuthorizationResponse{AuthorizationURL: authURL})
+		return
+	}
+
+	c.Redirect(http.StatusFound, authURL)
+}
+
+// @Summary Complete social login
+// @Description Callback from the identity provider. Logs in the user linked to the identity, creating an account on first login. Accounts with two-factor authentication enabled receive a models.TwoFactorChallengeResponse instead of a token. When the login was started to link an identity, the linked identity is returned.
+// @Tags oauth
+// @Produce json
+// @Param provider path string true "Provider name"
+// @Param code query string true "Authorization code"
+// @Param state query string true "State from the login request"
+// @Success 200 {object} models.TokenResponse
+// @Failure 400 {object} map[string]string
+// @Failure 403 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Failure 409 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Router /oauth/{provider}/callback [get]
+func (h *UserHandler) OAuthCallback(c *gin.Context) {
+	if errCode := c.Query("error"); errCode != "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "identity provider returned an error: " + errCode})
+		return
+	}
+
+	code := c.Query("code")
+	state := c.Query("state")
+	if code == "" || state == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "code and state are required"})
+		return
+	}
+
+	// Only the browser that started the login can complete it
+	binding, err := c.Cookie(oauthBindingCookie)
+	if err != nil {
+		writeOAuthError(c, auth.ErrInvalidOAuthState)
+		return
+	}
+
+	identity, linkUserID, err := auth.CompleteOIDCLogin(c.Request.Context(), c.Param("provider"), state, binding, code)
+	if err != nil {
+		writeOAuthError(c, err)
+		return
+	}
+
+	// The flow was started from an account to link a new identity
+	if linkUserID != nil {
+		linked, err := auth.LinkOIDCIdentity(*linkUserID, identity)
+		if err != nil {
+			writeOAuthError(c, err)
+			return
+		}
+
+		c.JSON(http.StatusOK, linked)
+		return
+	}
+
+	user, err := auth.ResolveOIDCUser(identity)
+	if err != nil {
+		writeOAuthError(c, err)
+		return
+	}
+
+	cfg, err := config.LoadConfig()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "couldn't load config"})
+		return
+	}
+
+	if cfg.EmailVerification == config.EmailVerificationLogin && user.EmailVerifiedAt == nil {
+		c.JSON(http.StatusForbidden, gin.H{"error": "email address not verified"})
+		return
+	}
+
+	ip := c.ClientIP()
+	if !checkLoginAllowed(c, &user.ID, ip) {
+		return
+	}
+
+	// The provider replaces the password, not the second factor
+	if user.TwoFactorEnabled {
+		challenge, err := auth.GenerateChallengeToken(user, cfg.TwoFactorChallengeTTL)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to generate challenge token"})
+			return
+		}
+
+		c.JSON(http.StatusOK, models.TwoFactorChallengeResponse{
+			TwoFactorRequired: true,
+			ChallengeToken:    challenge,
+			ExpiresIn:         int64(cfg.TwoFactorChallengeTTL.Seconds()),
+		})
+		return
+	}
+
+	if err := auth.RecordSuccessfulLogin(user.ID, user.Username, ip); err != nil {
+		log.Printf("Failed to record login attempt: %v", err)
+	}
+
+	respondWithTokens(c, user)
+}
+
+// @Summary Link identity
+// @Description Start linking an identity provider account to the current user. Send the user to the returned URL in the same browser; the provider redirects back to the callback, which links the identity. The response sets a cookie the callback checks.
+// @Tags oauth
+// @Produce json
+// @Security BearerAuth
+// @Param provider path string true "Provider name"
+// @Success 200 {object} models.OAuthAuthorizationResponse
+// @Failure 401 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Router /users/me/identities/{provider} [post]
+func (h *UserHandler) LinkIdentity(c *gin.Context) {
+	userID, exists := c.Get("userID")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "unauthorized"})
+		return
+	}
+
+	id, ok := userID.(uuid.UUID)
+	if !ok {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "invalid user ID format"})
+		return
+	}
+
+	cfg, err := config.LoadConfig()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "couldn't load config"})
+		return
+	}
+
+	binding, err := oauthBinding(c, cfg)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to start linking"})
+		return
+	}
+
+	authURL, err := auth.BeginOIDCLogin(c.Request.Context(), c.Param("provider"), binding, &id, cfg.OIDCStateTTL)
+	if err != nil {
+		writeOAuthError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, models.OAuthAuthorizationResponse{AuthorizationURL: authURL})
+}
+
+// @Summary List linked identities
+// @Description List the identity provider accounts linked to the current user
+// @Tags oauth
+// @Produce json
+// @Security BearerAuth
+// @Success 200 {array} models.LinkedIdentity
+// @Failure 401 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Router /users/me/identities [get]
+func (h *UserHandler) GetIdentities(c *gin.Context) {
+	userID, exists := c.Get("userID")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "unauthorized"})
+		return
+	}
+
+	id, ok := userID.(uuid.UUID)
+	if !ok {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "invalid user ID format"})
+		return
+	}
+
+	identities, err := auth.ListLinkedIdentities(id)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to get identities"})
+		return
+	}
+
+	c.JSON(http.StatusOK, identities)
+}
+
+// @Summary Unlink identity
+// @Description Remove a linked identity provider account from the current user
+// @Tags oauth
+// @Produce json
+// @Security BearerAuth
+// @Param id path string true "Linked identity ID"
+// @Success 200 {object} map[string]string
+// @Failure 400 {object} map[string]string
+// @Failure 401 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Router /users/me/identities/{id} [delete]
+func (h *UserHandler) UnlinkIdentity(c *gin.Context) {
+	identityID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid identity ID format"})
+		return
+	}
+
+	userID, exists := c.Get("userID")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "unauthorized"})
+		return
+	}
+
+	id, ok := userID.(uuid.UUID)
+	if !ok {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "invalid user ID format"})
+		return
+	}
+
+	if err := auth.UnlinkIdentity(id, identityID); err != nil {
+		writeOAuthError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "identity unlinked successfully"})
+}
+
+// oauthBindingCookie ties pending logins to the browser that started them
+const oauthBindingCookie = "oauth_binding"
+
+// oauthBinding returns the browser's binding cookie, setting a new one if it
+// has none. The cookie is only sent to the callback, and also on the
+// top-level redirect back from the provider.
+func oauthBinding(c *gin.Context, cfg config.Config) (string, error) {
+	binding, err := c.Cookie(oauthBindingCookie)
+	if err != nil || len(binding) < 32 {
+		if binding, _, err = auth.GenerateOpaqueToken(); err != nil {
+			return "", err
+		}
+	}
+
+	secure := strings.HasPrefix(cfg.OIDCRedirectBaseURL, "https://")
+	c.SetSameSite(http.SameSiteLaxMode)
+	c.SetCookie(oauthBindingCookie, binding, int(cfg.OIDCStateTTL.Seconds()), "/api/v1/oauth", "", secure, true)
+	return binding, nil
+}
+
+func writeOAuthError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, auth.ErrUnknownOIDCProvider),
+		errors.Is(err, auth.ErrIdentityNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+	case errors.Is(err, auth.ErrInvalidOAuthState),
+		errors.Is(err, auth.ErrOIDCEmailRequired):
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	case errors.Is(err, auth.ErrOIDCAccountExists),
+		errors.Is(err, auth.ErrIdentityAlreadyLinked):
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+	default:
+		log.Printf("External login failed: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "external login failed"})
+	}
+}
@@ -0,0 +1,256 @@
+package handlers_test
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v4"
+	"github.com/terkoizmy/go-blog-api/config"
+	"github.com/terkoizmy/go-blog-api/internal/auth"
+	"github.com/terkoizmy/go-blog-api/internal/models"
+)
+
+// mockIssuer is a minimal OpenID Connect provider. Tests stand in for the
+// user at the authorization endpoint by calling authorize with the URL the
+// API sent them to.
+type mockIssuer struct {
+	*httptest.Server
+	t     *testing.T
+	key   *rsa.PrivateKey
+	mu    sync.Mutex
+	codes map[string]mockGrant
+}
+
+type mockGrant struct {
+	nonce     string
+	challenge string
+	subject   string
+	email     string
+}
+
+func newMockIssuer(t *testing.T) *mockIssuer {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	m := &mockIssuer{t: t, key: key, codes: map[string]mockGrant{}}
+	mux := http.NewServeMux()
+	mux.HandleFunc("/.well-known/openid-configuration", m.discovery)
+	mux.HandleFunc("/jwks", m.jwks)
+	mux.HandleFunc("/token", m.token)
+	m.Server = httptest.NewServer(mux)
+	t.Cleanup(m.Close)
+	return m
+}
+
+func (m *mockIssuer) discovery(w http.ResponseWriter, r *http.Request) {
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"issuer":                                m.URL,
+		"authorization_endpoint":                m.URL + "/authorize",
+		"token_endpoint":                        m.URL + "/token",
+		"jwks_uri":                              m.URL + "/jwks",
+		"id_token_signing_alg_values_supported": []string{"RS256"},
+	})
+}
+
+func (m *mockIssuer) jwks(w http.ResponseWriter, r *http.Request) {
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"keys": []map[string]string{{
+			"kty": "RSA",
+			"kid": "mock",
+			"use": "sig",
+			"alg": "RS256",
+			"n":   base64.RawURLEncoding.EncodeToString(m.key.N.Bytes()),
+			"e":   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(m.key.E)).Bytes()),
+		}},
+	})
+}
+
+func (m *mockIssuer) token(w http.ResponseWriter, r *http.Request) {
+	m.mu.Lock()
+	grant, ok := m.codes[r.FormValue("code")]
+	delete(m.codes, r.FormValue("code"))
+	m.mu.Unlock()
+
+	verifier := sha256.Sum256([]byte(r.FormValue("code_verifier")))
+	if !ok || base64.RawURLEncoding.EncodeToString(verifier[:]) != grant.challenge {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(map[string]string{"error": "invalid_grant"})
+		return
+	}
+
+	idToken := jwt.NewWithClaims(jwt.SigningMethodRS256, jwt.MapClaims{
+		"iss":            m.URL,
+		"sub":            grant.subject,
+		"aud":            "blog",
+		"exp":            time.Now().Add(time.Minute).Unix(),
+		"iat":            time.Now().Unix(),
+		"nonce":          grant.nonce,
+		"email":          grant.email,
+		"email_verified": true,
+	})
+	idToken.Header["kid"] = "mock"
+	signed, err := idToken.SignedString(m.key)
+	if err != nil {
+		m.t.Error(err)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"access_token": "mock-access-token",
+		"token_type":   "Bearer",
+		"expires_in":   60,
+		"id_token":     signed,
+	})
+}
+
+// authorize logs the user in at the provider and returns the callback URL
+// the provider would redirect the browser to
+func (m *mockIssuer) authorize(authorizationURL, subject, email string) string {
+	m.t.Helper()
+
+	u, err := url.Parse(authorizationURL)
+	if err != nil {
+		m.t.Fatal(err)
+	}
+	query := u.Query()
+	if query.Get("client_id") != "blog" || query.Get("code_challenge_method") != "S256" {
+		m.t.Fatalf("unexpected authorization request %s", authorizationURL)
+	}
+
+	code := subject + "-code"
+	m.mu.Lock()
+	m.codes[code] = mockGrant{
+		nonce:     query.Get("nonce"),
+		challenge: query.Get("code_challenge"),
+		subject:   subject,
+		email:     email,
+	}
+	m.mu.Unlock()
+
+	callback := url.Values{"code": {code}, "state": {query.Get("state")}}
+	return "/api/v1/oauth/mock/callback?" + callback.Encode()
+}
+
+func setupOIDC(t *testing.T) *mockIssuer {
+	issuer := newMockIssuer(t)
+	err := auth.InitOIDC(config.Config{OIDCProviders: []config.OIDCProvider{{
+		Name:         "mock",
+		Issuer:       issuer.URL,
+		ClientID:     "blog",
+		ClientSecret: "secret",
+		RedirectURL:  "http://blog.test/api/v1/oauth/mock/callback",
+		Scopes:       []string{"openid", "email", "profile"},
+	}}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	auth.SetOIDCHTTPClient(issuer.Client())
+	t.Cleanup(func() {
+		auth.InitOIDC(config.Config{})
+		auth.SetOIDCHTTPClient(nil)
+	})
+	return issuer
+}
+
+func TestOAuthLoginCreatesAccount(t *testing.T) {
+	api := newTestAPI(t)
+	issuer := setupOIDC(t)
+
+	start := api.do(t, http.MethodGet, "/api/v1/oauth/mock/login?redirect=false", "", nil)
+	if start.Code != http.StatusOK {
+		t.Fatalf("login: got %d %s", start.Code, start.Body)
+	}
+	var authorization models.OAuthAuthorizationResponse
+	decode(t, start, &authorization)
+
+	callback := issuer.authorize(authorization.AuthorizationURL, "alice-sub", "alice@example.com")
+	res := api.do(t, http.MethodGet, callback, "", nil, start.Result().Cookies()...)
+	if res.Code != http.StatusOK {
+		t.Fatalf("callback: got %d %s", res.Code, res.Body)
+	}
+	var tokens models.TokenResponse
+	decode(t, res, &tokens)
+	if tokens.Token == "" || tokens.RefreshToken == "" {
+		t.Fatalf("callback didn't return tokens: %s", res.Body)
+	}
+
+	user, err := api.repos.Users.FindByEmail("alice@example.com")
+	if err != nil {
+		t.Fatalf("account wasn't created: %v", err)
+	}
+	if user.EmailVerifiedAt == nil {
+		t.Error("verified provider email wasn't marked verified")
+	}
+
+	// The authorization request can't be completed twice
+	replay := api.do(t, http.MethodGet, callback, "", nil, start.Result().Cookies()...)
+	if replay.Code != http.StatusBadRequest {
+		t.Errorf("replayed callback: got %d, want 400", replay.Code)
+	}
+}
+
+func TestLinkIdentityIsBoundToBrowser(t *testing.T) {
+	api := newTestAPI(t)
+	issuer := setupOIDC(t)
+	attacker := api.createUser(t, "mallory", auth.RoleUser)
+
+	start := api.do(t, http.MethodPost, "/api/v1/users/me/identities/mock", attacker.token, nil)
+	if start.Code != http.StatusOK {
+		t.Fatalf("link: got %d %s", start.Code, start.Body)
+	}
+	var authorization models.OAuthAuthorizationResponse
+	decode(t, start, &authorization)
+
+	// The victim opens the attacker's link in their own browser, which has
+	// no binding cookie
+	callback := issuer.authorize(authorization.AuthorizationURL, "victim-sub", "victim@example.com")
+	res := api.do(t, http.MethodGet, callback, "", nil)
+	if res.Code != http.StatusBadRequest {
+		t.Fatalf("callback from another browser: got %d %s, want 400", res.Code, res.Body)
+	}
+
+	identities, err := auth.ListLinkedIdentities(attacker.user.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(identities) != 0 {
+		t.Fatalf("identity was linked to the attacker: %+v", identities)
+	}
+}
+
+func TestLinkIdentity(t *testing.T) {
+	api := newTestAPI(t)
+	issuer := setupOIDC(t)
+	owner := api.createUser(t, "bob", auth.RoleUser)
+
+	start := api.do(t, http.MethodPost, "/api/v1/users/me/identities/mock", owner.token, nil)
+	if start.Code != http.StatusOK {
+		t.Fatalf("link: got %d %s", start.Code, start.Body)
+	}
+	var authorization models.OAuthAuthorizationResponse
+	decode(t, start, &authorization)
+
+	callback := issuer.authorize(authorization.AuthorizationURL, "bob-sub", "bob@example.com")
+	res := api.do(t, http.MethodGet, callback, "", nil, start.Result().Cookies()...)
+	if res.Code != http.StatusOK {
+		t.Fatalf("callback: got %d %s", res.Code, res.Body)
+	}
+
+	var linked models.LinkedIdentity
+	decode(t, res, &linked)
+	if linked.UserID != owner.user.ID || linked.Subject != "bob-sub" {
+		t.Fatalf("linked %+v, want bob-sub on %s", linked, owner.user.ID)
+	}
+}
@@ -0,0 +1,233 @@
+package handlers
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/terkoizmy/go-blog-api/config"
+	"github.com/terkoizmy/go-blog-api/internal/models"
+	"github.com/terkoizmy/go-blog-api/internal/repository"
+)
+
+// pagination is the page of a listing requested with the page and limit
+// query parameters
+type pagination struct {
+	Page  int
+	Limit int
+}
+
+func (p pagination) Offset() int {
+	return (p.Page - 1) * p.Limit
+}
+
+// Repository returns the page as a repository.Page
+func (p pagination) Repository() repository.Page {
+	return repository.Page{Offset: p.Offset(), Limit: p.Limit}
+}
+
+// parsePagination reads the page and limit query parameters. limit defaults
+// to PAGE_DEFAULT_LIMIT and is capped at PAGE_MAX_LIMIT. Invalid values get
+// a 400 response and false.
+func parsePagination(c *gin.Context) (pagination, bool) {
+	cfg, err := config.LoadConfig()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "couldn't load config"})
+		return pagination{}, false
+	}
+
+	limit, ok := parseLimit(c, cfg)
+	if !ok {
+		return pagination{}, false
+	}
+	p := pagination{Page: 1, Limit: limit}
+
+	if pageStr, exists := c.GetQuery("page"); exists {
+		page, err := strconv.Atoi(pageStr)
+		if err != nil || page < 1 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "page must be a positive integer"})
+			return pagination{}, false
+		}
+		p.Page = page
+	}
+
+	return p, true
+}
+
+// parseLimit reads the limit query parameter, applying the configured
+// default and maximum
+func parseLimit(c *gin.Context, cfg config.Config) (int, bool) {
+	limit := cfg.PageDefaultLimit
+	if limitStr, exists := c.GetQuery("limit"); exists {
+		val, err := strconv.Atoi(limitStr)
+		if err != nil || val < 1 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "limit must be a positive integer"})
+			return 0, false
+		}
+		limit = val
+	}
+
+	if cfg.PageMaxLimit > 0 && limit > cfg.PageMaxLimit {
+		limit = cfg.PageMaxLimit
+	}
+	if limit < 1 {
+		limit = 1
+	}
+
+	return limit, true
+}
+
+// writePage responds with one page of a listing and a Link header (RFC 5988)
+// pointing at the first, previous, next and last pages
+func writePage(c *gin.Context, data interface{}, p pagination, total int64) {
+	totalPages := int((total + int64(p.Limit) - 1) / int64(p.Limit))
+
+	lastPage := totalPages
+	if lastPage < 1 {
+		lastPage = 1
+	}
+
+	links := []string{pageLink(c, 1, p.Limit, "first")}
+	if p.Page > 1 {
+		prev := p.Page - 1
+		if prev > lastPage {
+			prev = lastPage
+		}
+		links = append(links, pageLink(c, prev, p.Limit, "prev"))
+	}
+	if p.Page < totalPages {
+		links = append(links, pageLink(c, p.Page+1, p.Limit, "next"))
+	}
+	links = append(links, pageLink(c, lastPage, p.Limit, "last"))
+	c.Header("Link", strings.Join(links, ", "))
+
+	c.JSON(http.StatusOK, models.PaginatedResponse{
+		Data:       data,
+		Page:       p.Page,
+		Limit:      p.Limit,
+		Total:      total,
+		TotalPages: totalPages,
+	})
+}
+
+// pageLink builds a Link header entry for the current URL at another page
+func pageLink(c *gin.Context, page, limit int, rel string) string {
+	u := *c.Request.URL
+	query := u.Query()
+	query.Set("page", strconv.Itoa(page))
+	query.Set("limit", strconv.Itoa(limit))
+	u.RawQuery = query.Encode()
+
+	return fmt.Sprintf(`<%s>; rel="%s"`, u.RequestURI(), rel)
+}
+
+// usesCursor reports whether the request asks for cursor pagination, which
+// it does by passing a cursor, even an empty one
+func usesCursor(c *gin.Context) bool {
+	_, exists := c.GetQuery("cursor")
+	return exists
+}
+
+// cursorToken is the content of an opaque cursor
+type cursorToken struct {
+	Time   time.Time `json:"t"`
+	ID     uuid.UUID `json:"id"`
+	Before bool      `json:"b,omitempty"`
+}
+
+// parseCursorPage reads the cursor and limit query parameters. An empty
+// cursor starts at the beginning of the feed. Invalid values get a 400
+// response and false.
+func parseCursorPage(c *gin.Context) (repository.CursorPage, bool) {
+	cfg, err := config.LoadConfig()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "couldn't load config"})
+		return repository.CursorPage{}, false
+	}
+
+	limit, ok := parseLimit(c, cfg)
+	if !ok {
+		return repository.CursorPage{}, false
+	}
+	page := repository.CursorPage{Limit: limit}
+
+	if value := c.Query("cursor"); value != "" {
+		cursor, err := decodeCursor(value)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid cursor"})
+			return repository.CursorPage{}, false
+		}
+		page.Cursor = &cursor
+	}
+
+	return page, true
+}
+
+func decodeCursor(value string) (repository.Cursor, error) {
+	data, err := base64.RawURLEncoding.DecodeString(value)
+	if err != nil {
+		return repository.Cursor{}, err
+	}
+
+	var token cursorToken
+	if err := json.Unmarshal(data, &token); err != nil {
+		return repository.Cursor{}, err
+	}
+	if token.ID == uuid.Nil || token.Time.IsZero() {
+		return repository.Cursor{}, errors.New("incomplete cursor")
+	}
+
+	return repository.Cursor{Time: token.Time, ID: token.ID, Before: token.Before}, nil
+}
+
+func encodeCursor(cursor *repository.Cursor) *string {
+	if cursor == nil {
+		return nil
+	}
+
+	data, _ := json.Marshal(cursorToken{Time: cursor.Time, ID: cursor.ID, Before: cursor.Before})
+	value := base64.RawURLEncoding.EncodeToString(data)
+	return &value
+}
+
+// writeCursorPage responds with one page of a feed and a Link header
+// pointing at the next and previous pages
+func writeCursorPage(c *gin.Context, data interface{}, page repository.CursorPage, cursors repository.Cursors) {
+	response := models.CursorResponse{
+		Data:       data,
+		Limit:      page.Limit,
+		NextCursor: encodeCursor(cursors.Next),
+		PrevCursor: encodeCursor(cursors.Prev),
+	}
+
+	var links []string
+	if response.NextCursor != nil {
+		links = append(links, cursorLink(c, *response.NextCursor, page.Limit, "next"))
+	}
+	if response.PrevCursor != nil {
+		links = append(links, cursorLink(c, *response.PrevCursor, page.Limit, "prev"))
+	}
+	if len(links) > 0 {
+		c.Header("Link", strings.Join(links, ", "))
+	}
+
+	c.JSON(http.StatusOK, response)
+}
+
+// cursorLink builds a Link header entry for the current URL at another cursor
+func cursorLink(c *gin.Context, cursor string, limit int, rel string) string {
+	u := *c.Request.URL
+	query := u.Query()
+	query.Set("cursor", cursor)
+	query.Set("limit", strconv.Itoa(limit))
+	u.RawQuery = query.Encode()
+
+	return fmt.Sprintf(`<%s>; rel="%s"`, u.RequestURI(), rel)
+}
@@ -1,24 +1,35 @@
 package handlers
 
 import (
+	"errors"
 	"fmt"
+	"log"
 	"net/http"
 	"regexp"
 	"strconv"
 	"strings"
-	"time"
 
 	"github.com/gin-gonic/gin"
 	"github.com/google/uuid"
-	"github.com/terkoizmy/go-blog-api/internal/db"
+	"github.com/terkoizmy/go-blog-api/config"
+	"github.com/terkoizmy/go-blog-api/internal/auth"
+	"github.com/terkoizmy/go-blog-api/internal/markup"
 	"github.com/terkoizmy/go-blog-api/internal/models"
+	"github.com/terkoizmy/go-blog-api/internal/repository"
+	"github.com/terkoizmy/go-blog-api/internal/workflow"
 )
 
 // PostHandler handles post-related routes
-type PostHandler struct{}
+type PostHandler struct {
+	posts       repository.PostRepository
+	revisions   repository.PostRevisionRepository
+	transitions repository.PostTransitionRepository
+	users       repository.UserRepository
+	categories  repository.CategoryRepository
+}
 
-func NewPostHandler() *PostHandler {
-	return &PostHandler{}
+func NewPostHandler(posts repository.PostRepository, revisions repository.PostRevisionRepository, transitions repository.PostTransitionRepository, users repository.UserRepository, categories repository.CategoryRepository) *PostHandler {
+	return &PostHandler{posts: posts, revisions: revisions, transitions: transitions, users: users, categories: categories}
 }
 
 // Helper for generate slog from title
@@ -80,6 +91,25 @@ func (h *PostHandler) CreatePost(c *gin.Context) {
 		return
 	}
 
+	cfg, err := config.LoadConfig()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "couldn't load config"})
+		return
+	}
+
+	// Unverified accounts can't post when verification is required for posting
+	if cfg.EmailVerification == config.EmailVerificationPosts {
+		author, err := h.users.FindByID(authorID)
+		if err != nil {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "unauthorized"})
+			return
+		}
+		if author.EmailVerifiedAt == nil {
+			c.JSON(http.StatusForbidden, gin.H{"error": "email address not verified"})
+			return
+		}
+	}
+
 	// Generate slug if not provided
 	slug := req.Slug
 	if slug == "" {
@@ -89,8 +119,7 @@ func (h *PostHandler) CreatePost(c *gin.Context) {
 	}
 
 	// Check if slug already exists
-	var existingPost models.Post
-	if result := db.DB.Where("slug = ?", slug).First(&existingPost); result.RowsAffected > 0 {
+	if exists, _ := h.posts.SlugExists(slug, uuid.Nil); exists {
 		// Add a unique identifier to the slug
 		slug = slug + "-" + uuid.New().String()[:8]
 	}
@@ -98,53 +127,63 @@ func (h *PostHandler) CreatePost(c *gin.Context) {
 	// Set default status if not provided
 	status := req.Status
 	if status == "" {
-		status = "draft"
+		status = workflow.Draft
+		if req.PublishAt != nil {
+			status = workflow.Scheduled
+		}
+	}
+
+	format := req.ContentFormat
+	if format == "" {
+		format = markup.Markdown
+	} else if !markup.IsValidFormat(format) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": markup.ErrInvalidFormat.Error()})
+		return
 	}
 
 	// Create post
 	post := models.Post{
-		Title:    req.Title,
-		Content:  req.Content,
-		Slug:     slug,
-		Status:   status,
-		AuthorID: authorID,
+		Title:         req.Title,
+		Content:       req.Content,
+		ContentFormat: format,
+		Slug:          slug,
+		AuthorID:      authorID,
 	}
 
-	// If status is "published", set PublishedAt to nncurrent time
-	if status == "published" {
-		now := time.Now()
-		post.PublishedAt = &now
+	applyExcerpt(&post, req.Excerpt)
+
+	tags, err := newTags(req.Tags)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
 	}
 
-	if result := db.DB.Create(&post); result.Error != nil {
+	if !changeStatus(c, &post, status, req.PublishAt) {
+		return
+	}
+
+	// The post is created with all of its tags or not at all
+	post.Tags = tags
+	if err := h.posts.Create(&post); err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to create post"})
 		return
 	}
+	recordTransition(h.transitions, post.ID, "", post.Status, authorID, "")
 
-	// Add categories if provided
+	// Add categories if provided, skipping invalid ones
 	if len(req.CategoryIDs) > 0 {
-		for _, categoryID := range req.CategoryIDs {
-			// Check if category exists
-			var category models.Category
-			if result := db.DB.Where("id = ?", categoryID).First(&category); result.Error != nil {
-				// Skip invalid categories
-				continue
-			}
+		h.posts.SetCategories(&post, req.CategoryIDs)
+	}
 
-			// Add association
-			db.DB.Model(&post).Association("Categories").Append(&category)
+	// Load author details and categories
+	if created, err := h.posts.FindByIDWithAuthor(post.ID); err == nil {
+		post = created
+		if err := recordRevision(h.revisions, post, authorID, nil); err != nil {
+			log.Printf("Failed to record revision of post %s: %v", post.ID, err)
 		}
 	}
-
-	// Load author details
-	var author models.User
-	db.DB.Where("id = ?", authorID).First(&author)
-	post.Author = author
 	post.Author.Password = "" // Don't return password
 
-	// Load categories
-	db.DB.Model(&post).Association("Categories").Find(&post.Categories)
-
 	c.JSON(http.StatusCreated, post)
 
 }
@@ -156,50 +195,78 @@ func (h *PostHandler) CreatePost(c *gin.Context) {
 // @Produce json
 // @Param page query int false "Page number"
 // @Param limit query int false "Items per page"
-// @Param status query string false "Filter by status"
-// @Success 200 {array} models.Post
+// @Param cursor query string false "Cursor from next_cursor or prev_cursor, empty for the first page. Switches to cursor pagination ordered by publication date."
+// @Param status query string false "Filter by status; statuses other than published need the author filter set to yourself, or the review or edit_any permission"
+// @Param sort query string false "published_at (default), created_at, title or comments"
+// @Param order query string false "asc or desc; title defaults to asc, the others to desc"
+// @Param author query string false "Author ID or username"
+// @Param category query string false "Comma-separated category IDs or slugs"
+// @Param category_match query string false "any (default) or all of the categories"
+// @Param from query string false "Only posts published from this date on (YYYY-MM-DD or RFC 3339)"
+// @Param to query string false "Only posts published up to this date (YYYY-MM-DD or RFC 3339)"
+// @Param has_comments query bool false "Only posts with (true) or without (false) comments"
+// @Param content query string false "excerpt (default) leaves out the content of each post, full includes it"
+// @Success 200 {object} models.PaginatedResponse{data=[]models.Post} "models.CursorResponse when cursor is given"
+// @Failure 400 {object} map[string]string
+// @Failure 401 {object} map[string]string
+// @Failure 403 {object} map[string]string
 // @Failure 500 {object} map[string]string
 // @Router /posts [get]
 func (h *PostHandler) GetAllPosts(c *gin.Context) {
-	// Pagination
-	page := 1
-	if pageQuery, exists := c.GetQuery("page"); exists {
-		if _, err := c.Get(pageQuery); err {
-			page = 1
-		}
+	full, ok := parseListContent(c)
+	if !ok {
+		return
+	}
+
+	filter, ok := h.parsePostFilter(c)
+	if !ok {
+		return
 	}
 
-	limit := 10
-	// if limitQuery, exists := c.GetQuery("limit"); exists {
-	// 	if val, err := c.GetInt(limitQuery); err == nil {
-	// 		limit = val
-	// 	}
-	// }
+	// By default, only show published posts to public
+	if filter.Status == "" {
+		filter.Status = "published"
+	}
 
-	if limitStr, exists := c.GetQuery("limit"); exists {
-		if limitVal, err := strconv.Atoi(limitStr); err == nil {
-			limit = limitVal
+	if usesCursor(c) {
+		if c.Query("sort") != "" || c.Query("order") != "" {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "sort and order can't be combined with cursor"})
+			return
 		}
-	}
 
-	offset := (page - 1) * limit
+		page, ok := parseCursorPage(c)
+		if !ok {
+			return
+		}
 
-	// Status filter
-	status := c.Query("status")
+		posts, cursors, err := h.posts.Feed(filter, page)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to get posts"})
+			return
+		}
 
-	var posts []models.Post
-	query := db.DB.Offset(offset).Limit(limit).Preload("Author").Preload("Categories")
+		for i := range posts {
+			posts[i].Author.Password = ""
+			posts[i].Author.Role = ""
+		}
 
-	// Apply status filter if provided
-	if status != "" {
-		query = query.Where("status = ?", status)
-	} else {
-		// By default, only show published posts to public
-		query = query.Where("status = ?", "published")
+		trimContent(posts, full)
+		writeCursorPage(c, posts, page, cursors)
+		return
 	}
 
-	// Execute query
-	if result := query.Find(&posts); result.Error != nil {
+	p, ok := parsePagination(c)
+	if !ok {
+		return
+	}
+	filter.Offset = p.Offset()
+	filter.Limit = p.Limit
+
+	posts, total, err := h.posts.List(filter)
+	if errors.Is(err, repository.ErrInvalidSort) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "sort must be published_at, created_at, title or comments"})
+		return
+	} else if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to get posts"})
 		return
 	}
@@ -210,7 +277,106 @@ func (h *PostHandler) GetAllPosts(c *gin.Context) {
 		posts[i].Author.Role = ""
 	}
 
-	c.JSON(http.StatusOK, posts)
+	trimContent(posts, full)
+	writePage(c, posts, p, total)
+}
+
+// parsePostFilter reads the status, sort and filter query parameters of a
+// post listing. Invalid values get a 400 response and false.
+func (h *PostHandler) parsePostFilter(c *gin.Context) (repository.PostFilter, bool) {
+	filter := repository.PostFilter{
+		Status: c.Query("status"),
+		Sort:   c.Query("sort"),
+	}
+
+	if filter.Status != "" && !workflow.IsValid(filter.Status) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": workflow.ErrInvalidStatus.Error()})
+		return filter, false
+	}
+
+	switch c.Query("order") {
+	case "":
+		filter.Ascending = filter.Sort == repository.PostSortTitle
+	case "asc":
+		filter.Ascending = true
+	case "desc":
+	default:
+		c.JSON(http.StatusBadRequest, gin.H{"error": "order must be asc or desc"})
+		return filter, false
+	}
+
+	if author := c.Query("author"); author != "" {
+		id, err := resolveAuthor(h.users, author)
+		if errors.Is(err, repository.ErrNotFound) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "author not found"})
+			return filter, false
+		} else if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to get posts"})
+			return filter, false
+		}
+		filter.AuthorID = id
+	}
+
+	if categories := c.Query("category"); categories != "" {
+		seen := make(map[uuid.UUID]bool)
+		for _, category := range strings.Split(categories, ",") {
+			category = strings.TrimSpace(category)
+			if category == "" {
+				continue
+			}
+
+			id, err := resolveCategory(h.categories, category)
+			if errors.Is(err, repository.ErrNotFound) {
+				c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("category %q not found", category)})
+				return filter, false
+			} else if err != nil {
+				c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to get posts"})
+				return filter, false
+			}
+
+			if !seen[id] {
+				seen[id] = true
+				filter.CategoryIDs = append(filter.CategoryIDs, id)
+			}
+		}
+	}
+
+	switch c.Query("category_match") {
+	case "", "any":
+	case "all":
+		filter.AllCategories = true
+	default:
+		c.JSON(http.StatusBadRequest, gin.H{"error": "category_match must be any or all"})
+		return filter, false
+	}
+
+	from, to, ok := parseDateRange(c)
+	if !ok {
+		return filter, false
+	}
+	filter.From = from
+	filter.To = to
+
+	if value := c.Query("has_comments"); value != "" {
+		hasComments, err := strconv.ParseBool(value)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "has_comments must be true or false"})
+			return filter, false
+		}
+		filter.HasComments = &hasComments
+	}
+
+	// Unpublished posts are only listed for their author or reviewers
+	if filter.Status != "" && filter.Status != workflow.Published && !auth.CanSeeUnpublished(c, filter.AuthorID) {
+		if _, exists := c.Get("userID"); !exists {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "sign in to list unpublished posts"})
+		} else {
+			c.JSON(http.StatusForbidden, gin.H{"error": "permission denied to list unpublished posts of other authors"})
+		}
+		return filter, false
+	}
+
+	return filter, true
 }
 
 // @Summary Get post by ID
@@ -219,11 +385,18 @@ func (h *PostHandler) GetAllPosts(c *gin.Context) {
 // @Accept json
 // @Produce json
 // @Param id path string true "Post ID"
-// @Success 200 {object} models.Post
+// @Param render query string false "Set to html to include the content rendered as sanitized HTML"
+// @Success 200 {object} models.RenderedPost
+// @Failure 400 {object} map[string]string
 // @Failure 404 {object} map[string]string
 // @Failure 500 {object} map[string]string
 // @Router /posts/{id} [get]
 func (h *PostHandler) GetPostByID(c *gin.Context) {
+	render, ok := parseRender(c)
+	if !ok {
+		return
+	}
+
 	id := c.Param("id")
 	fmt.Println(id)
 	// Parse the UUID
@@ -234,32 +407,16 @@ func (h *PostHandler) GetPostByID(c *gin.Context) {
 		return
 	}
 
-	var post models.Post
-	if result := db.DB.Preload("Author").Preload("Categories").Preload("Comments.Author").Where("id = ?", postUUID).First(&post); result.Error != nil {
+	post, err := h.posts.FindByIDWithRelations(postUUID)
+	if err != nil {
 		c.JSON(http.StatusNotFound, gin.H{"error": "post not found"})
 		return
 	}
 
-	// Check if the post is published or the user is the author or admin
-	if post.Status != "published" {
-		c.JSON(http.StatusNotFound, gin.H{"error": "post not published yet from author"})
+	// Only the author and reviewers see unpublished posts
+	if post.Status != workflow.Published && !auth.CanSeeUnpublished(c, post.AuthorID) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "post not found"})
 		return
-		// userID, exists := c.Get("userID")
-		// userRole, roleExists := c.Get("role")
-
-		// // If not authenticated or not the author or admin, return 404
-		// if !exists || !roleExists {
-		// 	c.JSON(http.StatusNotFound, gin.H{"error": "post not found"})
-		// 	return
-		// }
-
-		// authorID, idOk := userID.(uuid.UUID)
-		// roleStr, roleOk := userRole.(string)
-
-		// if !idOk || !roleOk || (post.AuthorID != authorID && roleStr != "admin") {
-		// 	c.JSON(http.StatusNotFound, gin.H{"error": "post not found"})
-		// 	return
-		// }
 	}
 
 	// Clean up sensitive information
@@ -269,7 +426,7 @@ func (h *PostHandler) GetPostByID(c *gin.Context) {
 		post.Comments[i].Author.Password = ""
 	}
 
-	c.JSON(http.StatusOK, post)
+	writePost(c, post, render)
 }
 
 // @Summary Get own posts
@@ -278,11 +435,20 @@ func (h *PostHandler) GetPostByID(c *gin.Context) {
 // @Accept json
 // @Produce json
 // @Security BearerAuth
-// @Success 200 {array} models.Post
+// @Param page query int false "Page number"
+// @Param limit query int false "Items per page"
+// @Param content query string false "excerpt (default) leaves out the content of each post, full includes it"
+// @Success 200 {object} models.PaginatedResponse{data=[]models.Post}
+// @Failure 400 {object} map[string]string
 // @Failure 401 {object} map[string]string
 // @Failure 500 {object} map[string]string
 // @Router /posts/own [get]
 func (h *PostHandler) GetOwnPosts(c *gin.Context) {
+	full, ok := parseListContent(c)
+	if !ok {
+		return
+	}
+
 	ownID, exists := c.Get("userID")
 	if !exists {
 		c.JSON(http.StatusUnauthorized, gin.H{"error": "unauthorized"})
@@ -294,8 +460,13 @@ func (h *PostHandler) GetOwnPosts(c *gin.Context) {
 		return
 	}
 
-	var posts []models.Post
-	if result := db.DB.Preload("Categories").Where("author_id = ?", userID).Find(&posts); result.Error != nil {
+	p, ok := parsePagination(c)
+	if !ok {
+		return
+	}
+
+	posts, total, err := h.posts.List(repository.PostFilter{AuthorID: userID, Offset: p.Offset(), Limit: p.Limit})
+	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to get posts"})
 		return
 	}
@@ -304,7 +475,121 @@ func (h *PostHandler) GetOwnPosts(c *gin.Context) {
 		posts[i].Author.Password = ""
 	}
 
-	c.JSON(http.StatusOK, posts)
+	trimContent(posts, full)
+	writePage(c, posts, p, total)
+}
+
+// @Summary Get scheduled posts
+// @Description Get scheduled posts, due first. Editors who can edit any post see everyone's, other users their own.
+// @Tags posts
+// @Produce json
+// @Security BearerAuth
+// @Param page query int false "Page number"
+// @Param limit query int false "Items per page"
+// @Param content query string false "excerpt (default) leaves out the content of each post, full includes it"
+// @Success 200 {object} models.PaginatedResponse{data=[]models.Post}
+// @Failure 400 {object} map[string]string
+// @Failure 401 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Router /posts/scheduled [get]
+func (h *PostHandler) GetScheduledPosts(c *gin.Context) {
+	full, ok := parseListContent(c)
+	if !ok {
+		return
+	}
+
+	userID, exists := c.Get("userID")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "unauthorized"})
+		return
+	}
+
+	authorID, ok := userID.(uuid.UUID)
+	if !ok {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "invalid user ID format"})
+		return
+	}
+	if auth.Can(c, auth.PermPostsEditAny) {
+		authorID = uuid.Nil
+	}
+
+	p, ok := parsePagination(c)
+	if !ok {
+		return
+	}
+
+	posts, total, err := h.posts.ListScheduled(authorID, p.Repository())
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to get posts"})
+		return
+	}
+
+	for i := range posts {
+		posts[i].Author.Password = ""
+	}
+
+	trimContent(posts, full)
+	writePage(c, posts, p, total)
+}
+
+// @Summary Cancel scheduled publishing
+// @Description Turn a scheduled post back into a draft
+// @Tags posts
+// @Produce json
+// @Security BearerAuth
+// @Param id path string true "Post ID"
+// @Success 200 {object} models.Post
+// @Failure 400 {object} map[string]string
+// @Failure 403 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Failure 409 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Router /posts/{id}/schedule [delete]
+func (h *PostHandler) CancelSchedule(c *gin.Context) {
+	postUUID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid post ID format"})
+		return
+	}
+
+	post, err := h.posts.FindByID(postUUID)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "post not found"})
+		return
+	}
+
+	if !auth.CanModify(c, post.AuthorID, auth.PermPostsEditAny) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "permission denied"})
+		return
+	}
+
+	// The scheduler may publish the post in the meantime, so only a post
+	// that is still scheduled is changed
+	userID, ok := c.MustGet("userID").(uuid.UUID)
+	if !ok {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "invalid user ID format"})
+		return
+	}
+
+	cancelled, err := h.posts.CancelSchedule(postUUID, userID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to cancel schedule"})
+		return
+	}
+	if !cancelled {
+		c.JSON(http.StatusConflict, gin.H{"error": "post is not scheduled"})
+		return
+	}
+
+	post, err = h.posts.FindByIDWithAuthor(postUUID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to get post"})
+		return
+	}
+
+	post.Author.Password = ""
+	post.Author.Role = ""
+	c.JSON(http.StatusOK, post)
 }
 
 // @Summary Get post by USER ID
@@ -313,11 +598,20 @@ func (h *PostHandler) GetOwnPosts(c *gin.Context) {
 // @Accept json
 // @Produce json
 // @Param userId path string true "User ID"
-// @Success 200 {object} models.Post
+// @Param page query int false "Page number"
+// @Param limit query int false "Items per page"
+// @Param content query string false "excerpt (default) leaves out the content of each post, full includes it"
+// @Success 200 {object} models.PaginatedResponse{data=[]models.Post}
+// @Failure 400 {object} map[string]string
 // @Failure 404 {object} map[string]string
 // @Failure 500 {object} map[string]string
 // @Router /posts/user/{userId} [get]
 func (h *PostHandler) GetPostsByUserID(c *gin.Context) {
+	full, ok := parseListContent(c)
+	if !ok {
+		return
+	}
+
 	userIDParam := c.Param("userId")
 
 	// Parse the UUID
@@ -327,8 +621,18 @@ func (h *PostHandler) GetPostsByUserID(c *gin.Context) {
 		return
 	}
 
-	var posts []models.Post
-	if result := db.DB.Preload("Author").Preload("Categories").Preload("Comments.Author").Where("author_id = ?", userID).Where("status = ?", "published").Find(&posts); result.Error != nil {
+	p, ok := parsePagination(c)
+	if !ok {
+		return
+	}
+
+	posts, total, err := h.posts.ListWithRelations(repository.PostFilter{
+		AuthorID: userID,
+		Status:   "published",
+		Offset:   p.Offset(),
+		Limit:    p.Limit,
+	})
+	if err != nil {
 		c.JSON(http.StatusNotFound, gin.H{"error": "Failet to get posts"})
 		return
 	}
@@ -339,7 +643,8 @@ func (h *PostHandler) GetPostsByUserID(c *gin.Context) {
 		posts[i].Author.Role = ""
 	}
 
-	c.JSON(http.StatusOK, posts)
+	trimContent(posts, full)
+	writePage(c, posts, p, total)
 }
 
 // @Summary Get post by slug
@@ -348,38 +653,31 @@ func (h *PostHandler) GetPostsByUserID(c *gin.Context) {
 // @Accept json
 // @Produce json
 // @Param slug path string true "Post Slug"
-// @Success 200 {object} models.Post
+// @Param render query string false "Set to html to include the content rendered as sanitized HTML"
+// @Success 200 {object} models.RenderedPost
+// @Failure 400 {object} map[string]string
 // @Failure 404 {object} map[string]string
 // @Failure 500 {object} map[string]string
 // @Router /posts/slug/{slug} [get]
 func (h *PostHandler) GetPostBySlug(c *gin.Context) {
+	render, ok := parseRender(c)
+	if !ok {
+		return
+	}
+
 	slug := c.Param("slug")
 
-	var post models.Post
-	if result := db.DB.Preload("Author").Preload("Categories").Preload("Comments.Author").Where("slug = ?", slug).First(&post); result.Error != nil {
+	post, err := h.posts.FindBySlugWithRelations(slug)
+	if err != nil {
 		c.JSON(http.StatusNotFound, gin.H{"error": "post not found"})
 		return
 	}
 
-	// // Check if the post is published or the user is the author or admin
-	// if post.Status != "published" {
-	// 	userID, exists := c.Get("userID")
-	// 	userRole, roleExists := c.Get("role")
-
-	// 	// If not authenticated or not the author or admin, return 404
-	// 	if !exists || !roleExists {
-	// 		c.JSON(http.StatusNotFound, gin.H{"error": "post not found"})
-	// 		return
-	// 	}
-
-	// 	authorID, idOk := userID.(uuid.UUID)
-	// 	roleStr, roleOk := userRole.(string)
-
-	// 	if !idOk || !roleOk || (post.AuthorID != authorID && roleStr != "admin") {
-	// 		c.JSON(http.StatusNotFound, gin.H{"error": "post not found"})
-	// 		return
-	// 	}
-	// }
+	// Only the author and reviewers see unpublished posts
+	if post.Status != workflow.Published && !auth.CanSeeUnpublished(c, post.AuthorID) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "post not found"})
+		return
+	}
 
 	// Clean up sensitive information
 	post.Author.Password = ""
@@ -388,9 +686,66 @@ func (h *PostHandler) GetPostBySlug(c *gin.Context) {
 		post.Comments[i].Author.Password = ""
 	}
 
+	writePost(c, post, render)
+}
+
+// parseRender reads the render query parameter, reporting whether the
+// rendered HTML was asked for. Invalid values get a 400 response and false.
+func parseRender(c *gin.Context) (bool, bool) {
+	switch c.Query("render") {
+	case "":
+		return false, true
+	case "html":
+		return true, true
+	}
+	c.JSON(http.StatusBadRequest, gin.H{"error": "render must be html"})
+	return false, false
+}
+
+// writePost responds with a post, along with its rendered HTML if asked for
+func writePost(c *gin.Context, post models.Post, render bool) {
+	if render {
+		c.JSON(http.StatusOK, models.RenderedPost{Post: post, ContentHTML: post.ContentHTML})
+		return
+	}
 	c.JSON(http.StatusOK, post)
 }
 
+// parseListContent reads the content query parameter of post listings,
+// reporting whether the full content of the posts was asked for. Invalid
+// values get a 400 response and false.
+func parseListContent(c *gin.Context) (bool, bool) {
+	switch c.Query("content") {
+	case "", "excerpt":
+		return false, true
+	case "full":
+		return true, true
+	}
+	c.JSON(http.StatusBadRequest, gin.H{"error": "content must be excerpt or full"})
+	return false, false
+}
+
+// trimContent leaves out the content of listed posts unless full content was
+// asked for; their excerpts stand in for it
+func trimContent(posts []models.Post, full bool) {
+	if full {
+		return
+	}
+	for i := range posts {
+		posts[i].Content = ""
+	}
+}
+
+// applyExcerpt sets the excerpt given in a request, if any. An empty one
+// goes back to the excerpt taken from the content.
+func applyExcerpt(post *models.Post, excerpt *string) {
+	if excerpt == nil {
+		return
+	}
+	post.Excerpt = strings.TrimSpace(*excerpt)
+	post.CustomExcerpt = post.Excerpt != ""
+}
+
 // @Summary Update post
 // @Description Update a post
 // @Tags posts
@@ -403,6 +758,7 @@ func (h *PostHandler) GetPostBySlug(c *gin.Context) {
 // @Failure 400 {object} map[string]string
 // @Failure 401 {object} map[string]string
 // @Failure 404 {object} map[string]string
+// @Failure 409 {object} map[string]string
 // @Failure 500 {object} map[string]string
 // @Router /posts/{id} [put]
 func (h *PostHandler) UpdatePost(c *gin.Context) {
@@ -415,25 +771,25 @@ func (h *PostHandler) UpdatePost(c *gin.Context) {
 		return
 	}
 
-	var post models.Post
-	if result := db.DB.Where("id = ?", postUUID).First(&post); result.Error != nil {
+	post, err := h.posts.FindByID(postUUID)
+	if err != nil {
 		c.JSON(http.StatusNotFound, gin.H{"error": "post not found"})
 		return
 	}
 
-	// Check if user is the author or admin
+	// Check if user is the author or can edit any post
 	userID, exists := c.Get("userID")
-	userRole, roleExists := c.Get("role")
-
-	if !exists || !roleExists {
+	if !exists {
 		c.JSON(http.StatusUnauthorized, gin.H{"error": "unauthorized"})
 		return
 	}
+	editorID, ok := userID.(uuid.UUID)
+	if !ok {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "invalid user ID format"})
+		return
+	}
 
-	authorID, idOk := userID.(uuid.UUID)
-	roleStr, roleOk := userRole.(string)
-
-	if !idOk || !roleOk || (post.AuthorID != authorID && roleStr != "admin") {
+	if !auth.CanModify(c, post.AuthorID, auth.PermPostsEditAny) {
 		c.JSON(http.StatusForbidden, gin.H{"error": "permission denied"})
 		return
 	}
@@ -444,6 +800,12 @@ func (h *PostHandler) UpdatePost(c *gin.Context) {
 		return
 	}
 
+	// Keep the version being replaced if it predates revision history
+	if err := recordBaseline(h.posts, h.revisions, postUUID); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to update post"})
+		return
+	}
+
 	// Update fields
 	if req.Title != "" {
 		post.Title = req.Title
@@ -453,6 +815,22 @@ func (h *PostHandler) UpdatePost(c *gin.Context) {
 		post.Content = req.Content
 	}
 
+	if req.ContentFormat != "" {
+		if !markup.IsValidFormat(req.ContentFormat) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": markup.ErrInvalidFormat.Error()})
+			return
+		}
+		post.ContentFormat = req.ContentFormat
+	}
+
+	applyExcerpt(&post, req.Excerpt)
+
+	tags, err := newTags(req.Tags)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
 	// Update slug if provided, otherwise generate from title
 	if req.Slug != "" {
 		post.Slug = generateSlug(req.Slug)
@@ -461,50 +839,56 @@ func (h *PostHandler) UpdatePost(c *gin.Context) {
 	}
 
 	// Check if slug already exists
-	var existingPost models.Post
-	if result := db.DB.Where("slug = ? AND id != ?", post.Slug, postUUID).First(&existingPost); result.RowsAffected > 0 {
+	if exists, _ := h.posts.SlugExists(post.Slug, postUUID); exists {
 		// Add a unique identifier to the slug
 		post.Slug = post.Slug + "-" + uuid.New().String()[:8]
 	}
 
-	// Update status if provided
-	if req.Status != "" && req.Status != post.Status {
-		post.Status = req.Status
-
-		// If changing to published, set published time
-		if req.Status == "published" && post.PublishedAt == nil {
-			now := time.Now()
-			post.PublishedAt = &now
+	// Update status if provided; a publish time alone reschedules the post
+	status := req.Status
+	if status == "" && req.PublishAt != nil {
+		status = workflow.Scheduled
+	}
+	from := post.Status
+	if status != "" && (status != post.Status || req.PublishAt != nil) {
+		if !changeStatus(c, &post, status, req.PublishAt) {
+			return
 		}
 	}
 
-	// Save the post
-	if result := db.DB.Save(&post); result.Error != nil {
+	// Save the post, and replace its tags if given, unless its status changed
+	// since it was loaded
+	var updated bool
+	if req.Tags != nil {
+		updated, err = h.posts.UpdateWithTagsIfStatus(&post, from, tags)
+	} else {
+		updated, err = h.posts.UpdateIfStatus(&post, from)
+	}
+	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to update post"})
 		return
 	}
+	if !updated {
+		c.JSON(http.StatusConflict, gin.H{"error": errStatusChanged})
+		return
+	}
+	if post.Status != from || req.PublishAt != nil {
+		recordTransition(h.transitions, post.ID, from, post.Status, editorID, "")
+	}
 
 	// Update categories if provided
 	if len(req.CategoryIDs) > 0 {
-		// Clear existing categories
-		db.DB.Model(&post).Association("Categories").Clear()
-
-		// Add new categories
-		for _, categoryID := range req.CategoryIDs {
-			// Check if category exists
-			var category models.Category
-			if result := db.DB.Where("id = ?", categoryID).First(&category); result.Error != nil {
-				// Skip invalid categories
-				continue
-			}
-
-			// Add association
-			db.DB.Model(&post).Association("Categories").Append(&category)
-		}
+		// Replace existing categories, skipping invalid ones
+		h.posts.SetCategories(&post, req.CategoryIDs)
 	}
 
 	// Load updated post with associations
-	db.DB.Preload("Author").Preload("Categories").Where("id = ?", postUUID).First(&post)
+	if updated, err := h.posts.FindByIDWithAuthor(postUUID); err == nil {
+		post = updated
+		if err := recordRevision(h.revisions, post, editorID, nil); err != nil {
+			log.Printf("Failed to record revision of post %s: %v", post.ID, err)
+		}
+	}
 
 	// Clean up sensitive information
 	post.Author.Password = ""
@@ -535,31 +919,25 @@ func (h *PostHandler) DeletePost(c *gin.Context) {
 		return
 	}
 
-	var post models.Post
-	if result := db.DB.Where("id = ?", postUUID).First(&post); result.Error != nil {
+	post, err := h.posts.FindByID(postUUID)
+	if err != nil {
 		c.JSON(http.StatusNotFound, gin.H{"error": "post not found"})
 		return
 	}
 
-	// Check if user is the author or admin
-	userID, exists := c.Get("userID")
-	userRole, roleExists := c.Get("role")
-
-	if !exists || !roleExists {
+	// Check if user is the author or can delete any post
+	if _, exists := c.Get("userID"); !exists {
 		c.JSON(http.StatusUnauthorized, gin.H{"error": "unauthorized"})
 		return
 	}
 
-	authorID, idOk := userID.(uuid.UUID)
-	roleStr, roleOk := userRole.(string)
-
-	if !idOk || !roleOk || (post.AuthorID != authorID && roleStr != "admin") {
+	if !auth.CanModify(c, post.AuthorID, auth.PermPostsDeleteAny) {
 		c.JSON(http.StatusForbidden, gin.H{"error": "permission denied"})
 		return
 	}
 
 	// Delete the post (this will use soft delete due to GORM's DeletedAt field)
-	if result := db.DB.Delete(&post); result.Error != nil {
+	if err := h.posts.Delete(&post); err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to delete post"})
 		return
 	}
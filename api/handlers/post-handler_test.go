@@ -0,0 +1,290 @@
+package handlers_test
+
+import (
+	"net/http"
+	"slices"
+	"testing"
+
+	"github.com/terkoizmy/go-blog-api/internal/auth"
+	"github.com/terkoizmy/go-blog-api/internal/db"
+	"github.com/terkoizmy/go-blog-api/internal/models"
+)
+
+// createPost creates a post through the API and returns it
+func (api *testAPI) createPost(t *testing.T, author testUser, req models.PostRequest) models.Post {
+	t.Helper()
+
+	res := api.do(t, http.MethodPost, "/api/v1/posts", author.token, req)
+	if res.Code != http.StatusCreated {
+		t.Fatalf("create post: got %d %s", res.Code, res.Body)
+	}
+	var post models.Post
+	decode(t, res, &post)
+	return post
+}
+
+func TestCreateAndGetPost(t *testing.T) {
+	api := newTestAPI(t)
+	editor := api.createUser(t, "editor", auth.RoleEditor)
+
+	post := api.createPost(t, editor, models.PostRequest{
+		Title:   "Hello World",
+		Content: "First paragraph.\n\nSecond *paragraph*.",
+		Status:  "published",
+		Tags:    []string{"Go", "go", "Testing"},
+	})
+	if post.Slug != "hello-world" {
+		t.Errorf("slug %q, want hello-world", post.Slug)
+	}
+	if post.Author.Password != "" {
+		t.Error("response includes the author's password hash")
+	}
+	if len(post.Tags) != 2 {
+		t.Errorf("got %d tags, want 2 after dropping the duplicate", len(post.Tags))
+	}
+
+	res := api.do(t, http.MethodGet, "/api/v1/posts/"+post.ID.String()+"?render=html", "", nil)
+	if res.Code != http.StatusOK {
+		t.Fatalf("get: got %d %s", res.Code, res.Body)
+	}
+	var rendered models.RenderedPost
+	decode(t, res, &rendered)
+	if rendered.ContentHTML != "<p>First paragraph.</p>\n\n<p>Second <em>paragraph</em>.</p>\n" {
+		t.Errorf("rendered %q", rendered.ContentHTML)
+	}
+
+	res = api.do(t, http.MethodGet, "/api/v1/posts/slug/hello-world", "", nil)
+	if res.Code != http.StatusOK {
+		t.Fatalf("get by slug: got %d %s", res.Code, res.Body)
+	}
+
+	res = api.do(t, http.MethodGet, "/api/v1/posts", "", nil)
+	if res.Code != http.StatusOK {
+		t.Fatalf("list: got %d %s", res.Code, res.Body)
+	}
+	var page struct {
+		Data  []models.Post `json:"data"`
+		Total int64         `json:"total"`
+	}
+	decode(t, res, &page)
+	if page.Total != 1 || len(page.Data) != 1 || page.Data[0].ID != post.ID {
+		t.Fatalf("list returned %+v", page)
+	}
+	if page.Data[0].Content != "" || page.Data[0].Excerpt != "First paragraph." {
+		t.Errorf("list entry has content %q and excerpt %q, want only the excerpt", page.Data[0].Content, page.Data[0].Excerpt)
+	}
+}
+
+func TestCreatePostValidation(t *testing.T) {
+	api := newTestAPI(t)
+	author := api.createUser(t, "author", auth.RoleAuthor)
+
+	tests := []struct {
+		name  string
+		token string
+		body  interface{}
+		want  int
+	}{
+		{"no token", "", models.PostRequest{Title: "T", Content: "C"}, http.StatusUnauthorized},
+		{"no title", author.token, map[string]string{"content": "C"}, http.StatusBadRequest},
+		{"unknown format", author.token, models.PostRequest{Title: "T", Content: "C", ContentFormat: "rtf"}, http.StatusBadRequest},
+		{"unknown status", author.token, models.PostRequest{Title: "T", Content: "C", Status: "live"}, http.StatusBadRequest},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			res := api.do(t, http.MethodPost, "/api/v1/posts", tt.token, tt.body)
+			if res.Code != tt.want {
+				t.Fatalf("got %d %s, want %d", res.Code, res.Body, tt.want)
+			}
+		})
+	}
+}
+
+func TestDraftsAreHidden(t *testing.T) {
+	api := newTestAPI(t)
+	author := api.createUser(t, "author", auth.RoleAuthor)
+
+	draft := api.createPost(t, author, models.PostRequest{Title: "Draft", Content: "Not yet"})
+	if draft.Status != "draft" {
+		t.Fatalf("status %q, want draft", draft.Status)
+	}
+
+	res := api.do(t, http.MethodGet, "/api/v1/posts/"+draft.ID.String(), "", nil)
+	if res.Code != http.StatusNotFound {
+		t.Errorf("get draft: got %d, want 404", res.Code)
+	}
+
+	res = api.do(t, http.MethodGet, "/api/v1/posts/own", author.token, nil)
+	if res.Code != http.StatusOK {
+		t.Fatalf("own posts: got %d %s", res.Code, res.Body)
+	}
+	var own struct {
+		Data []models.Post `json:"data"`
+	}
+	decode(t, res, &own)
+	if len(own.Data) != 1 || own.Data[0].ID != draft.ID {
+		t.Errorf("own posts %+v, want the draft", own.Data)
+	}
+}
+
+func TestUpdateAndDeletePostPermissions(t *testing.T) {
+	api := newTestAPI(t)
+	author := api.createUser(t, "author", auth.RoleAuthor)
+	other := api.createUser(t, "other", auth.RoleAuthor)
+	editor := api.createUser(t, "editor", auth.RoleEditor)
+
+	post := api.createPost(t, author, models.PostRequest{Title: "Mine", Content: "Original"})
+	path := "/api/v1/posts/" + post.ID.String()
+
+	res := api.do(t, http.MethodPut, path, other.token, models.PostRequest{Title: "Theirs", Content: "Changed"})
+	if res.Code != http.StatusForbidden {
+		t.Errorf("update by another author: got %d, want 403", res.Code)
+	}
+
+	res = api.do(t, http.MethodPut, path, editor.token, models.PostRequest{Title: "Edited", Content: "Changed"})
+	if res.Code != http.StatusOK {
+		t.Fatalf("update by editor: got %d %s", res.Code, res.Body)
+	}
+	var updated models.Post
+	decode(t, res, &updated)
+	if updated.Title != "Edited" || updated.AuthorID != author.user.ID {
+		t.Errorf("updated to %q by %s, want Edited by the original author", updated.Title, updated.AuthorID)
+	}
+
+	res = api.do(t, http.MethodDelete, path, other.token, nil)
+	if res.Code != http.StatusForbidden {
+		t.Errorf("delete by another author: got %d, want 403", res.Code)
+	}
+
+	res = api.do(t, http.MethodDelete, path, author.token, nil)
+	if res.Code != http.StatusOK {
+		t.Fatalf("delete by author: got %d %s", res.Code, res.Body)
+	}
+	if _, err := api.repos.Posts.FindByID(post.ID); err == nil {
+		t.Error("deleted post can still be found")
+	}
+}
+
+func TestUnpublishedPostsVisibility(t *testing.T) {
+	api := newTestAPI(t)
+	author := api.createUser(t, "author", auth.RoleAuthor)
+	other := api.createUser(t, "other", auth.RoleUser)
+	editor := api.createUser(t, "editor", auth.RoleEditor)
+
+	draft := api.createPost(t, author, models.PostRequest{Title: "Secret plans", Content: "Not yet"})
+
+	for _, tt := range []struct {
+		name string
+		user testUser
+		want int
+	}{
+		{"anonymous", testUser{}, http.StatusNotFound},
+		{"another user", other, http.StatusNotFound},
+		{"author", author, http.StatusOK},
+		{"editor", editor, http.StatusOK},
+	} {
+		t.Run("slug as "+tt.name, func(t *testing.T) {
+			res := api.do(t, http.MethodGet, "/api/v1/posts/slug/"+draft.Slug, tt.user.token, nil)
+			if res.Code != tt.want {
+				t.Fatalf("got %d %s, want %d", res.Code, res.Body, tt.want)
+			}
+		})
+	}
+
+	for _, tt := range []struct {
+		name  string
+		user  testUser
+		query string
+		want  int
+	}{
+		{"anonymous", testUser{}, "?status=draft", http.StatusUnauthorized},
+		{"another user", other, "?status=draft", http.StatusForbidden},
+		{"another user for the author", other, "?status=draft&author=author", http.StatusForbidden},
+		{"author for themselves", author, "?status=draft&author=author", http.StatusOK},
+		{"author for everyone", author, "?status=draft", http.StatusForbidden},
+		{"editor", editor, "?status=draft", http.StatusOK},
+	} {
+		t.Run("list as "+tt.name, func(t *testing.T) {
+			res := api.do(t, http.MethodGet, "/api/v1/posts"+tt.query, tt.user.token, nil)
+			if res.Code != tt.want {
+				t.Fatalf("got %d %s, want %d", res.Code, res.Body, tt.want)
+			}
+			if res.Code != http.StatusOK {
+				return
+			}
+
+			var page struct {
+				Data []models.Post `json:"data"`
+			}
+			decode(t, res, &page)
+			if len(page.Data) != 1 || page.Data[0].ID != draft.ID {
+				t.Errorf("listed %+v, want the draft", page.Data)
+			}
+		})
+	}
+}
+
+func TestCreatePostFailsWithoutTags(t *testing.T) {
+	api := newTestAPI(t)
+	author := api.createUser(t, "author", auth.RoleAuthor)
+
+	// Linking the tags fails after they have been created
+	if err := db.DB.Exec("DROP TABLE post_tags").Error; err != nil {
+		t.Fatal(err)
+	}
+
+	res := api.do(t, http.MethodPost, "/api/v1/posts", author.token, models.PostRequest{Title: "Tagged", Content: "C", Tags: []string{"Go"}})
+	if res.Code != http.StatusInternalServerError {
+		t.Fatalf("got %d %s, want 500", res.Code, res.Body)
+	}
+
+	var count int64
+	if err := db.DB.Model(&models.Post{}).Count(&count).Error; err != nil {
+		t.Fatal(err)
+	}
+	if count != 0 {
+		t.Errorf("%d posts were created without their tags", count)
+	}
+	if err := db.DB.Model(&models.Tag{}).Count(&count).Error; err != nil {
+		t.Fatal(err)
+	}
+	if count != 0 {
+		t.Errorf("%d tags were created without their post", count)
+	}
+}
+
+func TestUpdatePostTags(t *testing.T) {
+	api := newTestAPI(t)
+	author := api.createUser(t, "author", auth.RoleAuthor)
+
+	post := api.createPost(t, author, models.PostRequest{Title: "Tagged", Content: "C", Tags: []string{"Go"}})
+	path := "/api/v1/posts/" + post.ID.String()
+
+	for _, tt := range []struct {
+		name string
+		tags []string
+		want []string
+	}{
+		{"replace", []string{"Testing", "Go"}, []string{"Go", "Testing"}},
+		{"keep", nil, []string{"Go", "Testing"}},
+		{"remove", []string{}, nil},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			res := api.do(t, http.MethodPut, path, author.token, models.PostRequest{Title: "Tagged", Content: "C", Tags: tt.tags})
+			if res.Code != http.StatusOK {
+				t.Fatalf("got %d %s", res.Code, res.Body)
+			}
+			var updated models.Post
+			decode(t, res, &updated)
+
+			var got []string
+			for _, tag := range updated.Tags {
+				got = append(got, tag.Name)
+			}
+			slices.Sort(got)
+			if !slices.Equal(got, tt.want) {
+				t.Errorf("tags %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,344 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/terkoizmy/go-blog-api/internal/auth"
+	"github.com/terkoizmy/go-blog-api/internal/diff"
+	"github.com/terkoizmy/go-blog-api/internal/models"
+	"github.com/terkoizmy/go-blog-api/internal/repository"
+)
+
+// RevisionHandler handles post revision routes
+type RevisionHandler struct {
+	posts     repository.PostRepository
+	revisions repository.PostRevisionRepository
+}
+
+func NewRevisionHandler(posts repository.PostRepository, revisions repository.PostRevisionRepository) *RevisionHandler {
+	return &RevisionHandler{posts: posts, revisions: revisions}
+}
+
+// @Summary List post revisions
+// @Description List the revisions of a post, newest first. Only the author and editors can see them.
+// @Tags revisions
+// @Produce json
+// @Security BearerAuth
+// @Param id path string true "Post ID"
+// @Param page query int false "Page number"
+// @Param limit query int false "Items per page"
+// @Success 200 {object} models.PaginatedResponse{data=[]models.PostRevision}
+// @Failure 400 {object} map[string]string
+// @Failure 401 {object} map[string]string
+// @Failure 403 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Router /posts/{id}/revisions [get]
+func (h *RevisionHandler) ListRevisions(c *gin.Context) {
+	post, ok := h.loadPost(c)
+	if !ok {
+		return
+	}
+
+	p, ok := parsePagination(c)
+	if !ok {
+		return
+	}
+
+	revisions, total, err := h.revisions.ListByPost(post.ID, p.Repository())
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to get revisions"})
+		return
+	}
+
+	for i := range revisions {
+		revisions[i].Author.Password = ""
+	}
+
+	writePage(c, revisions, p, total)
+}
+
+// @Summary Get post revision
+// @Description Get one revision of a post
+// @Tags revisions
+// @Produce json
+// @Security BearerAuth
+// @Param id path string true "Post ID"
+// @Param number path int true "Revision number"
+// @Success 200 {object} models.PostRevision
+// @Failure 400 {object} map[string]string
+// @Failure 401 {object} map[string]string
+// @Failure 403 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Router /posts/{id}/revisions/{number} [get]
+func (h *RevisionHandler) GetRevision(c *gin.Context) {
+	post, ok := h.loadPost(c)
+	if !ok {
+		return
+	}
+
+	revision, ok := h.loadRevision(c, post.ID, c.Param("number"))
+	if !ok {
+		return
+	}
+
+	revision.Author.Password = ""
+	c.JSON(http.StatusOK, revision)
+}
+
+// @Summary Diff post revisions
+// @Description Unified diff of the title, categories and content between two revisions of a post
+// @Tags revisions
+// @Produce json
+// @Security BearerAuth
+// @Param id path string true "Post ID"
+// @Param number path int true "Revision number to compare"
+// @Param from query int false "Revision number to compare against, the previous one by default; 0 compares against an empty post"
+// @Success 200 {object} models.PostRevisionDiff
+// @Failure 400 {object} map[string]string
+// @Failure 401 {object} map[string]string
+// @Failure 403 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Failure 413 {object} map[string]string
+// @Failure 422 {object} map[string]string
+// @Router /posts/{id}/revisions/{number}/diff [get]
+func (h *RevisionHandler) DiffRevisions(c *gin.Context) {
+	post, ok := h.loadPost(c)
+	if !ok {
+		return
+	}
+
+	to, ok := h.loadRevision(c, post.ID, c.Param("number"))
+	if !ok {
+		return
+	}
+
+	from := models.PostRevision{Number: to.Number - 1}
+	if value, exists := c.GetQuery("from"); exists {
+		number, err := strconv.Atoi(value)
+		if err != nil || number < 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "from must be a revision number"})
+			return
+		}
+		from.Number = number
+	}
+	if from.Number > 0 {
+		if from, ok = h.loadRevision(c, post.ID, strconv.Itoa(from.Number)); !ok {
+			return
+		}
+	}
+
+	unified, err := diff.Unified(
+		fmt.Sprintf("revision %d", from.Number),
+		fmt.Sprintf("revision %d", to.Number),
+		revisionText(from), revisionText(to),
+	)
+	switch {
+	case errors.Is(err, diff.ErrTooLarge):
+		c.JSON(http.StatusRequestEntityTooLarge, gin.H{"error": err.Error()})
+		return
+	case errors.Is(err, diff.ErrTooManyChanges):
+		c.JSON(http.StatusUnprocessableEntity, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, models.PostRevisionDiff{
+		PostID: post.ID,
+		From:   from.Number,
+		To:     to.Number,
+		Diff:   unified,
+	})
+}
+
+// @Summary Restore post revision
+// @Description Restore the title, content and categories of an old revision. The restored version is recorded as a new revision.
+// @Tags revisions
+// @Produce json
+// @Security BearerAuth
+// @Param id path string true "Post ID"
+// @Param number path int true "Revision number to restore"
+// @Success 200 {object} models.Post
+// @Failure 400 {object} map[string]string
+// @Failure 401 {object} map[string]string
+// @Failure 403 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Failure 409 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Router /posts/{id}/revisions/{number}/restore [post]
+func (h *RevisionHandler) RestoreRevision(c *gin.Context) {
+	post, ok := h.loadPost(c)
+	if !ok {
+		return
+	}
+
+	revision, ok := h.loadRevision(c, post.ID, c.Param("number"))
+	if !ok {
+		return
+	}
+
+	userID, ok := c.MustGet("userID").(uuid.UUID)
+	if !ok {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "invalid user ID format"})
+		return
+	}
+
+	post.Title = revision.Title
+	post.Content = revision.Content
+	if revision.ContentFormat != "" {
+		post.ContentFormat = revision.ContentFormat
+	}
+	updated, err := h.posts.UpdateIfStatus(&post, post.Status)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to restore revision"})
+		return
+	}
+	if !updated {
+		c.JSON(http.StatusConflict, gin.H{"error": errStatusChanged})
+		return
+	}
+
+	categoryIDs := make([]uuid.UUID, len(revision.Categories))
+	for i, category := range revision.Categories {
+		categoryIDs[i] = category.ID
+	}
+	// Categories deleted since are skipped
+	if err := h.posts.SetCategories(&post, categoryIDs); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to restore revision"})
+		return
+	}
+
+	restored, err := h.posts.FindByIDWithAuthor(post.ID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to restore revision"})
+		return
+	}
+
+	if err := recordRevision(h.revisions, restored, userID, &revision.Number); err != nil {
+		log.Printf("Failed to record revision of post %s: %v", post.ID, err)
+	}
+
+	restored.Author.Password = ""
+	restored.Author.Role = ""
+	c.JSON(http.StatusOK, restored)
+}
+
+// loadPost loads the post of the request and checks the user may see its
+// revisions, responding with an error if not
+func (h *RevisionHandler) loadPost(c *gin.Context) (models.Post, bool) {
+	postID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid post ID format"})
+		return models.Post{}, false
+	}
+
+	post, err := h.posts.FindByID(postID)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "post not found"})
+		return models.Post{}, false
+	}
+
+	if !auth.CanModify(c, post.AuthorID, auth.PermPostsEditAny) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "permission denied"})
+		return models.Post{}, false
+	}
+
+	return post, true
+}
+
+func (h *RevisionHandler) loadRevision(c *gin.Context, postID uuid.UUID, number string) (models.PostRevision, bool) {
+	n, err := strconv.Atoi(number)
+	if err != nil || n < 1 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid revision number"})
+		return models.PostRevision{}, false
+	}
+
+	revision, err := h.revisions.FindByNumber(postID, n)
+	if errors.Is(err, repository.ErrNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": fmt.Sprintf("revision %d not found", n)})
+		return models.PostRevision{}, false
+	} else if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to get revision"})
+		return models.PostRevision{}, false
+	}
+
+	return revision, true
+}
+
+// recordRevision stores the post's title, content and categories as its next
+// revision, unless nothing changed since the latest one. post must have its
+// categories loaded. restoredFrom is the number of the revision restored, if
+// any.
+func recordRevision(revisions repository.PostRevisionRepository, post models.Post, authorID uuid.UUID, restoredFrom *int) error {
+	revision := newRevision(post, authorID)
+	revision.RestoredFrom = restoredFrom
+
+	latest, err := revisions.Latest(post.ID)
+	if err != nil && !errors.Is(err, repository.ErrNotFound) {
+		return err
+	}
+	if err == nil && restoredFrom == nil && revisionText(latest) == revisionText(revision) {
+		return nil
+	}
+
+	return revisions.Create(&revision)
+}
+
+// recordBaseline stores the current version of a post from before revisions
+// were kept, so that its first edit doesn't lose it
+func recordBaseline(posts repository.PostRepository, revisions repository.PostRevisionRepository, postID uuid.UUID) error {
+	if _, err := revisions.Latest(postID); !errors.Is(err, repository.ErrNotFound) {
+		return err
+	}
+
+	post, err := posts.FindByIDWithAuthor(postID)
+	if err != nil {
+		return err
+	}
+
+	revision := newRevision(post, post.AuthorID)
+	revision.CreatedAt = post.UpdatedAt
+	return revisions.Create(&revision)
+}
+
+func newRevision(post models.Post, authorID uuid.UUID) models.PostRevision {
+	categories := make([]models.RevisionCategory, len(post.Categories))
+	for i, category := range post.Categories {
+		categories[i] = models.RevisionCategory{ID: category.ID, Name: category.Name, Slug: category.Slug}
+	}
+	sort.Slice(categories, func(i, j int) bool { return categories[i].Name < categories[j].Name })
+
+	return models.PostRevision{
+		PostID:        post.ID,
+		AuthorID:      authorID,
+		Title:         post.Title,
+		Content:       post.Content,
+		Categories:    categories,
+		ContentFormat: post.ContentFormat,
+	}
+}
+
+// revisionText is what diffs compare. The zero revision is an empty post.
+func revisionText(revision models.PostRevision) string {
+	if revision.Number == 0 && revision.PostID == uuid.Nil {
+		return ""
+	}
+
+	names := make([]string, len(revision.Categories))
+	for i, category := range revision.Categories {
+		names[i] = category.Name
+	}
+
+	return "Title: " + revision.Title + "\n" +
+		"Categories: " + strings.Join(names, ", ") + "\n" +
+		"Format: " + revision.ContentFormat + "\n" +
+		"\n" +
+		revision.Content
+}
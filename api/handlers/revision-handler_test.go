@@ -0,0 +1,50 @@
+package handlers_test
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/terkoizmy/go-blog-api/internal/auth"
+	"github.com/terkoizmy/go-blog-api/internal/models"
+)
+
+func TestRestoreRevisionKeepsFormat(t *testing.T) {
+	api := newTestAPI(t)
+	author := api.createUser(t, "author", auth.RoleAuthor)
+
+	post := api.createPost(t, author, models.PostRequest{Title: "Notes", Content: "Plain *stars*", ContentFormat: "plain"})
+	path := "/api/v1/posts/" + post.ID.String()
+
+	res := api.do(t, http.MethodPut, path, author.token, models.PostRequest{Title: "Notes", Content: "Now in *Markdown*", ContentFormat: "markdown"})
+	if res.Code != http.StatusOK {
+		t.Fatalf("update: got %d %s", res.Code, res.Body)
+	}
+
+	res = api.do(t, http.MethodGet, path+"/revisions/2/diff", author.token, nil)
+	if res.Code != http.StatusOK {
+		t.Fatalf("diff: got %d %s", res.Code, res.Body)
+	}
+	var diff models.PostRevisionDiff
+	decode(t, res, &diff)
+	if want := "-Format: plain\n+Format: markdown\n"; !strings.Contains(diff.Diff, want) {
+		t.Errorf("diff doesn't show the format change:\n%s", diff.Diff)
+	}
+
+	res = api.do(t, http.MethodPost, path+"/revisions/1/restore", author.token, nil)
+	if res.Code != http.StatusOK {
+		t.Fatalf("restore: got %d %s", res.Code, res.Body)
+	}
+	var restored models.Post
+	decode(t, res, &restored)
+	if restored.ContentFormat != "plain" || restored.Content != "Plain *stars*" {
+		t.Fatalf("restored %s content %q", restored.ContentFormat, restored.Content)
+	}
+
+	res = api.do(t, http.MethodGet, path+"?render=html", author.token, nil)
+	var rendered models.RenderedPost
+	decode(t, res, &rendered)
+	if rendered.ContentHTML != "<p>Plain *stars*</p>\n" {
+		t.Errorf("rendered %q", rendered.ContentHTML)
+	}
+}
@@ -0,0 +1,107 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/terkoizmy/go-blog-api/internal/repository"
+)
+
+// maxSearchQueryLength bounds the search query, in bytes
+const maxSearchQueryLength = 200
+
+type SearchHandler struct {
+	search     repository.SearchRepository
+	categories repository.CategoryRepository
+	users      repository.UserRepository
+}
+
+func NewSearchHandler(search repository.SearchRepository, categories repository.CategoryRepository, users repository.UserRepository) *SearchHandler {
+	return &SearchHandler{search: search, categories: categories, users: users}
+}
+
+// @Summary Search posts and comments
+// @Description Full-text search over published posts and their comments, best match first. Matches in the snippets are wrapped in <mark> tags.
+// @Tags search
+// @Produce json
+// @Param q query string true "Search query"
+// @Param type query string false "posts, comments or all (default)"
+// @Param category query string false "Category ID or slug"
+// @Param author query string false "Author ID or username"
+// @Param from query string false "Only results from this date on (YYYY-MM-DD or RFC 3339)"
+// @Param to query string false "Only results up to this date (YYYY-MM-DD or RFC 3339)"
+// @Param page query int false "Page number"
+// @Param limit query int false "Items per page"
+// @Success 200 {object} models.PaginatedResponse{data=[]models.SearchResult}
+// @Failure 400 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Router /search [get]
+func (h *SearchHandler) Search(c *gin.Context) {
+	query := c.Query("q")
+	if query == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "q is required"})
+		return
+	}
+	if len(query) > maxSearchQueryLength {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "q is too long"})
+		return
+	}
+
+	filter := repository.SearchFilter{Query: query}
+
+	switch resultType := c.Query("type"); resultType {
+	case "", "all":
+	case repository.SearchPosts, repository.SearchComments:
+		filter.Type = resultType
+	default:
+		c.JSON(http.StatusBadRequest, gin.H{"error": "type must be posts, comments or all"})
+		return
+	}
+
+	if category := c.Query("category"); category != "" {
+		id, err := resolveCategory(h.categories, category)
+		if errors.Is(err, repository.ErrNotFound) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "category not found"})
+			return
+		} else if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to search"})
+			return
+		}
+		filter.CategoryID = id
+	}
+
+	if author := c.Query("author"); author != "" {
+		id, err := resolveAuthor(h.users, author)
+		if errors.Is(err, repository.ErrNotFound) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "author not found"})
+			return
+		} else if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to search"})
+			return
+		}
+		filter.AuthorID = id
+	}
+
+	from, to, ok := parseDateRange(c)
+	if !ok {
+		return
+	}
+	filter.From = from
+	filter.To = to
+
+	p, ok := parsePagination(c)
+	if !ok {
+		return
+	}
+	filter.Offset = p.Offset()
+	filter.Limit = p.Limit
+
+	results, total, err := h.search.Search(filter)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to search"})
+		return
+	}
+
+	writePage(c, results, p, total)
+}
@@ -0,0 +1,262 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/terkoizmy/go-blog-api/internal/auth"
+	"github.com/terkoizmy/go-blog-api/internal/models"
+	"github.com/terkoizmy/go-blog-api/internal/repository"
+)
+
+type TagHandler struct {
+	tags  repository.TagRepository
+	posts repository.PostRepository
+}
+
+func NewTagHandler(tags repository.TagRepository, posts repository.PostRepository) *TagHandler {
+	return &TagHandler{tags: tags, posts: posts}
+}
+
+// @Summary Get all tags
+// @Description Get all tags with the number of published posts using them, most used first
+// @Tags tags
+// @Produce json
+// @Param page query int false "Page number"
+// @Param limit query int false "Items per page"
+// @Success 200 {object} models.PaginatedResponse{data=[]models.TagUsage}
+// @Failure 400 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Router /tags [get]
+func (h *TagHandler) GetAllTags(c *gin.Context) {
+	p, ok := parsePagination(c)
+	if !ok {
+		return
+	}
+
+	tags, total, err := h.tags.List(p.Repository())
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to get tags"})
+		return
+	}
+
+	writePage(c, tags, p, total)
+}
+
+// @Summary Get posts by tag
+// @Description Get the published posts with a tag
+// @Tags tags
+// @Produce json
+// @Param slug path string true "Tag slug"
+// @Param page query int false "Page number"
+// @Param limit query int false "Items per page"
+// @Param cursor query string false "Cursor from next_cursor or prev_cursor, empty for the first page. Switches to cursor pagination ordered by publication date."
+// @Param content query string false "excerpt (default) leaves out the content of each post, full includes it"
+// @Success 200 {object} models.PaginatedResponse{data=[]models.Post} "models.CursorResponse when cursor is given"
+// @Failure 400 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Router /tags/{slug}/posts [get]
+func (h *TagHandler) GetPostsByTag(c *gin.Context) {
+	full, ok := parseListContent(c)
+	if !ok {
+		return
+	}
+
+	tag, err := h.tags.FindBySlug(c.Param("slug"))
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "tag not found"})
+		return
+	}
+
+	if usesCursor(c) {
+		page, ok := parseCursorPage(c)
+		if !ok {
+			return
+		}
+
+		posts, cursors, err := h.posts.FeedByTag(tag.ID, repository.PostFilter{Status: "published"}, page)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to get posts"})
+			return
+		}
+
+		for i := range posts {
+			posts[i].Author.Password = ""
+		}
+
+		trimContent(posts, full)
+		writeCursorPage(c, posts, page, cursors)
+		return
+	}
+
+	p, ok := parsePagination(c)
+	if !ok {
+		return
+	}
+
+	posts, total, err := h.posts.ListByTag(tag.ID, repository.PostFilter{
+		Status: "published",
+		Offset: p.Offset(),
+		Limit:  p.Limit,
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to get posts"})
+		return
+	}
+
+	for i := range posts {
+		posts[i].Author.Password = ""
+	}
+
+	trimContent(posts, full)
+	writePage(c, posts, p, total)
+}
+
+// @Summary Rename tag
+// @Description Rename a tag, which also changes its slug. Renaming onto an existing tag fails; merge into it instead.
+// @Tags tags
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param slug path string true "Tag slug"
+// @Param tag body models.TagRequest true "New name"
+// @Success 200 {object} models.Tag
+// @Failure 400 {object} map[string]string
+// @Failure 401 {object} map[string]string
+// @Failure 403 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Failure 409 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Router /tags/{slug} [put]
+func (h *TagHandler) RenameTag(c *gin.Context) {
+	if !auth.Can(c, auth.PermTagsManage) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "permission denied"})
+		return
+	}
+
+	tag, err := h.tags.FindBySlug(c.Param("slug"))
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "tag not found"})
+		return
+	}
+
+	var req models.TagRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	renamed, err := newTag(req.Name)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	exists, err := h.tags.SlugExists(renamed.Slug, tag.ID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to rename tag"})
+		return
+	}
+	if exists {
+		c.JSON(http.StatusConflict, gin.H{"error": fmt.Sprintf("tag %q already exists, merge into it instead", renamed.Slug)})
+		return
+	}
+
+	tag.Name = renamed.Name
+	tag.Slug = renamed.Slug
+	if err := h.tags.Update(&tag); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to rename tag"})
+		return
+	}
+
+	c.JSON(http.StatusOK, tag)
+}
+
+// @Summary Merge tags
+// @Description Move every post of a tag to another tag and delete it
+// @Tags tags
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param slug path string true "Slug of the tag to merge and delete"
+// @Param merge body models.TagMergeRequest true "Tag to merge into"
+// @Success 200 {object} models.Tag
+// @Failure 400 {object} map[string]string
+// @Failure 401 {object} map[string]string
+// @Failure 403 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Router /tags/{slug}/merge [post]
+func (h *TagHandler) MergeTag(c *gin.Context) {
+	if !auth.Can(c, auth.PermTagsManage) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "permission denied"})
+		return
+	}
+
+	from, err := h.tags.FindBySlug(c.Param("slug"))
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "tag not found"})
+		return
+	}
+
+	var req models.TagMergeRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	into, err := h.tags.FindBySlug(req.Into)
+	if errors.Is(err, repository.ErrNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": fmt.Sprintf("tag %q not found", req.Into)})
+		return
+	} else if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to merge tags"})
+		return
+	}
+	if into.ID == from.ID {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "can't merge a tag into itself"})
+		return
+	}
+
+	if err := h.tags.Merge(&from, &into); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to merge tags"})
+		return
+	}
+
+	c.JSON(http.StatusOK, into)
+}
+
+// newTag normalizes a tag name and derives its slug
+func newTag(name string) (models.Tag, error) {
+	name = strings.Join(strings.Fields(name), " ")
+	slug := generateSlug(name)
+	if slug == "" {
+		return models.Tag{}, fmt.Errorf("tag %q needs a letter or digit", name)
+	}
+	return models.Tag{Name: name, Slug: slug}, nil
+}
+
+// newTags normalizes the tag names of a request, dropping empty and
+// duplicate ones
+func newTags(names []string) ([]models.Tag, error) {
+	tags := make([]models.Tag, 0, len(names))
+	seen := make(map[string]bool)
+	for _, name := range names {
+		if strings.TrimSpace(name) == "" {
+			continue
+		}
+		tag, err := newTag(name)
+		if err != nil {
+			return nil, err
+		}
+		if !seen[tag.Slug] {
+			seen[tag.Slug] = true
+			tags = append(tags, tag)
+		}
+	}
+	return tags, nil
+}
@@ -0,0 +1,252 @@
+package handlers
+
+import (
+	"errors"
+	"log"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/terkoizmy/go-blog-api/config"
+	"github.com/terkoizmy/go-blog-api/internal/auth"
+	"github.com/terkoizmy/go-blog-api/internal/models"
+)
+
+// @Summary Complete two-factor login
+// @Description Exchange the challenge token from /login and a TOTP or recovery code for an access token
+// @Tags two-factor
+// @Accept json
+// @Produce json
+// @Param request body models.TwoFactorLoginRequest true "Challenge token and code"
+// @Success 200 {object} models.TokenResponse
+// @Failure 400 {object} map[string]string
+// @Failure 401 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Router /login/2fa [post]
+func (h *UserHandler) LoginTwoFactor(c *gin.Context) {
+	var req models.TwoFactorLoginRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	claims, err := auth.ValidateChallengeToken(req.ChallengeToken)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "invalid or expired challenge token"})
+		return
+	}
+
+	user, err := h.users.FindByID(claims.UserID)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "invalid credentials"})
+		return
+	}
+
+	if !user.TwoFactorEnabled {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "invalid or expired challenge token"})
+		return
+	}
+
+	cfg, err := config.LoadConfig()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "couldn't load config"})
+		return
+	}
+	ip := c.ClientIP()
+
+	// Wrong second-factor codes count towards the same lockout as passwords
+	if !checkLoginAllowed(c, &user.ID, ip) {
+		return
+	}
+
+	// A challenge completes a single login
+	if err := auth.CompleteTwoFactorLogin(claims, user, req.Code); err != nil {
+		if errors.Is(err, auth.ErrChallengeUsed) {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "invalid or expired challenge token"})
+			return
+		}
+		if errors.Is(err, auth.ErrInvalidTwoFactorCode) {
+			if err := auth.RecordFailedLogin(&user.ID, user.Username, ip, auth.LockoutPolicyFromConfig(cfg)); err != nil {
+				log.Printf("Failed to record login attempt: %v", err)
+			}
+		}
+		writeTwoFactorError(c, err)
+		return
+	}
+
+	if err := auth.RecordSuccessfulLogin(user.ID, user.Username, ip); err != nil {
+		log.Printf("Failed to record login attempt: %v", err)
+	}
+
+	respondWithTokens(c, user)
+}
+
+// @Summary Start two-factor enrollment
+// @Description Generate a TOTP secret and otpauth URI for the current user. Two-factor authentication is enabled once a code is confirmed.
+// @Tags two-factor
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Success 200 {object} models.TwoFactorEnrollResponse
+// @Failure 400 {object} map[string]string
+// @Failure 401 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Router /users/me/2fa/enroll [post]
+func (h *UserHandler) EnrollTwoFactor(c *gin.Context) {
+	user, ok := h.currentUser(c)
+	if !ok {
+		return
+	}
+
+	cfg, err := config.LoadConfig()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "couldn't load config"})
+		return
+	}
+
+	secret, uri, err := auth.EnrollTOTP(user, cfg.TOTPIssuer)
+	if err != nil {
+		writeTwoFactorError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, models.TwoFactorEnrollResponse{
+		Secret:     secret,
+		OTPAuthURI: uri,
+	})
+}
+
+// @Summary Enable two-factor authentication
+// @Description Confirm enrollment with a code from the authenticator app. Returns recovery codes, which are only shown once.
+// @Tags two-factor
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param request body models.TwoFactorCodeRequest true "TOTP code"
+// @Success 200 {object} models.RecoveryCodesResponse
+// @Failure 400 {object} map[string]string
+// @Failure 401 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Router /users/me/2fa/verify [post]
+func (h *UserHandler) EnableTwoFactor(c *gin.Context) {
+	var req models.TwoFactorCodeRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	user, ok := h.currentUser(c)
+	if !ok {
+		return
+	}
+
+	codes, err := auth.EnableTOTP(user, req.Code)
+	if err != nil {
+		writeTwoFactorError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, models.RecoveryCodesResponse{RecoveryCodes: codes})
+}
+
+// @Summary Disable two-factor authentication
+// @Description Turn off two-factor authentication using a TOTP or recovery code
+// @Tags two-factor
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param request body models.TwoFactorCodeRequest true "TOTP or recovery code"
+// @Success 200 {object} map[string]string
+// @Failure 400 {object} map[string]string
+// @Failure 401 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Router /users/me/2fa/disable [post]
+func (h *UserHandler) DisableTwoFactor(c *gin.Context) {
+	var req models.TwoFactorCodeRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	user, ok := h.currentUser(c)
+	if !ok {
+		return
+	}
+
+	if err := auth.DisableTOTP(user, req.Code); err != nil {
+		writeTwoFactorError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "two-factor authentication disabled"})
+}
+
+// @Summary Regenerate recovery codes
+// @Description Replace all recovery codes using a TOTP or recovery code
+// @Tags two-factor
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param request body models.TwoFactorCodeRequest true "TOTP or recovery code"
+// @Success 200 {object} models.RecoveryCodesResponse
+// @Failure 400 {object} map[string]string
+// @Failure 401 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Router /users/me/2fa/recovery-codes [post]
+func (h *UserHandler) RegenerateRecoveryCodes(c *gin.Context) {
+	var req models.TwoFactorCodeRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	user, ok := h.currentUser(c)
+	if !ok {
+		return
+	}
+
+	codes, err := auth.RegenerateRecoveryCodes(user, req.Code)
+	if err != nil {
+		writeTwoFactorError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, models.RecoveryCodesResponse{RecoveryCodes: codes})
+}
+
+// currentUser loads the authenticated user, writing an error response if it
+// can't
+func (h *UserHandler) currentUser(c *gin.Context) (models.User, bool) {
+	userID, exists := c.Get("userID")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "unauthorized"})
+		return models.User{}, false
+	}
+
+	id, ok := userID.(uuid.UUID)
+	if !ok {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "invalid user ID format"})
+		return models.User{}, false
+	}
+
+	user, err := h.users.FindByID(id)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "unauthorized"})
+		return models.User{}, false
+	}
+
+	return user, true
+}
+
+func writeTwoFactorError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, auth.ErrInvalidTwoFactorCode):
+		c.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+	case errors.Is(err, auth.ErrTwoFactorAlreadyEnabled),
+		errors.Is(err, auth.ErrTwoFactorNotEnabled),
+		errors.Is(err, auth.ErrTwoFactorNotEnrolled):
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	default:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "two-factor operation failed"})
+	}
+}
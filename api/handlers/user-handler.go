@@ -1,22 +1,31 @@
 package handlers
 
 import (
+	"errors"
+	"fmt"
+	"log"
+	"math"
 	"net/http"
+	"net/url"
+	"strconv"
 
 	"github.com/gin-gonic/gin"
 	"github.com/google/uuid"
 	"github.com/terkoizmy/go-blog-api/config"
 	"github.com/terkoizmy/go-blog-api/internal/auth"
-	"github.com/terkoizmy/go-blog-api/internal/db"
+	"github.com/terkoizmy/go-blog-api/internal/mailer"
 	"github.com/terkoizmy/go-blog-api/internal/models"
+	"github.com/terkoizmy/go-blog-api/internal/repository"
 )
 
 // UserHandler handles user-related routes
-type UserHandler struct{}
+type UserHandler struct {
+	users repository.UserRepository
+}
 
 // NewUserHandler creates a new UserHandler
-func NewUserHandler() *UserHandler {
-	return &UserHandler{}
+func NewUserHandler(users repository.UserRepository) *UserHandler {
+	return &UserHandler{users: users}
 }
 
 // @Summary Register a new user
@@ -37,14 +46,13 @@ func (h *UserHandler) Register(c *gin.Context) {
 	}
 
 	// Check if username already exists
-	var existingUser models.User
-	if result := db.DB.Where("username = ?", req.Username).First(&existingUser); result.RowsAffected > 0 {
+	if exists, _ := h.users.UsernameExists(req.Username, uuid.Nil); exists {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "username already exists"})
 		return
 	}
 
 	// Check if email already exists
-	if result := db.DB.Where("email = ?", req.Email).First(&existingUser); result.RowsAffected > 0 {
+	if exists, _ := h.users.EmailExists(req.Email, uuid.Nil); exists {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "email already exists"})
 		return
 	}
@@ -63,14 +71,18 @@ func (h *UserHandler) Register(c *gin.Context) {
 		Password:  hashedPassword,
 		FirstName: req.FirstName,
 		LastName:  req.LastName,
-		Role:      "user", // Default role
+		Role:      auth.DefaultRole,
 	}
 
-	if result := db.DB.Create(&user); result.Error != nil {
+	if err := h.users.Create(&user); err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to create user"})
 		return
 	}
 
+	if err := sendVerificationEmail(user); err != nil {
+		log.Printf("Failed to send verification email to %s: %v", user.Email, err)
+	}
+
 	// Don't return the password
 	user.Password = ""
 
@@ -78,7 +90,7 @@ func (h *UserHandler) Register(c *gin.Context) {
 }
 
 // @Summary Login user
-// @Description Login with username and password
+// @Description Login with username and password. Accounts with two-factor authentication enabled receive a models.TwoFactorChallengeResponse to complete at /login/2fa instead of a token.
 // @Tags users
 // @Accept json
 // @Produce json
@@ -95,35 +107,394 @@ func (h *UserHandler) Login(c *gin.Context) {
 		return
 	}
 
+	cfg, err := config.LoadConfig()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "couldn't load config"})
+		return
+	}
+	policy := auth.LockoutPolicyFromConfig(cfg)
+	ip := c.ClientIP()
+
 	// Find user by username
-	var user models.User
-	if result := db.DB.Where("username = ?", req.Username).First(&user); result.Error != nil {
+	user, err := h.users.FindByUsername(req.Username)
+	if err != nil {
+		if !checkLoginAllowed(c, nil, ip) {
+			return
+		}
+		if err := auth.RecordFailedLogin(nil, req.Username, ip, policy); err != nil {
+			log.Printf("Failed to record login attempt: %v", err)
+		}
 		c.JSON(http.StatusUnauthorized, gin.H{"error": "invalid credentials"})
 		return
 	}
 
+	if !checkLoginAllowed(c, &user.ID, ip) {
+		return
+	}
+
 	// Check password
 	if !auth.CheckPasswordHash(req.Password, user.Password) {
+		if err := auth.RecordFailedLogin(&user.ID, req.Username, ip, policy); err != nil {
+			log.Printf("Failed to record login attempt: %v", err)
+		}
 		c.JSON(http.StatusUnauthorized, gin.H{"error": "invalid credentials"})
 		return
 	}
 
-	// Generate JWT token
+	if cfg.EmailVerification == config.EmailVerificationLogin && user.EmailVerifiedAt == nil {
+		c.JSON(http.StatusForbidden, gin.H{"error": "email address not verified"})
+		return
+	}
+
+	// Accounts with two-factor authentication get a challenge instead of a token
+	if user.TwoFactorEnabled {
+		challenge, err := auth.GenerateChallengeToken(user, cfg.TwoFactorChallengeTTL)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to generate challenge token"})
+			return
+		}
+
+		c.JSON(http.StatusOK, models.TwoFactorChallengeResponse{
+			TwoFactorRequired: true,
+			ChallengeToken:    challenge,
+			ExpiresIn:         int64(cfg.TwoFactorChallengeTTL.Seconds()),
+		})
+		return
+	}
+
+	if err := auth.RecordSuccessfulLogin(user.ID, user.Username, ip); err != nil {
+		log.Printf("Failed to record login attempt: %v", err)
+	}
+
+	respondWithTokens(c, user)
+}
+
+// checkLoginAllowed writes a 429 response with Retry-After if the client
+// address or account is locked out
+func checkLoginAllowed(c *gin.Context, userID *uuid.UUID, ip string) bool {
+	err := auth.CheckLoginAllowed(userID, ip)
+	if err == nil {
+		return true
+	}
+
+	if lockedOut, ok := auth.IsLockedOut(err); ok {
+		retryAfter := int64(math.Ceil(lockedOut.RetryAfter.Seconds()))
+		c.Header("Retry-After", strconv.FormatInt(retryAfter, 10))
+		c.JSON(http.StatusTooManyRequests, gin.H{"error": lockedOut.Error(), "retry_after": retryAfter})
+		return false
+	}
+
+	c.JSON(http.StatusInternalServerError, gin.H{"error": "couldn't check login attempts"})
+	return false
+}
+
+// @Summary Refresh access token
+// @Description Exchange a refresh token for a new access token and a rotated refresh token
+// @Tags users
+// @Accept json
+// @Produce json
+// @Param token body models.RefreshTokenRequest true "Refresh token"
+// @Success 200 {object} models.TokenResponse
+// @Failure 400 {object} map[string]string
+// @Failure 401 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Router /token/refresh [post]
+func (h *UserHandler) RefreshToken(c *gin.Context) {
+	var req models.RefreshTokenRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
 	cfg, err := config.LoadConfig()
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "couldn't load config"})
 		return
 	}
 
-	token, err := auth.GenerateToken(user, cfg.JWTSecret)
+	user, refreshToken, err := auth.RotateRefreshToken(req.RefreshToken, cfg.RefreshTokenTTL)
+	if err != nil {
+		switch {
+		case errors.Is(err, auth.ErrInvalidRefreshToken),
+			errors.Is(err, auth.ErrRefreshTokenExpired),
+			errors.Is(err, auth.ErrRefreshTokenReused):
+			c.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+		default:
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to refresh token"})
+		}
+		return
+	}
+
+	token, err := auth.GenerateToken(user, cfg.AccessTokenTTL)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to generate token"})
 		return
 	}
 
 	c.JSON(http.StatusOK, models.TokenResponse{
-		Token:     token,
-		TokenType: "Bearer",
+		Token:        token,
+		TokenType:    "Bearer",
+		ExpiresIn:    int64(cfg.AccessTokenTTL.Seconds()),
+		RefreshToken: refreshToken,
+	})
+}
+
+// @Summary Logout
+// @Description Revoke the current access token and, if provided, the refresh token of this session
+// @Tags users
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param token body models.LogoutRequest false "Refresh token of the session"
+// @Success 200 {object} map[string]string
+// @Failure 401 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Router /logout [post]
+func (h *UserHandler) Logout(c *gin.Context) {
+	value, exists := c.Get("claims")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "unauthorized"})
+		return
+	}
+
+	claims, ok := value.(*auth.JWTClaim)
+	if !ok {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "invalid token claims"})
+		return
+	}
+
+	// The body is optional
+	var req models.LogoutRequest
+	_ = c.ShouldBindJSON(&req)
+
+	if err := auth.RevokeToken(claims); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to revoke token"})
+		return
+	}
+
+	if req.RefreshToken != "" {
+		if err := auth.RevokeRefreshTokenFamily(req.RefreshToken); err != nil && !errors.Is(err, auth.ErrInvalidRefreshToken) {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to revoke refresh token"})
+			return
+		}
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "logged out successfully"})
+}
+
+// @Summary Logout all sessions
+// @Description Revoke every access token, refresh token and personal access token of the current user
+// @Tags users
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Success 200 {object} map[string]string
+// @Failure 401 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Router /logout/all [post]
+func (h *UserHandler) LogoutAll(c *gin.Context) {
+	userID, exists := c.Get("userID")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "unauthorized"})
+		return
+	}
+
+	id, ok := userID.(uuid.UUID)
+	if !ok {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "invalid user ID format"})
+		return
+	}
+
+	if err := auth.RevokeAllUserTokens(id); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to revoke sessions"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "all sessions logged out successfully"})
+}
+
+// @Summary Forgot password
+// @Description Email a single-use password reset token to the account with this address
+// @Tags users
+// @Accept json
+// @Produce json
+// @Param request body models.ForgotPasswordRequest true "Account email"
+// @Success 200 {object} map[string]string
+// @Failure 400 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Router /password/forgot [post]
+func (h *UserHandler) ForgotPassword(c *gin.Context) {
+	var req models.ForgotPasswordRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	// Always give the same answer so the endpoint can't be used to find accounts
+	response := gin.H{"message": "if an account with that email exists, a reset link has been sent"}
+
+	user, err := h.users.FindByEmail(req.Email)
+	if err != nil {
+		c.JSON(http.StatusOK, response)
+		return
+	}
+
+	// Sent in the background so the response time doesn't give away whether
+	// the account exists
+	go func() {
+		if err := sendPasswordResetEmail(user); err != nil {
+			log.Printf("Failed to send password reset email to %s: %v", user.Email, err)
+		}
+	}()
+
+	c.JSON(http.StatusOK, response)
+}
+
+// @Summary Reset password
+// @Description Set a new password using a token from the reset email
+// @Tags users
+// @Accept json
+// @Produce json
+// @Param request body models.ResetPasswordRequest true "Reset token and new password"
+// @Success 200 {object} map[string]string
+// @Failure 400 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Router /password/reset [post]
+func (h *UserHandler) ResetPassword(c *gin.Context) {
+	var req models.ResetPasswordRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if _, err := auth.ResetPassword(req.Token, req.Password); err != nil {
+		if errors.Is(err, auth.ErrInvalidResetToken) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to reset password"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "password reset successfully"})
+}
+
+// @Summary Verify email
+// @Description Confirm an email address using the token from the verification email
+// @Tags users
+// @Accept json
+// @Produce json
+// @Param request body models.VerifyEmailRequest true "Verification token"
+// @Success 200 {object} map[string]string
+// @Failure 400 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Router /email/verify [post]
+func (h *UserHandler) VerifyEmail(c *gin.Context) {
+	var req models.VerifyEmailRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if _, err := auth.VerifyEmail(req.Token); err != nil {
+		if errors.Is(err, auth.ErrInvalidVerificationToken) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to verify email"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "email verified successfully"})
+}
+
+// @Summary Resend verification email
+// @Description Send a new verification email to the current user's address
+// @Tags users
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Success 200 {object} map[string]string
+// @Failure 400 {object} map[string]string
+// @Failure 401 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Router /email/verify/resend [post]
+func (h *UserHandler) ResendVerificationEmail(c *gin.Context) {
+	userID, exists := c.Get("userID")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "unauthorized"})
+		return
+	}
+
+	id, ok := userID.(uuid.UUID)
+	if !ok {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "invalid user ID format"})
+		return
+	}
+
+	user, err := h.users.FindByID(id)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to get user"})
+		return
+	}
+
+	if user.EmailVerifiedAt != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "email already verified"})
+		return
+	}
+
+	if err := sendVerificationEmail(user); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to send verification email"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "verification email sent"})
+}
+
+// @Summary JSON Web Key Set
+// @Description Public keys for verifying access tokens issued by this API
+// @Tags users
+// @Produce json
+// @Success 200 {object} auth.JWKSet
+// @Failure 500 {object} map[string]string
+// @Router /.well-known/jwks.json [get]
+func (h *UserHandler) JWKS(c *gin.Context) {
+	jwks, err := auth.PublicJWKS()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.Header("Cache-Control", "public, max-age=300")
+	c.JSON(http.StatusOK, jwks)
+}
+
+// respondWithTokens issues a new access token and starts a new refresh token
+// family for the user
+func respondWithTokens(c *gin.Context, user models.User) {
+	cfg, err := config.LoadConfig()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "couldn't load config"})
+		return
+	}
+
+	token, err := auth.GenerateToken(user, cfg.AccessTokenTTL)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to generate token"})
+		return
+	}
+
+	refreshToken, err := auth.IssueRefreshToken(user.ID, uuid.Nil, cfg.RefreshTokenTTL)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to generate refresh token"})
+		return
+	}
+
+	c.JSON(http.StatusOK, models.TokenResponse{
+		Token:        token,
+		TokenType:    "Bearer",
+		ExpiresIn:    int64(cfg.AccessTokenTTL.Seconds()),
+		RefreshToken: refreshToken,
 	})
 }
 
@@ -150,8 +521,8 @@ func (h *UserHandler) GetMe(c *gin.Context) {
 		return
 	}
 
-	var user models.User
-	if result := db.DB.Where("id = ?", id).First(&user); result.Error != nil {
+	user, err := h.users.FindByID(id)
+	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to get user"})
 		return
 	}
@@ -168,14 +539,22 @@ func (h *UserHandler) GetMe(c *gin.Context) {
 // @Accept json
 // @Produce json
 // @Security BearerAuth
-// @Success 200 {array} models.User
+// @Param page query int false "Page number"
+// @Param limit query int false "Items per page"
+// @Success 200 {object} models.PaginatedResponse{data=[]models.User}
+// @Failure 400 {object} map[string]string
 // @Failure 401 {object} map[string]string
 // @Failure 403 {object} map[string]string
 // @Failure 500 {object} map[string]string
 // @Router /users [get]
 func (h *UserHandler) GetAll(c *gin.Context) {
-	var users []models.User
-	if result := db.DB.Find(&users); result.Error != nil {
+	p, ok := parsePagination(c)
+	if !ok {
+		return
+	}
+
+	users, total, err := h.users.List(p.Repository())
+	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to get users"})
 		return
 	}
@@ -185,11 +564,11 @@ func (h *UserHandler) GetAll(c *gin.Context) {
 		users[i].Password = ""
 	}
 
-	c.JSON(http.StatusOK, users)
+	writePage(c, users, p, total)
 }
 
 // @Summary Update user
-// @Description Update user information
+// @Description Update user information. Passwords and emails can't be changed with a personal access token.
 // @Tags users
 // @Accept json
 // @Produce json
@@ -225,17 +604,14 @@ func (h *UserHandler) UpdateUser(c *gin.Context) {
 		return
 	}
 
-	role, _ := c.Get("role")
-	roleStr, _ := role.(string)
-
-	// Only allow users to update their own profile or admins to update any profile
-	if userUUID != requestingUserID && roleStr != "admin" {
+	// Only allow users to update their own profile unless they can manage users
+	if userUUID != requestingUserID && !auth.Can(c, auth.PermUsersManage) {
 		c.JSON(http.StatusForbidden, gin.H{"error": "permission denied"})
 		return
 	}
 
-	var user models.User
-	if result := db.DB.Where("id = ?", userUUID).First(&user); result.Error != nil {
+	user, err := h.users.FindByID(userUUID)
+	if err != nil {
 		c.JSON(http.StatusNotFound, gin.H{"error": "user not found"})
 		return
 	}
@@ -263,19 +639,48 @@ func (h *UserHandler) UpdateUser(c *gin.Context) {
 		user.LastName = updateData.LastName
 	}
 
+	emailChanged := false
 	if updateData.Email != "" && updateData.Email != user.Email {
 		// Check if the email is already taken
-		var existingUser models.User
-		if result := db.DB.Where("email = ? AND id != ?", updateData.Email, userUUID).First(&existingUser); result.RowsAffected > 0 {
+		if exists, _ := h.users.EmailExists(updateData.Email, userUUID); exists {
 			c.JSON(http.StatusBadRequest, gin.H{"error": "email already exists"})
 			return
 		}
 		user.Email = updateData.Email
+		user.EmailVerifiedAt = nil
+		emailChanged = true
 	}
 
-	// Only admins can update roles
-	if updateData.Role != "" && roleStr == "admin" {
-		user.Role = updateData.Role
+	// Credentials can only be changed from an interactive login, and those of
+	// accounts with more permissions than the caller's only by someone who
+	// could also change their role
+	if (emailChanged || updateData.Password != "") && c.GetString("authMethod") == "pat" {
+		c.JSON(http.StatusForbidden, gin.H{"error": "personal access tokens can't change passwords or emails"})
+		return
+	}
+	if (emailChanged || updateData.Password != "") && !auth.CanManageUser(c, user.ID, user.Role) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "permission denied to change this user's credentials"})
+		return
+	}
+
+	// Existing sessions are revoked when credentials change
+	revokeSessions := false
+
+	// Role changes need the roles:manage permission
+	changeRole := updateData.Role != "" && updateData.Role != user.Role
+	if changeRole {
+		if !auth.Can(c, auth.PermRolesManage) {
+			c.JSON(http.StatusForbidden, gin.H{"error": "permission denied to change roles"})
+			return
+		}
+		if _, err := auth.GetRole(updateData.Role); err != nil {
+			if errors.Is(err, auth.ErrRoleNotFound) {
+				c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+				return
+			}
+			writeRoleError(c, err)
+			return
+		}
 	}
 
 	// Update password if provided
@@ -286,13 +691,29 @@ func (h *UserHandler) UpdateUser(c *gin.Context) {
 			return
 		}
 		user.Password = hashedPassword
+		revokeSessions = true
 	}
 
-	if result := db.DB.Save(&user); result.Error != nil {
+	// Nothing is saved unless the role change and session revocation succeed
+	role := ""
+	if changeRole {
+		role = updateData.Role
+	}
+	if err := auth.UpdateUser(&user, role, revokeSessions); err != nil {
+		if errors.Is(err, auth.ErrLastAdmin) || errors.Is(err, auth.ErrRoleNotFound) {
+			writeRoleError(c, err)
+			return
+		}
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to update user"})
 		return
 	}
 
+	if emailChanged {
+		if err := sendVerificationEmail(user); err != nil {
+			log.Printf("Failed to send verification email to %s: %v", user.Email, err)
+		}
+	}
+
 	// Don't return the password
 	user.Password = ""
 
@@ -300,7 +721,7 @@ func (h *UserHandler) UpdateUser(c *gin.Context) {
 }
 
 // @Summary Delete user
-// @Description Delete a user (admin only or own account)
+// @Description Delete a user (own account, or with users:manage; accounts with more permissions also take roles:manage). The last admin can't be deleted, and personal access tokens can't delete accounts.
 // @Tags users
 // @Accept json
 // @Produce json
@@ -311,6 +732,7 @@ func (h *UserHandler) UpdateUser(c *gin.Context) {
 // @Failure 401 {object} map[string]string
 // @Failure 403 {object} map[string]string
 // @Failure 404 {object} map[string]string
+// @Failure 409 {object} map[string]string
 // @Failure 500 {object} map[string]string
 // @Router /users/{id} [delete]
 func (h *UserHandler) DeleteUser(c *gin.Context) {
@@ -335,26 +757,83 @@ func (h *UserHandler) DeleteUser(c *gin.Context) {
 		return
 	}
 
-	role, _ := c.Get("role")
-	roleStr, _ := role.(string)
-
-	// Only allow users to delete their own account or admins to delete any account
-	if userUUID != requestingUserID && roleStr != "admin" {
+	// Only allow users to delete their own account unless they can manage users
+	if userUUID != requestingUserID && !auth.Can(c, auth.PermUsersManage) {
 		c.JSON(http.StatusForbidden, gin.H{"error": "permission denied"})
 		return
 	}
 
-	var user models.User
-	if result := db.DB.Where("id = ?", userUUID).First(&user); result.Error != nil {
+	user, err := h.users.FindByID(userUUID)
+	if err != nil {
 		c.JSON(http.StatusNotFound, gin.H{"error": "user not found"})
 		return
 	}
 
-	// Delete user
-	if result := db.DB.Delete(&user); result.Error != nil {
+	// Accounts with more permissions than the caller's take roles:manage
+	if !auth.CanManageUser(c, user.ID, user.Role) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "permission denied to delete this user"})
+		return
+	}
+
+	// Delete user, keeping at least one admin
+	if err := auth.DeleteUser(user.ID); err != nil {
+		if errors.Is(err, auth.ErrLastAdmin) || errors.Is(err, auth.ErrUserNotFound) {
+			writeRoleError(c, err)
+			return
+		}
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to delete user"})
 		return
 	}
 
 	c.JSON(http.StatusOK, gin.H{"message": "user deleted successfully"})
 }
+
+// sendPasswordResetEmail emails the user a single-use password reset token
+func sendPasswordResetEmail(user models.User) error {
+	cfg, err := config.LoadConfig()
+	if err != nil {
+		return err
+	}
+
+	token, err := auth.CreatePasswordResetToken(user.ID, cfg.PasswordResetTTL)
+	if err != nil {
+		return err
+	}
+
+	body := fmt.Sprintf("Hi %s,\n\nUse this token to reset your password: %s\n", user.Username, token)
+	if cfg.PasswordResetURL != "" {
+		body += fmt.Sprintf("\nOr open this link: %s?token=%s\n", cfg.PasswordResetURL, url.QueryEscape(token))
+	}
+	body += fmt.Sprintf("\nThe token expires in %s. If you didn't ask for a reset, you can ignore this email.\n", cfg.PasswordResetTTL)
+
+	return mailer.Send(mailer.Message{
+		To:      user.Email,
+		Subject: "Reset your password",
+		Body:    body,
+	})
+}
+
+// sendVerificationEmail emails the user a token confirming their current address
+func sendVerificationEmail(user models.User) error {
+	cfg, err := config.LoadConfig()
+	if err != nil {
+		return err
+	}
+
+	token, err := auth.CreateEmailVerificationToken(user.ID, user.Email, cfg.EmailVerificationTTL)
+	if err != nil {
+		return err
+	}
+
+	body := fmt.Sprintf("Hi %s,\n\nUse this token to verify your email address: %s\n", user.Username, token)
+	if cfg.EmailVerificationURL != "" {
+		body += fmt.Sprintf("\nOr open this link: %s?token=%s\n", cfg.EmailVerificationURL, url.QueryEscape(token))
+	}
+	body += fmt.Sprintf("\nThe token expires in %s.\n", cfg.EmailVerificationTTL)
+
+	return mailer.Send(mailer.Message{
+		To:      user.Email,
+		Subject: "Verify your email address",
+		Body:    body,
+	})
+}
@@ -0,0 +1,202 @@
+package handlers_test
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/terkoizmy/go-blog-api/internal/auth"
+	"github.com/terkoizmy/go-blog-api/internal/models"
+)
+
+func TestRegisterAndLogin(t *testing.T) {
+	api := newTestAPI(t)
+
+	res := api.do(t, http.MethodPost, "/api/v1/register", "", map[string]string{
+		"username": "alice",
+		"email":    "alice@example.com",
+		"password": testPassword,
+	})
+	if res.Code != http.StatusCreated {
+		t.Fatalf("register: got %d %s", res.Code, res.Body)
+	}
+	var registered models.User
+	decode(t, res, &registered)
+	if registered.Password != "" {
+		t.Error("register returned the password hash")
+	}
+	if registered.Role != auth.DefaultRole {
+		t.Errorf("registered with role %q, want %q", registered.Role, auth.DefaultRole)
+	}
+
+	duplicate := api.do(t, http.MethodPost, "/api/v1/register", "", map[string]string{
+		"username": "alice",
+		"email":    "other@example.com",
+		"password": testPassword,
+	})
+	if duplicate.Code != http.StatusBadRequest {
+		t.Errorf("duplicate username: got %d, want 400", duplicate.Code)
+	}
+
+	res = api.do(t, http.MethodPost, "/api/v1/login", "", models.LoginRequest{Username: "alice", Password: testPassword})
+	if res.Code != http.StatusOK {
+		t.Fatalf("login: got %d %s", res.Code, res.Body)
+	}
+	var tokens models.TokenResponse
+	decode(t, res, &tokens)
+
+	res = api.do(t, http.MethodGet, "/api/v1/users/me", tokens.Token, nil)
+	if res.Code != http.StatusOK {
+		t.Fatalf("me: got %d %s", res.Code, res.Body)
+	}
+	var me models.User
+	decode(t, res, &me)
+	if me.ID != registered.ID {
+		t.Errorf("me returned %s, want %s", me.ID, registered.ID)
+	}
+}
+
+func TestLoginRejectsWrongPassword(t *testing.T) {
+	api := newTestAPI(t)
+	api.createUser(t, "alice", auth.RoleUser)
+
+	res := api.do(t, http.MethodPost, "/api/v1/login", "", models.LoginRequest{Username: "alice", Password: "wrong-password"})
+	if res.Code != http.StatusUnauthorized {
+		t.Fatalf("got %d %s, want 401", res.Code, res.Body)
+	}
+
+	res = api.do(t, http.MethodGet, "/api/v1/users/me", "", nil)
+	if res.Code != http.StatusUnauthorized {
+		t.Fatalf("me without token: got %d, want 401", res.Code)
+	}
+}
+
+func TestUpdateUserPermissions(t *testing.T) {
+	api := newTestAPI(t)
+	admin := api.createUser(t, "admin", auth.RoleAdmin)
+	alice := api.createUser(t, "alice", auth.RoleUser)
+	bob := api.createUser(t, "bob", auth.RoleUser)
+
+	if _, err := auth.CreateRole("usermgr", "", []string{auth.PermUsersManage, auth.PermPostsPublish}); err != nil {
+		t.Fatal(err)
+	}
+	manager := api.createUser(t, "manager", "usermgr")
+
+	tests := []struct {
+		name   string
+		caller testUser
+		target testUser
+		body   map[string]string
+		want   int
+	}{
+		{"own profile", alice, alice, map[string]string{"first_name": "Alice"}, http.StatusOK},
+		{"someone else's profile", alice, bob, map[string]string{"first_name": "Bobby"}, http.StatusForbidden},
+		{"own role", alice, alice, map[string]string{"role": auth.RoleAdmin}, http.StatusForbidden},
+		{"password of a peer", manager, bob, map[string]string{"password": "new-password"}, http.StatusOK},
+		{"password of an admin", manager, admin, map[string]string{"password": "new-password"}, http.StatusForbidden},
+		{"email of an admin", manager, admin, map[string]string{"email": "taken@example.com"}, http.StatusForbidden},
+		{"password as admin", admin, alice, map[string]string{"password": "new-password"}, http.StatusOK},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			res := api.do(t, http.MethodPut, "/api/v1/users/"+tt.target.user.ID.String(), tt.caller.token, tt.body)
+			if res.Code != tt.want {
+				t.Fatalf("got %d %s, want %d", res.Code, res.Body, tt.want)
+			}
+		})
+	}
+}
+
+func TestDeleteUser(t *testing.T) {
+	api := newTestAPI(t)
+	admin := api.createUser(t, "admin", auth.RoleAdmin)
+	alice := api.createUser(t, "alice", auth.RoleUser)
+	bob := api.createUser(t, "bob", auth.RoleUser)
+
+	res := api.do(t, http.MethodDelete, "/api/v1/users/"+bob.user.ID.String(), alice.token, nil)
+	if res.Code != http.StatusForbidden {
+		t.Errorf("delete someone else: got %d, want 403", res.Code)
+	}
+
+	res = api.do(t, http.MethodDelete, "/api/v1/users/"+admin.user.ID.String(), admin.token, nil)
+	if res.Code != http.StatusConflict {
+		t.Errorf("delete the last admin: got %d %s, want 409", res.Code, res.Body)
+	}
+
+	res = api.do(t, http.MethodDelete, "/api/v1/users/"+alice.user.ID.String(), alice.token, nil)
+	if res.Code != http.StatusOK {
+		t.Fatalf("delete own account: got %d %s", res.Code, res.Body)
+	}
+
+	// Tokens of deleted accounts stop working
+	res = api.do(t, http.MethodGet, "/api/v1/users/me", alice.token, nil)
+	if res.Code != http.StatusUnauthorized {
+		t.Errorf("token of deleted account: got %d, want 401", res.Code)
+	}
+}
+
+func TestPersonalAccessTokensCantChangeCredentials(t *testing.T) {
+	api := newTestAPI(t)
+	alice := api.createUser(t, "alice", auth.RoleUser)
+
+	_, token, err := auth.CreatePersonalAccessToken(alice.user.ID, "ci", []string{auth.ScopeUsersRead, auth.ScopeUsersWrite}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	path := "/api/v1/users/" + alice.user.ID.String()
+
+	for _, tt := range []struct {
+		name   string
+		method string
+		body   map[string]string
+		want   int
+	}{
+		{"profile", http.MethodPut, map[string]string{"first_name": "Alice"}, http.StatusOK},
+		{"password", http.MethodPut, map[string]string{"password": "new-password"}, http.StatusForbidden},
+		{"email", http.MethodPut, map[string]string{"email": "new@example.com"}, http.StatusForbidden},
+		{"delete", http.MethodDelete, nil, http.StatusForbidden},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			var body interface{}
+			if tt.body != nil {
+				body = tt.body
+			}
+			res := api.do(t, tt.method, path, token, body)
+			if res.Code != tt.want {
+				t.Fatalf("got %d %s, want %d", res.Code, res.Body, tt.want)
+			}
+		})
+	}
+
+	// Changing the password from a session revokes the token
+	res := api.do(t, http.MethodPut, path, alice.token, map[string]string{"password": "new-password"})
+	if res.Code != http.StatusOK {
+		t.Fatalf("change password: got %d %s", res.Code, res.Body)
+	}
+	res = api.do(t, http.MethodGet, "/api/v1/users/me", token, nil)
+	if res.Code != http.StatusUnauthorized {
+		t.Errorf("token after a password change: got %d, want 401", res.Code)
+	}
+}
+
+func TestUpdateUserIsAllOrNothing(t *testing.T) {
+	api := newTestAPI(t)
+	admin := api.createUser(t, "admin", auth.RoleAdmin)
+
+	// Demoting the last admin fails, and so does the password change with it
+	res := api.do(t, http.MethodPut, "/api/v1/users/"+admin.user.ID.String(), admin.token, map[string]string{
+		"password": "new-password",
+		"role":     auth.RoleUser,
+	})
+	if res.Code != http.StatusConflict {
+		t.Fatalf("demote the last admin: got %d %s, want 409", res.Code, res.Body)
+	}
+
+	res = api.do(t, http.MethodPost, "/api/v1/login", "", models.LoginRequest{Username: "admin", Password: testPassword})
+	if res.Code != http.StatusOK {
+		t.Errorf("login with the old password: got %d %s", res.Code, res.Body)
+	}
+	res = api.do(t, http.MethodGet, "/api/v1/users/me", admin.token, nil)
+	if res.Code != http.StatusOK {
+		t.Errorf("session after the failed update: got %d, want 200", res.Code)
+	}
+}
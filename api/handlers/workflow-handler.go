@@ -0,0 +1,289 @@
+package handlers
+
+import (
+	"errors"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/terkoizmy/go-blog-api/internal/auth"
+	"github.com/terkoizmy/go-blog-api/internal/models"
+	"github.com/terkoizmy/go-blog-api/internal/repository"
+	"github.com/terkoizmy/go-blog-api/internal/workflow"
+)
+
+// errStatusChanged is the error of updates that lost a race to change the
+// status of a post
+const errStatusChanged = "post status changed in the meantime, reload it and try again"
+
+// WorkflowHandler handles post status changes and the review queue
+type WorkflowHandler struct {
+	posts       repository.PostRepository
+	transitions repository.PostTransitionRepository
+}
+
+func NewWorkflowHandler(posts repository.PostRepository, transitions repository.PostTransitionRepository) *WorkflowHandler {
+	return &WorkflowHandler{posts: posts, transitions: transitions}
+}
+
+// @Summary Change post status
+// @Description Move a post to another status. Authors submit posts for review (in_review) and withdraw them (draft); reviewers send them back (draft) or publish them (published or scheduled). Publishing needs the publish permission.
+// @Tags workflow
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param id path string true "Post ID"
+// @Param transition body models.TransitionRequest true "New status"
+// @Success 200 {object} models.Post
+// @Failure 400 {object} map[string]string
+// @Failure 401 {object} map[string]string
+// @Failure 403 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Failure 409 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Router /posts/{id}/transitions [post]
+func (h *WorkflowHandler) TransitionPost(c *gin.Context) {
+	postID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid post ID format"})
+		return
+	}
+
+	var req models.TransitionRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	userID, ok := c.MustGet("userID").(uuid.UUID)
+	if !ok {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "invalid user ID format"})
+		return
+	}
+
+	post, err := h.posts.FindByID(postID)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "post not found"})
+		return
+	}
+
+	from := post.Status
+	if !changeStatus(c, &post, req.Status, req.PublishAt) {
+		return
+	}
+
+	// Only the status that was checked is changed, should the scheduler or
+	// another request have moved the post on since it was loaded
+	updated, err := h.posts.UpdateIfStatus(&post, from)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to update post"})
+		return
+	}
+	if !updated {
+		c.JSON(http.StatusConflict, gin.H{"error": errStatusChanged})
+		return
+	}
+	recordTransition(h.transitions, post.ID, from, post.Status, userID, req.Note)
+
+	if updated, err := h.posts.FindByIDWithAuthor(post.ID); err == nil {
+		post = updated
+	}
+
+	post.Author.Password = ""
+	post.Author.Role = ""
+	c.JSON(http.StatusOK, post)
+}
+
+// @Summary List post status changes
+// @Description List the status changes of a post, newest first
+// @Tags workflow
+// @Produce json
+// @Security BearerAuth
+// @Param id path string true "Post ID"
+// @Param page query int false "Page number"
+// @Param limit query int false "Items per page"
+// @Success 200 {object} models.PaginatedResponse{data=[]models.PostTransition}
+// @Failure 400 {object} map[string]string
+// @Failure 401 {object} map[string]string
+// @Failure 403 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Router /posts/{id}/transitions [get]
+func (h *WorkflowHandler) ListTransitions(c *gin.Context) {
+	postID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid post ID format"})
+		return
+	}
+
+	post, err := h.posts.FindByID(postID)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "post not found"})
+		return
+	}
+
+	if !auth.CanSeeUnpublished(c, post.AuthorID) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "permission denied"})
+		return
+	}
+
+	p, ok := parsePagination(c)
+	if !ok {
+		return
+	}
+
+	transitions, total, err := h.transitions.ListByPost(post.ID, p.Repository())
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to get status changes"})
+		return
+	}
+
+	for i := range transitions {
+		if transitions[i].User != nil {
+			transitions[i].User.Password = ""
+		}
+	}
+
+	writePage(c, transitions, p, total)
+}
+
+// @Summary Review queue
+// @Description List the posts waiting for review, oldest first
+// @Tags workflow
+// @Produce json
+// @Security BearerAuth
+// @Param page query int false "Page number"
+// @Param limit query int false "Items per page"
+// @Param content query string false "excerpt (default) leaves out the content of each post, full includes it"
+// @Success 200 {object} models.PaginatedResponse{data=[]models.Post}
+// @Failure 400 {object} map[string]string
+// @Failure 401 {object} map[string]string
+// @Failure 403 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Router /posts/review-queue [get]
+func (h *WorkflowHandler) ReviewQueue(c *gin.Context) {
+	if !auth.Can(c, auth.PermPostsReview) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "permission denied"})
+		return
+	}
+
+	full, ok := parseListContent(c)
+	if !ok {
+		return
+	}
+
+	p, ok := parsePagination(c)
+	if !ok {
+		return
+	}
+
+	posts, total, err := h.posts.List(repository.PostFilter{
+		Status:    workflow.InReview,
+		Sort:      repository.PostSortCreatedAt,
+		Ascending: true,
+		Offset:    p.Offset(),
+		Limit:     p.Limit,
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to get posts"})
+		return
+	}
+
+	for i := range posts {
+		posts[i].Author.Password = ""
+	}
+
+	trimContent(posts, full)
+	writePage(c, posts, p, total)
+}
+
+// changeStatus moves the post to status if the workflow allows it and the
+// user may make the move, updating its publish times. Otherwise it responds
+// with an error and returns false.
+func changeStatus(c *gin.Context, post *models.Post, status string, publishAt *time.Time) bool {
+	requirement, err := workflow.Check(post.Status, status)
+	if errors.Is(err, workflow.ErrInvalidStatus) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return false
+	} else if err != nil {
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+		return false
+	}
+
+	if !mayTransition(c, *post, requirement) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "permission denied to move the post to " + status})
+		return false
+	}
+
+	post.Status = status
+	if status == workflow.Published && post.PublishedAt == nil {
+		now := time.Now()
+		post.PublishedAt = &now
+	}
+
+	return applySchedule(c, post, publishAt)
+}
+
+// mayTransition reports whether the user meets a transition's requirement
+func mayTransition(c *gin.Context, post models.Post, requirement workflow.Requirement) bool {
+	switch requirement {
+	case workflow.RequireEdit:
+		return auth.CanModify(c, post.AuthorID, auth.PermPostsEditAny)
+	case workflow.RequirePublish:
+		return auth.CanModify(c, post.AuthorID, auth.PermPostsEditAny) && auth.Can(c, auth.PermPostsPublish)
+	case workflow.RequireReview:
+		return auth.Can(c, auth.PermPostsReview)
+	case workflow.RequireAuthorOrReview:
+		userID, _ := c.Get("userID")
+		return userID == post.AuthorID || auth.Can(c, auth.PermPostsReview)
+	}
+	return false
+}
+
+// applySchedule sets or clears the publish time of a post according to its
+// status. Scheduled posts need a publish time; a new one must be in the
+// future. Invalid combinations get a 400 response and false.
+func applySchedule(c *gin.Context, post *models.Post, publishAt *time.Time) bool {
+	if post.Status != workflow.Scheduled {
+		if publishAt != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "publish_at can only be set on scheduled posts"})
+			return false
+		}
+		post.PublishAt = nil
+		return true
+	}
+
+	if publishAt == nil {
+		// Already scheduled posts keep their publish time
+		if post.PublishAt == nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "publish_at is required to schedule a post"})
+			return false
+		}
+		return true
+	}
+
+	if !publishAt.After(time.Now()) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "publish_at must be in the future"})
+		return false
+	}
+
+	post.PublishAt = publishAt
+	return true
+}
+
+// recordTransition adds a status change to the post's history. The change
+// has already been saved, so failures are only logged.
+func recordTransition(transitions repository.PostTransitionRepository, postID uuid.UUID, from, to string, userID uuid.UUID, note string) {
+	transition := models.PostTransition{
+		PostID:     postID,
+		FromStatus: from,
+		ToStatus:   to,
+		UserID:     &userID,
+		Note:       note,
+	}
+	if err := transitions.Create(&transition); err != nil {
+		log.Printf("Failed to record status change of post %s: %v", postID, err)
+	}
+}
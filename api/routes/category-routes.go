@@ -4,22 +4,25 @@ import (
 	"github.com/gin-gonic/gin"
 	"github.com/terkoizmy/go-blog-api/api/handlers"
 	"github.com/terkoizmy/go-blog-api/internal/auth"
+	"github.com/terkoizmy/go-blog-api/internal/repository"
 )
 
-func SetupCategoryRoutes(router *gin.Engine) {
-	categoryHandler := handlers.NewCategoryHandler()
+func SetupCategoryRoutes(router *gin.Engine, repos *repository.Repositories) {
+	categoryHandler := handlers.NewCategoryHandler(repos.Categories, repos.Posts)
 
 	api := router.Group("/api/v1")
 	categories := api.Group("/categories")
 
 	// Public routes
 	categories.GET("", categoryHandler.GetAllCategories)
+	categories.GET("/tree", categoryHandler.GetCategoryTree)
 	categories.GET("/:id", categoryHandler.GetCategoryByID)
 	categories.GET("/slug/:slug", categoryHandler.GetCategoryBySlug)
+	categories.GET("/:id/posts", categoryHandler.GetPostsByCategory)
 
 	// Protected routes
 	protected := categories.Group("")
-	protected.Use(auth.AuthMiddleware())
+	protected.Use(auth.AuthMiddleware(), auth.ScopeMiddleware(auth.ScopeCategoriesWrite))
 	{
 		protected.POST("", categoryHandler.CreateCategory)
 		protected.PUT("/:id", categoryHandler.UpdateCategory)
@@ -4,10 +4,11 @@ import (
 	"github.com/gin-gonic/gin"
 	"github.com/terkoizmy/go-blog-api/api/handlers"
 	"github.com/terkoizmy/go-blog-api/internal/auth"
+	"github.com/terkoizmy/go-blog-api/internal/repository"
 )
 
-func SetupCommentRoutes(router *gin.Engine) {
-	commentHandler := handlers.NewCommentHandler()
+func SetupCommentRoutes(router *gin.Engine, repos *repository.Repositories) {
+	commentHandler := handlers.NewCommentHandler(repos.Comments, repos.Posts)
 
 	api := router.Group("/api/v1")
 	comment := api.Group("/comment")
@@ -19,7 +20,7 @@ func SetupCommentRoutes(router *gin.Engine) {
 
 	// Protected routes
 	protected := comment.Group("")
-	protected.Use(auth.AuthMiddleware())
+	protected.Use(auth.AuthMiddleware(), auth.ScopeMiddleware(auth.ScopeCommentsWrite))
 	{
 		protected.POST("/posts/:postId", commentHandler.CreateComment)
 		protected.PUT("/:id", commentHandler.UpdateComment)
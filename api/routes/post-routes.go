@@ -4,27 +4,42 @@ import (
 	"github.com/gin-gonic/gin"
 	"github.com/terkoizmy/go-blog-api/api/handlers"
 	"github.com/terkoizmy/go-blog-api/internal/auth"
+	"github.com/terkoizmy/go-blog-api/internal/repository"
 )
 
-func SetupPostRoutes(router *gin.Engine) {
-	postHandler := handlers.NewPostHandler()
+func SetupPostRoutes(router *gin.Engine, repos *repository.Repositories) {
+	postHandler := handlers.NewPostHandler(repos.Posts, repos.Revisions, repos.Transitions, repos.Users, repos.Categories)
+	revisionHandler := handlers.NewRevisionHandler(repos.Posts, repos.Revisions)
+	workflowHandler := handlers.NewWorkflowHandler(repos.Posts, repos.Transitions)
 
 	api := router.Group("/api/v1")
 	posts := api.Group("/posts")
 
-	// Public routes
-	posts.GET("", postHandler.GetAllPosts)
-	posts.GET("/:id", postHandler.GetPostByID)
+	// Public routes. Signed in authors and reviewers also see unpublished
+	// posts there.
+	posts.GET("", auth.OptionalAuthMiddleware(), postHandler.GetAllPosts)
+	posts.GET("/:id", auth.OptionalAuthMiddleware(), postHandler.GetPostByID)
 	posts.GET("/user/:userId", postHandler.GetPostsByUserID)
-	posts.GET("/slug/:slug", postHandler.GetPostBySlug)
+	posts.GET("/slug/:slug", auth.OptionalAuthMiddleware(), postHandler.GetPostBySlug)
 
 	// Protected routes
 	protected := posts.Group("")
 	protected.Use(auth.AuthMiddleware())
 	{
-		protected.GET("/own", postHandler.GetOwnPosts)
-		protected.POST("", postHandler.CreatePost)
-		protected.PUT("/:id", postHandler.UpdatePost)
-		protected.DELETE("/:id", postHandler.DeletePost)
+		protected.GET("/own", auth.ScopeMiddleware(auth.ScopePostsRead), postHandler.GetOwnPosts)
+		protected.GET("/scheduled", auth.ScopeMiddleware(auth.ScopePostsRead), postHandler.GetScheduledPosts)
+		protected.GET("/review-queue", auth.ScopeMiddleware(auth.ScopePostsRead), workflowHandler.ReviewQueue)
+		protected.POST("", auth.ScopeMiddleware(auth.ScopePostsWrite), postHandler.CreatePost)
+		protected.PUT("/:id", auth.ScopeMiddleware(auth.ScopePostsWrite), postHandler.UpdatePost)
+		protected.DELETE("/:id", auth.ScopeMiddleware(auth.ScopePostsWrite), postHandler.DeletePost)
+		protected.DELETE("/:id/schedule", auth.ScopeMiddleware(auth.ScopePostsWrite), postHandler.CancelSchedule)
+
+		protected.GET("/:id/transitions", auth.ScopeMiddleware(auth.ScopePostsRead), workflowHandler.ListTransitions)
+		protected.POST("/:id/transitions", auth.ScopeMiddleware(auth.ScopePostsWrite), workflowHandler.TransitionPost)
+
+		protected.GET("/:id/revisions", auth.ScopeMiddleware(auth.ScopePostsRead), revisionHandler.ListRevisions)
+		protected.GET("/:id/revisions/:number", auth.ScopeMiddleware(auth.ScopePostsRead), revisionHandler.GetRevision)
+		protected.GET("/:id/revisions/:number/diff", auth.ScopeMiddleware(auth.ScopePostsRead), revisionHandler.DiffRevisions)
+		protected.POST("/:id/revisions/:number/restore", auth.ScopeMiddleware(auth.ScopePostsWrite), revisionHandler.RestoreRevision)
 	}
 }
@@ -0,0 +1,27 @@
+package routes
+
+import (
+	"github.com/gin-gonic/gin"
+	"github.com/terkoizmy/go-blog-api/api/handlers"
+	"github.com/terkoizmy/go-blog-api/internal/auth"
+)
+
+// SetupRoleRoutes configures role and permission management routes
+func SetupRoleRoutes(router *gin.Engine) {
+	roleHandler := handlers.NewRoleHandler()
+
+	api := router.Group("/api/v1")
+
+	// Role management can only be done from an interactive login
+	admin := api.Group("")
+	admin.Use(auth.AuthMiddleware(), auth.SessionOnlyMiddleware(), auth.PermissionMiddleware(auth.PermRolesManage))
+	{
+		admin.GET("/permissions", roleHandler.GetPermissions)
+		admin.GET("/roles", roleHandler.GetRoles)
+		admin.GET("/roles/:name", roleHandler.GetRole)
+		admin.POST("/roles", roleHandler.CreateRole)
+		admin.PUT("/roles/:name", roleHandler.UpdateRole)
+		admin.DELETE("/roles/:name", roleHandler.DeleteRole)
+		admin.PUT("/users/:id/role", roleHandler.AssignRole)
+	}
+}
@@ -0,0 +1,16 @@
+package routes
+
+import (
+	"github.com/gin-gonic/gin"
+	"github.com/terkoizmy/go-blog-api/api/handlers"
+	"github.com/terkoizmy/go-blog-api/internal/repository"
+)
+
+func SetupSearchRoutes(router *gin.Engine, repos *repository.Repositories) {
+	searchHandler := handlers.NewSearchHandler(repos.Search, repos.Categories, repos.Users)
+
+	api := router.Group("/api/v1")
+
+	// Public routes
+	api.GET("/search", searchHandler.Search)
+}
@@ -0,0 +1,27 @@
+package routes
+
+import (
+	"github.com/gin-gonic/gin"
+	"github.com/terkoizmy/go-blog-api/api/handlers"
+	"github.com/terkoizmy/go-blog-api/internal/auth"
+	"github.com/terkoizmy/go-blog-api/internal/repository"
+)
+
+func SetupTagRoutes(router *gin.Engine, repos *repository.Repositories) {
+	tagHandler := handlers.NewTagHandler(repos.Tags, repos.Posts)
+
+	api := router.Group("/api/v1")
+	tags := api.Group("/tags")
+
+	// Public routes
+	tags.GET("", tagHandler.GetAllTags)
+	tags.GET("/:slug/posts", tagHandler.GetPostsByTag)
+
+	// Protected routes
+	protected := tags.Group("")
+	protected.Use(auth.AuthMiddleware(), auth.ScopeMiddleware(auth.ScopeTagsWrite))
+	{
+		protected.PUT("/:slug", tagHandler.RenameTag)
+		protected.POST("/:slug/merge", tagHandler.MergeTag)
+	}
+}
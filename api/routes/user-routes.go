@@ -4,12 +4,13 @@ import (
 	"github.com/gin-gonic/gin"
 	"github.com/terkoizmy/go-blog-api/api/handlers"
 	"github.com/terkoizmy/go-blog-api/internal/auth"
+	"github.com/terkoizmy/go-blog-api/internal/repository"
 )
 
 // SetupUserRoutes configures all the user related routes
-func SetupUserRoutes(router *gin.Engine) {
+func SetupUserRoutes(router *gin.Engine, repos *repository.Repositories) {
 	// Create user handler
-	userHandler := handlers.NewUserHandler()
+	userHandler := handlers.NewUserHandler(repos.Users)
 
 	// Health check route
 	router.GET("/health", func(c *gin.Context) {
@@ -18,31 +19,85 @@ func SetupUserRoutes(router *gin.Engine) {
 		})
 	})
 
+	// Public keys for verifying access tokens
+	router.GET("/.well-known/jwks.json", userHandler.JWKS)
+
 	// Public routes (no authentication required)
 	router.POST("/api/v1/register", userHandler.Register)
 	router.POST("/api/v1/login", userHandler.Login)
+	router.POST("/api/v1/login/2fa", userHandler.LoginTwoFactor)
+	router.POST("/api/v1/token/refresh", userHandler.RefreshToken)
+	router.POST("/api/v1/password/forgot", userHandler.ForgotPassword)
+	router.POST("/api/v1/password/reset", userHandler.ResetPassword)
+	router.POST("/api/v1/email/verify", userHandler.VerifyEmail)
+
+	// Social login through OpenID Connect providers
+	router.GET("/api/v1/oauth/providers", userHandler.GetOAuthProviders)
+	router.GET("/api/v1/oauth/:provider/login", userHandler.OAuthLogin)
+	router.GET("/api/v1/oauth/:provider/callback", userHandler.OAuthCallback)
 
 	// Routes that require authentication
 	// Create a group with authentication middleware
 	authorized := router.Group("/api/v1")
 	authorized.Use(auth.AuthMiddleware())
 	{
+		// Session routes
+		authorized.POST("/logout", auth.SessionOnlyMiddleware(), userHandler.Logout)
+		authorized.POST("/logout/all", auth.SessionOnlyMiddleware(), userHandler.LogoutAll)
+
+		// Email verification
+		authorized.POST("/email/verify/resend", auth.SessionOnlyMiddleware(), userHandler.ResendVerificationEmail)
+
 		// User routes
 		users := authorized.Group("/users")
 		{
 			// Get current user profile
-			users.GET("/me", userHandler.GetMe)
+			users.GET("/me", auth.ScopeMiddleware(auth.ScopeUsersRead), userHandler.GetMe)
+
+			// Recent login attempts on the current user's account
+			users.GET("/me/login-attempts", auth.ScopeMiddleware(auth.ScopeUsersRead), userHandler.GetLoginAttempts)
+
+			// Two-factor authentication for the current user
+			twoFactor := users.Group("/me/2fa")
+			twoFactor.Use(auth.SessionOnlyMiddleware())
+			{
+				twoFactor.POST("/enroll", userHandler.EnrollTwoFactor)
+				twoFactor.POST("/verify", userHandler.EnableTwoFactor)
+				twoFactor.POST("/disable", userHandler.DisableTwoFactor)
+				twoFactor.POST("/recovery-codes", userHandler.RegenerateRecoveryCodes)
+			}
+
+			// Identity provider accounts linked to the current user
+			identities := users.Group("/me/identities")
+			identities.Use(auth.SessionOnlyMiddleware())
+			{
+				identities.GET("", userHandler.GetIdentities)
+				identities.POST("/:provider", userHandler.LinkIdentity)
+				identities.DELETE("/:id", userHandler.UnlinkIdentity)
+			}
+
+			// Personal access tokens can't be used to manage tokens
+			tokens := users.Group("/me/tokens")
+			tokens.Use(auth.SessionOnlyMiddleware())
+			{
+				tokens.POST("", userHandler.CreateAccessToken)
+				tokens.GET("", userHandler.GetAccessTokens)
+				tokens.DELETE("/:id", userHandler.RevokeAccessToken)
+			}
+
+			// List all users - requires the users:manage permission
+			users.GET("", auth.ScopeMiddleware(auth.ScopeUsersRead), auth.PermissionMiddleware(auth.PermUsersManage), userHandler.GetAll)
 
-			// Admin route to get all users - requires admin role
-			users.GET("", auth.RoleMiddleware("admin"), userHandler.GetAll)
+			// Lift a login lockout - requires the users:manage permission
+			users.POST("/:id/unlock", auth.ScopeMiddleware(auth.ScopeUsersWrite), auth.PermissionMiddleware(auth.PermUsersManage), userHandler.UnlockUser)
 
-			// User can update their own profile or admin can update any profile
+			// User can update their own profile or, with users:manage, any profile
 			// The handler itself checks for permissions
-			users.PUT("/:id", userHandler.UpdateUser)
+			users.PUT("/:id", auth.ScopeMiddleware(auth.ScopeUsersWrite), userHandler.UpdateUser)
 
-			// User can delete their own account or admin can delete any account
+			// User can delete their own account or, with users:manage, any account
 			// The handler itself checks for permissions
-			users.DELETE("/:id", userHandler.DeleteUser)
+			users.DELETE("/:id", auth.SessionOnlyMiddleware(), userHandler.DeleteUser)
 		}
 	}
 }
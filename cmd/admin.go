@@ -0,0 +1,174 @@
+package main
+
+import (
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/terkoizmy/go-blog-api/config"
+	"github.com/terkoizmy/go-blog-api/internal/auth"
+	"github.com/terkoizmy/go-blog-api/internal/db"
+	"github.com/terkoizmy/go-blog-api/internal/models"
+	"github.com/terkoizmy/go-blog-api/internal/repository"
+	"github.com/urfave/cli/v2"
+)
+
+// minPasswordLength matches the minimum enforced on registration
+const minPasswordLength = 6
+
+var createAdminCommand = &cli.Command{
+	Name:  "create-admin",
+	Usage: "Create a user with the admin role",
+	Flags: []cli.Flag{
+		&cli.StringFlag{Name: "username", Required: true},
+		&cli.StringFlag{Name: "email", Required: true},
+		&cli.StringFlag{Name: "password", Usage: "generated and printed if omitted"},
+		&cli.StringFlag{Name: "first-name"},
+		&cli.StringFlag{Name: "last-name"},
+	},
+	Action: createAdmin,
+}
+
+var resetPasswordCommand = &cli.Command{
+	Name:  "reset-password",
+	Usage: "Set a user's password, signing them out everywhere and lifting any lockout",
+	Flags: []cli.Flag{
+		&cli.StringFlag{Name: "username", Usage: "user to reset, or use --email"},
+		&cli.StringFlag{Name: "email", Usage: "user to reset, or use --username"},
+		&cli.StringFlag{Name: "password", Usage: "generated and printed if omitted"},
+	},
+	Action: resetPassword,
+}
+
+func createAdmin(c *cli.Context) error {
+	cfg, err := config.LoadConfig()
+	if err != nil {
+		return fmt.Errorf("failed to load config: %w", err)
+	}
+	if err := connect(cfg); err != nil {
+		return err
+	}
+
+	users := repository.NewUserRepository(db.DB)
+	username := c.String("username")
+	email := c.String("email")
+
+	if exists, err := users.UsernameExists(username, uuid.Nil); err != nil {
+		return err
+	} else if exists {
+		return fmt.Errorf("username %q already exists", username)
+	}
+	if exists, err := users.EmailExists(email, uuid.Nil); err != nil {
+		return err
+	} else if exists {
+		return fmt.Errorf("email %q already exists", email)
+	}
+
+	password, generated, err := passwordFromFlag(c)
+	if err != nil {
+		return err
+	}
+
+	hashedPassword, err := auth.HashPassword(password)
+	if err != nil {
+		return fmt.Errorf("failed to hash password: %w", err)
+	}
+
+	// The operator vouches for the address
+	now := time.Now()
+	user := models.User{
+		Username:        username,
+		Email:           email,
+		Password:        hashedPassword,
+		FirstName:       c.String("first-name"),
+		LastName:        c.String("last-name"),
+		Role:            auth.RoleAdmin,
+		EmailVerifiedAt: &now,
+	}
+	if err := users.Create(&user); err != nil {
+		return fmt.Errorf("failed to create user: %w", err)
+	}
+
+	fmt.Printf("Created admin %s (%s)\n", user.Username, user.ID)
+	if generated {
+		fmt.Printf("Password: %s\n", password)
+	}
+	return nil
+}
+
+func resetPassword(c *cli.Context) error {
+	username := c.String("username")
+	email := c.String("email")
+	if (username == "") == (email == "") {
+		return errors.New("pass exactly one of --username or --email")
+	}
+
+	cfg, err := config.LoadConfig()
+	if err != nil {
+		return fmt.Errorf("failed to load config: %w", err)
+	}
+	if err := connect(cfg); err != nil {
+		return err
+	}
+
+	users := repository.NewUserRepository(db.DB)
+	var user models.User
+	if username != "" {
+		user, err = users.FindByUsername(username)
+	} else {
+		user, err = users.FindByEmail(email)
+	}
+	if errors.Is(err, repository.ErrNotFound) {
+		return errors.New("user not found")
+	} else if err != nil {
+		return err
+	}
+
+	password, generated, err := passwordFromFlag(c)
+	if err != nil {
+		return err
+	}
+
+	hashedPassword, err := auth.HashPassword(password)
+	if err != nil {
+		return fmt.Errorf("failed to hash password: %w", err)
+	}
+
+	user.Password = hashedPassword
+	if err := users.Update(&user); err != nil {
+		return fmt.Errorf("failed to update password: %w", err)
+	}
+
+	if err := auth.RevokeAllUserTokens(user.ID); err != nil {
+		return fmt.Errorf("failed to revoke sessions: %w", err)
+	}
+	if err := auth.UnlockAccount(user.ID); err != nil {
+		return fmt.Errorf("failed to unlock account: %w", err)
+	}
+
+	fmt.Printf("Reset password of %s\n", user.Username)
+	if generated {
+		fmt.Printf("Password: %s\n", password)
+	}
+	return nil
+}
+
+// passwordFromFlag returns the --password flag, or a random password if it
+// was left out
+func passwordFromFlag(c *cli.Context) (password string, generated bool, err error) {
+	if password = c.String("password"); password != "" {
+		if len(password) < minPasswordLength {
+			return "", false, fmt.Errorf("password must be at least %d characters", minPasswordLength)
+		}
+		return password, false, nil
+	}
+
+	buf := make([]byte, 12)
+	if _, err := rand.Read(buf); err != nil {
+		return "", false, err
+	}
+	return base64.RawURLEncoding.EncodeToString(buf), true, nil
+}
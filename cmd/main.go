@@ -1,16 +1,15 @@
 package main
 
 import (
+	"fmt"
 	"log"
+	"os"
 
-	"github.com/gin-gonic/gin"
-	swaggerfiles "github.com/swaggo/files"
-	ginSwagger "github.com/swaggo/gin-swagger"
-	"github.com/terkoizmy/go-blog-api/api/routes"
 	"github.com/terkoizmy/go-blog-api/config"
 	_ "github.com/terkoizmy/go-blog-api/docs" // Import docs
+	"github.com/terkoizmy/go-blog-api/internal/auth"
 	"github.com/terkoizmy/go-blog-api/internal/db"
-	"github.com/terkoizmy/go-blog-api/internal/models"
+	"github.com/urfave/cli/v2"
 )
 
 // @title           Blog API
@@ -33,34 +32,41 @@ import (
 // @name                        Authorization
 // @description                 Type "Bearer" followed by a space and the JWT token.
 func main() {
-	// Load configuration
-	cfg, err := config.LoadConfig()
-	if err != nil {
-		log.Fatalf("Failed to load config: %v", err)
+	app := &cli.App{
+		Name:  "blog-api",
+		Usage: "Blog API server and maintenance commands",
+		// Running without a command starts the server
+		Action: runServe,
+		Commands: []*cli.Command{
+			serveCommand,
+			migrateCommand,
+			seedCommand,
+			createAdminCommand,
+			resetPasswordCommand,
+			purgeDeletedCommand,
+			renderContentCommand,
+		},
 	}
 
-	// Set gin mode based on config
-	gin.SetMode(cfg.GinMode)
-
-	// Initialize database
-	db.InitDB(cfg)
-
-	// Auto migrate the schema
-	db.DB.AutoMigrate(&models.User{}, &models.Post{}, &models.Category{}, &models.Comment{})
+	if err := app.Run(os.Args); err != nil {
+		log.Fatal(err)
+	}
+}
 
-	// Initialize router
-	router := gin.Default()
+// connect opens the database, makes sure its schema is current and creates
+// the built-in roles
+func connect(cfg config.Config) error {
+	if err := db.InitDB(cfg); err != nil {
+		return fmt.Errorf("failed to initialize database: %w", err)
+	}
 
-	// Setup routes
-	routes.SetupUserRoutes(router)
-	routes.SetupPostRoutes(router)
-	routes.SetupCategoryRoutes(router)
-	routes.SetupCommentRoutes(router)
+	if err := requireCurrentSchema(cfg); err != nil {
+		return err
+	}
 
-	// Swagger documentation
-	router.GET("/swagger/*any", ginSwagger.WrapHandler(swaggerfiles.Handler))
+	if err := auth.SeedRoles(); err != nil {
+		return fmt.Errorf("failed to seed roles: %w", err)
+	}
 
-	// Start server
-	log.Printf("Server running on port %s", cfg.Port)
-	router.Run(":" + cfg.Port)
+	return nil
 }
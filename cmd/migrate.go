@@ -0,0 +1,170 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"text/tabwriter"
+
+	"github.com/terkoizmy/go-blog-api/config"
+	"github.com/terkoizmy/go-blog-api/internal/db"
+	"github.com/terkoizmy/go-blog-api/internal/migrations"
+	"github.com/urfave/cli/v2"
+)
+
+var migrateCommand = &cli.Command{
+	Name:  "migrate",
+	Usage: "Apply, roll back or create schema migrations",
+	Subcommands: []*cli.Command{
+		{
+			Name:   "up",
+			Usage:  "Apply every pending migration",
+			Action: migrateUp,
+		},
+		{
+			Name:  "down",
+			Usage: "Roll back the most recent migrations",
+			Flags: []cli.Flag{
+				&cli.IntFlag{Name: "steps", Aliases: []string{"n"}, Value: 1, Usage: "number of migrations to roll back"},
+			},
+			Action: migrateDown,
+		},
+		{
+			Name:   "status",
+			Usage:  "List migrations and whether they have been applied",
+			Action: migrateStatus,
+		},
+		{
+			Name:      "create",
+			Usage:     "Write empty up and down files for a new migration",
+			ArgsUsage: "<name>",
+			Action:    migrateCreate,
+		},
+	},
+}
+
+func migrateUp(c *cli.Context) error {
+	if err := openMigrationDatabase(); err != nil {
+		return err
+	}
+
+	applied, err := migrations.Up(db.DB)
+	for _, m := range applied {
+		fmt.Printf("Applied %04d_%s\n", m.Version, m.Name)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to migrate: %w", err)
+	}
+	if len(applied) == 0 {
+		fmt.Println("Schema is up to date")
+		return nil
+	}
+	return renderAfterMigrating()
+}
+
+func migrateDown(c *cli.Context) error {
+	steps := c.Int("steps")
+	if steps < 1 {
+		return errors.New("steps must be at least 1")
+	}
+
+	if err := openMigrationDatabase(); err != nil {
+		return err
+	}
+
+	rolledBack, err := migrations.Down(db.DB, steps)
+	for _, m := range rolledBack {
+		fmt.Printf("Rolled back %04d_%s\n", m.Version, m.Name)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to roll back: %w", err)
+	}
+	return nil
+}
+
+func migrateStatus(c *cli.Context) error {
+	if err := openMigrationDatabase(); err != nil {
+		return err
+	}
+
+	statuses, err := migrations.GetStatus(db.DB)
+	if err != nil {
+		return fmt.Errorf("failed to get migration status: %w", err)
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "VERSION\tNAME\tAPPLIED AT")
+	for _, s := range statuses {
+		appliedAt := "pending"
+		if s.AppliedAt != nil {
+			appliedAt = s.AppliedAt.Format("2006-01-02 15:04:05")
+		}
+		fmt.Fprintf(w, "%04d\t%s\t%s\n", s.Version, s.Name, appliedAt)
+	}
+	return w.Flush()
+}
+
+// migrateCreate only touches the source tree, so it doesn't need a database
+func migrateCreate(c *cli.Context) error {
+	if c.NArg() != 1 {
+		return errors.New("usage: migrate create <name>")
+	}
+
+	cfg, err := config.LoadConfig()
+	if err != nil {
+		return fmt.Errorf("failed to load config: %w", err)
+	}
+
+	created, err := migrations.Create(cfg.MigrationsDir, c.Args().First())
+	if err != nil {
+		return fmt.Errorf("failed to create migration: %w", err)
+	}
+	for _, file := range created {
+		fmt.Println("Created", file)
+	}
+	return nil
+}
+
+// openMigrationDatabase connects without checking the schema, which is what
+// the migrate commands are there to fix
+func openMigrationDatabase() error {
+	cfg, err := config.LoadConfig()
+	if err != nil {
+		return fmt.Errorf("failed to load config: %w", err)
+	}
+
+	if err := db.InitDB(cfg); err != nil {
+		return fmt.Errorf("failed to initialize database: %w", err)
+	}
+	return nil
+}
+
+// requireCurrentSchema applies pending migrations when MIGRATE_ON_START is
+// set, or the database is in memory and nothing else could migrate it, and
+// otherwise refuses to continue until they have been applied
+func requireCurrentSchema(cfg config.Config) error {
+	if cfg.MigrateOnStart || db.InMemory(cfg.DBDriver, cfg.DatabaseURL) {
+		applied, err := migrations.Up(db.DB)
+		for _, m := range applied {
+			log.Printf("Applied migration %04d_%s", m.Version, m.Name)
+		}
+		if err != nil {
+			return fmt.Errorf("failed to migrate: %w", err)
+		}
+		if len(applied) == 0 {
+			return nil
+		}
+		return renderAfterMigrating()
+	}
+
+	pending, err := migrations.Pending(db.DB)
+	if err != nil {
+		return fmt.Errorf("failed to check migrations: %w", err)
+	}
+	if len(pending) > 0 {
+		return fmt.Errorf("database schema is behind by %d migration(s), starting with %04d_%s; run `migrate up` first",
+			len(pending), pending[0].Version, pending[0].Name)
+	}
+	return nil
+}
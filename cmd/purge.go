@@ -0,0 +1,39 @@
+package main
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/terkoizmy/go-blog-api/config"
+	"github.com/terkoizmy/go-blog-api/internal/db"
+	"github.com/terkoizmy/go-blog-api/internal/repository"
+	"github.com/urfave/cli/v2"
+)
+
+var purgeDeletedCommand = &cli.Command{
+	Name:  "purge-deleted",
+	Usage: "Permanently remove soft-deleted users, posts, comments and categories",
+	Flags: []cli.Flag{
+		&cli.DurationFlag{Name: "older-than", Value: 30 * 24 * time.Hour, Usage: "only purge records deleted at least this long ago"},
+	},
+	Action: purgeDeleted,
+}
+
+func purgeDeleted(c *cli.Context) error {
+	cfg, err := config.LoadConfig()
+	if err != nil {
+		return fmt.Errorf("failed to load config: %w", err)
+	}
+	if err := connect(cfg); err != nil {
+		return err
+	}
+
+	counts, err := repository.PurgeDeleted(db.DB, time.Now().Add(-c.Duration("older-than")))
+	if err != nil {
+		return fmt.Errorf("failed to purge deleted records: %w", err)
+	}
+
+	fmt.Printf("Purged %d users, %d posts, %d comments and %d categories\n",
+		counts.Users, counts.Posts, counts.Comments, counts.Categories)
+	return nil
+}
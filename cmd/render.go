@@ -0,0 +1,48 @@
+package main
+
+import (
+	"fmt"
+	"log"
+
+	"github.com/terkoizmy/go-blog-api/config"
+	"github.com/terkoizmy/go-blog-api/internal/db"
+	"github.com/terkoizmy/go-blog-api/internal/repository"
+	"github.com/urfave/cli/v2"
+)
+
+var renderContentCommand = &cli.Command{
+	Name:   "render-content",
+	Usage:  "Render posts and comments whose HTML isn't cached yet",
+	Action: renderContent,
+}
+
+func renderContent(c *cli.Context) error {
+	cfg, err := config.LoadConfig()
+	if err != nil {
+		return fmt.Errorf("failed to load config: %w", err)
+	}
+	if err := connect(cfg); err != nil {
+		return err
+	}
+
+	counts, err := repository.RenderContent(db.DB)
+	if err != nil {
+		return fmt.Errorf("failed to render content: %w", err)
+	}
+
+	fmt.Printf("Rendered %d posts and %d comments\n", counts.Posts, counts.Comments)
+	return nil
+}
+
+// renderAfterMigrating fills the HTML caches that migrations leave empty, so
+// reads don't render the content again until it is next saved
+func renderAfterMigrating() error {
+	counts, err := repository.RenderContent(db.DB)
+	if err != nil {
+		return fmt.Errorf("failed to render content: %w", err)
+	}
+	if counts.Posts > 0 || counts.Comments > 0 {
+		log.Printf("Rendered %d posts and %d comments", counts.Posts, counts.Comments)
+	}
+	return nil
+}
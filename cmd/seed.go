@@ -0,0 +1,193 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/terkoizmy/go-blog-api/config"
+	"github.com/terkoizmy/go-blog-api/internal/auth"
+	"github.com/terkoizmy/go-blog-api/internal/db"
+	"github.com/terkoizmy/go-blog-api/internal/models"
+	"github.com/terkoizmy/go-blog-api/internal/repository"
+	"github.com/urfave/cli/v2"
+)
+
+var seedCommand = &cli.Command{
+	Name:  "seed",
+	Usage: "Add demo users, categories, posts and comments. Existing records are left alone.",
+	Flags: []cli.Flag{
+		&cli.StringFlag{Name: "password", Value: "password", Usage: "password of the demo users"},
+	},
+	Action: seed,
+}
+
+type seedUser struct {
+	username  string
+	firstName string
+	role      string
+}
+
+type seedPost struct {
+	slug       string
+	title      string
+	content    string
+	author     string
+	status     string
+	categories []string
+	comments   []seedComment
+}
+
+type seedComment struct {
+	author  string
+	content string
+}
+
+var seedUsers = []seedUser{
+	{username: "demo_editor", firstName: "Edith", role: auth.RoleEditor},
+	{username: "demo_author", firstName: "Arthur", role: auth.RoleAuthor},
+	{username: "demo_reader", firstName: "Rita", role: auth.RoleUser},
+}
+
+var seedCategories = []models.Category{
+	{Name: "Go", Slug: "go"},
+	{Name: "Web Development", Slug: "web-development"},
+	{Name: "Databases", Slug: "databases"},
+}
+
+var seedPosts = []seedPost{
+	{
+		slug:       "getting-started-with-go",
+		title:      "Getting Started with Go",
+		content:    "Go is a small language with a big standard library. This post walks through installing the toolchain and writing a first program.",
+		author:     "demo_author",
+		status:     "published",
+		categories: []string{"go"},
+		comments: []seedComment{
+			{author: "demo_reader", content: "Great introduction, thanks!"},
+			{author: "demo_editor", content: "Glad you liked it."},
+		},
+	},
+	{
+		slug:       "building-rest-apis-with-gin",
+		title:      "Building REST APIs with Gin",
+		content:    "Gin keeps HTTP handlers short. We build a small JSON API with routing, binding and middleware.",
+		author:     "demo_editor",
+		status:     "published",
+		categories: []string{"go", "web-development"},
+		comments: []seedComment{
+			{author: "demo_author", content: "The middleware section was really useful."},
+		},
+	},
+	{
+		slug:       "choosing-a-database",
+		title:      "Choosing a Database",
+		content:    "Postgres for production, SQLite for local development and tests: notes on keeping one schema working on both.",
+		author:     "demo_author",
+		status:     "draft",
+		categories: []string{"databases"},
+	},
+}
+
+func seed(c *cli.Context) error {
+	cfg, err := config.LoadConfig()
+	if err != nil {
+		return fmt.Errorf("failed to load config: %w", err)
+	}
+	if err := connect(cfg); err != nil {
+		return err
+	}
+
+	repos := repository.NewGormRepositories(db.DB)
+
+	hashedPassword, err := auth.HashPassword(c.String("password"))
+	if err != nil {
+		return fmt.Errorf("failed to hash password: %w", err)
+	}
+
+	users := make(map[string]models.User, len(seedUsers))
+	for _, u := range seedUsers {
+		user, err := repos.Users.FindByUsername(u.username)
+		if errors.Is(err, repository.ErrNotFound) {
+			now := time.Now()
+			user = models.User{
+				Username:        u.username,
+				Email:           u.username + "@example.com",
+				Password:        hashedPassword,
+				FirstName:       u.firstName,
+				LastName:        "Demo",
+				Role:            u.role,
+				EmailVerifiedAt: &now,
+			}
+			if err := repos.Users.Create(&user); err != nil {
+				return fmt.Errorf("failed to create user %s: %w", u.username, err)
+			}
+			fmt.Printf("Created user %s\n", u.username)
+		} else if err != nil {
+			return err
+		}
+		users[u.username] = user
+	}
+
+	categories := make(map[string]models.Category, len(seedCategories))
+	for _, cat := range seedCategories {
+		category, err := repos.Categories.FindBySlug(cat.Slug)
+		if errors.Is(err, repository.ErrNotFound) {
+			category = cat
+			if err := repos.Categories.Create(&category); err != nil {
+				return fmt.Errorf("failed to create category %s: %w", cat.Slug, err)
+			}
+			fmt.Printf("Created category %s\n", cat.Slug)
+		} else if err != nil {
+			return err
+		}
+		categories[cat.Slug] = category
+	}
+
+	for _, p := range seedPosts {
+		if exists, err := repos.Posts.SlugExists(p.slug, uuid.Nil); err != nil {
+			return err
+		} else if exists {
+			continue
+		}
+
+		post := models.Post{
+			Title:    p.title,
+			Slug:     p.slug,
+			Content:  p.content,
+			AuthorID: users[p.author].ID,
+			Status:   p.status,
+		}
+		if p.status == "published" {
+			now := time.Now()
+			post.PublishedAt = &now
+		}
+		if err := repos.Posts.Create(&post); err != nil {
+			return fmt.Errorf("failed to create post %s: %w", p.slug, err)
+		}
+
+		var categoryIDs []uuid.UUID
+		for _, slug := range p.categories {
+			categoryIDs = append(categoryIDs, categories[slug].ID)
+		}
+		if err := repos.Posts.SetCategories(&post, categoryIDs); err != nil {
+			return fmt.Errorf("failed to set categories of post %s: %w", p.slug, err)
+		}
+
+		for _, cm := range p.comments {
+			comment := models.Comment{
+				Content:  cm.content,
+				PostID:   post.ID,
+				AuthorID: users[cm.author].ID,
+			}
+			if err := repos.Comments.Create(&comment); err != nil {
+				return fmt.Errorf("failed to create comment on post %s: %w", p.slug, err)
+			}
+		}
+
+		fmt.Printf("Created post %s\n", p.slug)
+	}
+
+	return nil
+}
@@ -0,0 +1,100 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	swaggerfiles "github.com/swaggo/files"
+	ginSwagger "github.com/swaggo/gin-swagger"
+	"github.com/terkoizmy/go-blog-api/api/routes"
+	"github.com/terkoizmy/go-blog-api/config"
+	"github.com/terkoizmy/go-blog-api/internal/auth"
+	"github.com/terkoizmy/go-blog-api/internal/db"
+	"github.com/terkoizmy/go-blog-api/internal/mailer"
+	"github.com/terkoizmy/go-blog-api/internal/repository"
+	"github.com/terkoizmy/go-blog-api/internal/scheduler"
+	"github.com/urfave/cli/v2"
+)
+
+var serveCommand = &cli.Command{
+	Name:   "serve",
+	Usage:  "Start the API server",
+	Action: runServe,
+}
+
+func runServe(c *cli.Context) error {
+	// Load configuration
+	cfg, err := config.LoadConfig()
+	if err != nil {
+		return fmt.Errorf("failed to load config: %w", err)
+	}
+
+	// Set gin mode based on config
+	gin.SetMode(cfg.GinMode)
+
+	// Load JWT signing keys
+	if err := auth.InitKeys(cfg); err != nil {
+		return fmt.Errorf("failed to load JWT signing keys: %w", err)
+	}
+
+	// Register OpenID Connect providers
+	if err := auth.InitOIDC(cfg); err != nil {
+		return fmt.Errorf("failed to configure identity providers: %w", err)
+	}
+
+	// Initialize mailer
+	if err := mailer.InitMailer(cfg); err != nil {
+		return fmt.Errorf("failed to initialize mailer: %w", err)
+	}
+
+	// Initialize database
+	if err := connect(cfg); err != nil {
+		return err
+	}
+
+	// Repositories used by the handlers
+	repos := repository.NewGormRepositories(db.DB)
+
+	// Publish scheduled posts in the background
+	if cfg.SchedulerInterval > 0 {
+		scheduler.Start(context.Background(), repos.Posts, cfg.SchedulerInterval)
+	}
+
+	// Initialize router
+	router := gin.Default()
+
+	// Only take the client IP from X-Forwarded-For when set by our own proxies
+	if err := router.SetTrustedProxies(trustedProxies(cfg)); err != nil {
+		return fmt.Errorf("invalid TRUSTED_PROXIES: %w", err)
+	}
+
+	// Setup routes
+	routes.SetupUserRoutes(router, repos)
+	routes.SetupPostRoutes(router, repos)
+	routes.SetupCategoryRoutes(router, repos)
+	routes.SetupTagRoutes(router, repos)
+	routes.SetupCommentRoutes(router, repos)
+	routes.SetupSearchRoutes(router, repos)
+	routes.SetupRoleRoutes(router)
+
+	// Swagger documentation
+	router.GET("/swagger/*any", ginSwagger.WrapHandler(swaggerfiles.Handler))
+
+	// Start server
+	log.Printf("Server running on port %s", cfg.Port)
+	return router.Run(":" + cfg.Port)
+}
+
+// trustedProxies lists the addresses in TRUSTED_PROXIES, or nil for none
+func trustedProxies(cfg config.Config) []string {
+	var proxies []string
+	for _, proxy := range strings.Split(cfg.TrustedProxies, ",") {
+		if proxy = strings.TrimSpace(proxy); proxy != "" {
+			proxies = append(proxies, proxy)
+		}
+	}
+	return proxies
+}
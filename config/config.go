@@ -1,23 +1,118 @@
 package config
 
 import (
+	"fmt"
 	"log"
+	"strings"
+	"time"
 
 	"github.com/spf13/viper"
 )
 
+// EMAIL_VERIFICATION modes: what accounts with an unverified email address
+// are blocked from
+const (
+	EmailVerificationOff   = "off"
+	EmailVerificationLogin = "login"
+	EmailVerificationPosts = "posts"
+)
+
 type Config struct {
-	DatabaseURL string `mapstructure:"DATABASE_URL"`
-	Port        string `mapstructure:"PORT"`
-	GinMode     string `mapstructure:"GIN_MODE"`
-	DBSSLMode   string `mapstructure:"DB_SSLMODE"`
-	JWTSecret   string `mapstructure:"JWT_SECRET"`
+	DBDriver              string         `mapstructure:"DB_DRIVER"`
+	DatabaseURL           string         `mapstructure:"DATABASE_URL"`
+	MigrationsDir         string         `mapstructure:"MIGRATIONS_DIR"`
+	MigrateOnStart        bool           `mapstructure:"MIGRATE_ON_START"`
+	Port                  string         `mapstructure:"PORT"`
+	TrustedProxies        string         `mapstructure:"TRUSTED_PROXIES"`
+	PageDefaultLimit      int            `mapstructure:"PAGE_DEFAULT_LIMIT"`
+	PageMaxLimit          int            `mapstructure:"PAGE_MAX_LIMIT"`
+	SchedulerInterval     time.Duration  `mapstructure:"PUBLISH_SCHEDULER_INTERVAL"`
+	GinMode               string         `mapstructure:"GIN_MODE"`
+	DBSSLMode             string         `mapstructure:"DB_SSLMODE"`
+	JWTSecret             string         `mapstructure:"JWT_SECRET"`
+	JWTSigningMethod      string         `mapstructure:"JWT_SIGNING_METHOD"`
+	JWTKeysDir            string         `mapstructure:"JWT_KEYS_DIR"`
+	JWTActiveKid          string         `mapstructure:"JWT_ACTIVE_KID"`
+	JWTAcceptHS256        bool           `mapstructure:"JWT_ACCEPT_HS256"`
+	AccessTokenTTL        time.Duration  `mapstructure:"ACCESS_TOKEN_TTL"`
+	RefreshTokenTTL       time.Duration  `mapstructure:"REFRESH_TOKEN_TTL"`
+	PasswordResetTTL      time.Duration  `mapstructure:"PASSWORD_RESET_TTL"`
+	PasswordResetURL      string         `mapstructure:"PASSWORD_RESET_URL"`
+	EmailVerification     string         `mapstructure:"EMAIL_VERIFICATION"`
+	EmailVerificationTTL  time.Duration  `mapstructure:"EMAIL_VERIFICATION_TTL"`
+	EmailVerificationURL  string         `mapstructure:"EMAIL_VERIFICATION_URL"`
+	TOTPIssuer            string         `mapstructure:"TOTP_ISSUER"`
+	TwoFactorChallengeTTL time.Duration  `mapstructure:"TWO_FACTOR_CHALLENGE_TTL"`
+	LoginMaxAttempts      int            `mapstructure:"LOGIN_MAX_ATTEMPTS"`
+	LoginIPMaxAttempts    int            `mapstructure:"LOGIN_IP_MAX_ATTEMPTS"`
+	LoginAttemptWindow    time.Duration  `mapstructure:"LOGIN_ATTEMPT_WINDOW"`
+	LoginLockoutBase      time.Duration  `mapstructure:"LOGIN_LOCKOUT_BASE"`
+	LoginLockoutMax       time.Duration  `mapstructure:"LOGIN_LOCKOUT_MAX"`
+	Mailer                string         `mapstructure:"MAILER"`
+	MailerFileDir         string         `mapstructure:"MAILER_FILE_DIR"`
+	MailFrom              string         `mapstructure:"MAIL_FROM"`
+	SMTPHost              string         `mapstructure:"SMTP_HOST"`
+	SMTPPort              string         `mapstructure:"SMTP_PORT"`
+	SMTPUsername          string         `mapstructure:"SMTP_USERNAME"`
+	SMTPPassword          string         `mapstructure:"SMTP_PASSWORD"`
+	OIDCProviderNames     string         `mapstructure:"OIDC_PROVIDERS"`
+	OIDCRedirectBaseURL   string         `mapstructure:"OIDC_REDIRECT_BASE_URL"`
+	OIDCStateTTL          time.Duration  `mapstructure:"OIDC_STATE_TTL"`
+	OIDCProviders         []OIDCProvider `mapstructure:"-"`
+}
+
+// OIDCProvider configures an OpenID Connect identity provider. Each provider
+// listed in OIDC_PROVIDERS is read from OIDC_<NAME>_* variables.
+type OIDCProvider struct {
+	Name         string
+	Issuer       string
+	ClientID     string
+	ClientSecret string
+	RedirectURL  string
+	Scopes       []string
 }
 
 func LoadConfig() (config Config, err error) {
 	viper.SetConfigFile(".env")
 	viper.AutomaticEnv()
 
+	// Defaults for optional settings
+	viper.SetDefault("DB_DRIVER", "postgres")
+	viper.SetDefault("MIGRATIONS_DIR", "internal/migrations")
+	viper.SetDefault("MIGRATE_ON_START", false)
+	viper.SetDefault("TRUSTED_PROXIES", "")
+	viper.SetDefault("PAGE_DEFAULT_LIMIT", 10)
+	viper.SetDefault("PAGE_MAX_LIMIT", 100)
+	viper.SetDefault("PUBLISH_SCHEDULER_INTERVAL", "1m")
+	viper.SetDefault("JWT_SIGNING_METHOD", "HS256")
+	viper.SetDefault("JWT_KEYS_DIR", "")
+	viper.SetDefault("JWT_ACTIVE_KID", "")
+	viper.SetDefault("JWT_ACCEPT_HS256", false)
+	viper.SetDefault("ACCESS_TOKEN_TTL", "15m")
+	viper.SetDefault("REFRESH_TOKEN_TTL", "720h")
+	viper.SetDefault("PASSWORD_RESET_TTL", "1h")
+	viper.SetDefault("PASSWORD_RESET_URL", "")
+	viper.SetDefault("EMAIL_VERIFICATION", "off")
+	viper.SetDefault("EMAIL_VERIFICATION_TTL", "48h")
+	viper.SetDefault("EMAIL_VERIFICATION_URL", "")
+	viper.SetDefault("TOTP_ISSUER", "Blog API")
+	viper.SetDefault("TWO_FACTOR_CHALLENGE_TTL", "5m")
+	viper.SetDefault("LOGIN_MAX_ATTEMPTS", 5)
+	viper.SetDefault("LOGIN_IP_MAX_ATTEMPTS", 20)
+	viper.SetDefault("LOGIN_ATTEMPT_WINDOW", "15m")
+	viper.SetDefault("LOGIN_LOCKOUT_BASE", "1m")
+	viper.SetDefault("LOGIN_LOCKOUT_MAX", "1h")
+	viper.SetDefault("MAILER", "file")
+	viper.SetDefault("MAILER_FILE_DIR", "mail")
+	viper.SetDefault("MAIL_FROM", "no-reply@localhost")
+	viper.SetDefault("SMTP_HOST", "")
+	viper.SetDefault("SMTP_PORT", "587")
+	viper.SetDefault("SMTP_USERNAME", "")
+	viper.SetDefault("SMTP_PASSWORD", "")
+	viper.SetDefault("OIDC_PROVIDERS", "")
+	viper.SetDefault("OIDC_REDIRECT_BASE_URL", "http://localhost:8080")
+	viper.SetDefault("OIDC_STATE_TTL", "10m")
+
 	err = viper.ReadInConfig()
 	if err != nil {
 		log.Printf("Warning: Error reading config file: %v. Will try to use environment variables instead.", err)
@@ -25,5 +120,52 @@ func LoadConfig() (config Config, err error) {
 	}
 
 	err = viper.Unmarshal(&config)
+	if err != nil {
+		return
+	}
+
+	config.EmailVerification = strings.ToLower(strings.TrimSpace(config.EmailVerification))
+	switch config.EmailVerification {
+	case EmailVerificationOff, EmailVerificationLogin, EmailVerificationPosts:
+	default:
+		err = fmt.Errorf("EMAIL_VERIFICATION must be off, login or posts, not %q", config.EmailVerification)
+		return
+	}
+
+	config.OIDCProviders = loadOIDCProviders(config)
 	return
 }
+
+// loadOIDCProviders reads the settings of every provider named in
+// OIDC_PROVIDERS, e.g. OIDC_GOOGLE_ISSUER for a provider called "google"
+func loadOIDCProviders(config Config) []OIDCProvider {
+	var providers []OIDCProvider
+	for _, name := range strings.Split(config.OIDCProviderNames, ",") {
+		name = strings.ToLower(strings.TrimSpace(name))
+		if name == "" {
+			continue
+		}
+
+		prefix := "OIDC_" + strings.ToUpper(name) + "_"
+		scopes := strings.Fields(strings.ReplaceAll(viper.GetString(prefix+"SCOPES"), ",", " "))
+		if len(scopes) == 0 {
+			scopes = []string{"openid", "email", "profile"}
+		}
+
+		redirectURL := viper.GetString(prefix + "REDIRECT_URL")
+		if redirectURL == "" {
+			redirectURL = strings.TrimRight(config.OIDCRedirectBaseURL, "/") + "/api/v1/oauth/" + name + "/callback"
+		}
+
+		providers = append(providers, OIDCProvider{
+			Name:         name,
+			Issuer:       viper.GetString(prefix + "ISSUER"),
+			ClientID:     viper.GetString(prefix + "CLIENT_ID"),
+			ClientSecret: viper.GetString(prefix + "CLIENT_SECRET"),
+			RedirectURL:  redirectURL,
+			Scopes:       scopes,
+		})
+	}
+
+	return providers
+}
@@ -2,21 +2,13 @@ package auth
 
 import (
 	"net/http"
-	_ "strings"
+	"strings"
 
 	"github.com/gin-gonic/gin"
-	"github.com/terkoizmy/go-blog-api/config"
 )
 
 func AuthMiddleware() gin.HandlerFunc {
 	return func(c *gin.Context) {
-		cfg, err := config.LoadConfig()
-		if err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "couldn't load config"})
-			c.Abort()
-			return
-		}
-
 		authHeader := c.GetHeader("Authorization")
 		if authHeader == "" {
 			c.JSON(http.StatusUnauthorized, gin.H{"error": "authorization header is required"})
@@ -33,14 +25,50 @@ func AuthMiddleware() gin.HandlerFunc {
 		// 	return
 		// }
 
-		claims, err := ValidateToken(authHeader, cfg.JWTSecret)
+		token := strings.TrimPrefix(authHeader, "Bearer ")
+
+		// Personal access tokens are opaque and looked up in the database
+		if IsPersonalAccessToken(token) {
+			pat, user, err := AuthenticatePersonalAccessToken(token)
+			if err != nil {
+				c.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+				c.Abort()
+				return
+			}
+
+			c.Set("authMethod", "pat")
+			c.Set("scopes", pat.Scopes)
+			c.Set("userID", user.ID)
+			c.Set("username", user.Username)
+			c.Set("email", user.Email)
+			c.Set("role", user.Role)
+
+			c.Next()
+			return
+		}
+
+		claims, err := ValidateToken(token)
 		if err != nil {
 			c.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
 			c.Abort()
 			return
 		}
 
+		revoked, err := IsTokenRevoked(claims)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "couldn't check token status"})
+			c.Abort()
+			return
+		}
+		if revoked {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "token has been revoked"})
+			c.Abort()
+			return
+		}
+
 		// Add claims to request context
+		c.Set("authMethod", "jwt")
+		c.Set("claims", claims)
 		c.Set("userID", claims.UserID)
 		c.Set("username", claims.Username)
 		c.Set("email", claims.Email)
@@ -50,33 +78,64 @@ func AuthMiddleware() gin.HandlerFunc {
 	}
 }
 
-// RoleMiddleware checks if user has one of the specified roles
-func RoleMiddleware(roles ...string) gin.HandlerFunc {
+// OptionalAuthMiddleware authenticates requests that carry a token, like
+// AuthMiddleware, and lets the others through anonymously
+func OptionalAuthMiddleware() gin.HandlerFunc {
+	authenticate := AuthMiddleware()
 	return func(c *gin.Context) {
-		userRole, exists := c.Get("role")
-		if !exists {
-			c.JSON(http.StatusUnauthorized, gin.H{"error": "unauthorized"})
+		if c.GetHeader("Authorization") == "" {
+			c.Next()
+			return
+		}
+		authenticate(c)
+	}
+}
+
+// ScopeMiddleware requires personal access tokens to carry the given scope.
+// Requests authenticated with a JWT are not restricted.
+func ScopeMiddleware(scope string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if c.GetString("authMethod") != "pat" {
+			c.Next()
+			return
+		}
+
+		scopes, _ := c.Get("scopes")
+		granted, _ := scopes.([]string)
+		if !hasScope(granted, scope) {
+			c.JSON(http.StatusForbidden, gin.H{"error": "token is missing the " + scope + " scope"})
 			c.Abort()
 			return
 		}
 
-		roleStr, ok := userRole.(string)
-		if !ok {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "role type assertion failed"})
+		c.Next()
+	}
+}
+
+// SessionOnlyMiddleware rejects personal access tokens, for account security
+// operations that must only be done from an interactive login
+func SessionOnlyMiddleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if c.GetString("authMethod") == "pat" {
+			c.JSON(http.StatusForbidden, gin.H{"error": "personal access tokens can't be used for this operation"})
 			c.Abort()
 			return
 		}
 
-		// Check if user role is in the accepted roles
-		authorized := false
-		for _, role := range roles {
-			if role == roleStr {
-				authorized = true
-				break
-			}
+		c.Next()
+	}
+}
+
+// PermissionMiddleware requires the user's role to grant the permission
+func PermissionMiddleware(permission string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if _, exists := c.Get("role"); !exists {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "unauthorized"})
+			c.Abort()
+			return
 		}
 
-		if !authorized {
+		if !Can(c, permission) {
 			c.JSON(http.StatusForbidden, gin.H{"error": "insufficient permissions"})
 			c.Abort()
 			return
@@ -10,6 +10,10 @@ import (
 	"golang.org/x/crypto/bcrypt"
 )
 
+// challengeAudience marks tokens issued between the password and second factor
+// steps of a two-factor login
+const challengeAudience = "blog-api:2fa-challenge"
+
 type JWTClaim struct {
 	UserID   uuid.UUID `json:"user_id"`
 	Username string    `json:"username"`
@@ -18,14 +22,19 @@ type JWTClaim struct {
 	jwt.RegisteredClaims
 }
 
-func GenerateToken(user models.User, jwtSecret string) (tokenString string, err error) {
-	expirationTime := time.Now().Add(24 * time.Hour)
+func GenerateToken(user models.User, ttl time.Duration) (tokenString string, err error) {
+	if keys == nil {
+		return "", errors.New("signing keys not initialized")
+	}
+
+	expirationTime := time.Now().Add(ttl)
 	claims := &JWTClaim{
 		UserID:   user.ID,
 		Username: user.Username,
 		Email:    user.Email,
 		Role:     user.Role,
 		RegisteredClaims: jwt.RegisteredClaims{
+			ID:        uuid.New().String(),
 			ExpiresAt: jwt.NewNumericDate(expirationTime),
 			IssuedAt:  jwt.NewNumericDate(time.Now()),
 			NotBefore: jwt.NewNumericDate(time.Now()),
@@ -34,18 +43,80 @@ func GenerateToken(user models.User, jwtSecret string) (tokenString string, err
 		},
 	}
 
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
-	tokenString, err = token.SignedString([]byte(jwtSecret))
+	tokenString, err = keys.sign(claims)
 	return
 }
 
-func ValidateToken(signedToken string, jwtSecret string) (*JWTClaim, error) {
+func ValidateToken(signedToken string) (*JWTClaim, error) {
+	claims, err := parseToken(signedToken)
+	if err != nil {
+		return nil, err
+	}
+
+	// Two-factor challenge tokens must never be accepted as access tokens
+	if claims.VerifyAudience(challengeAudience, true) {
+		return nil, errors.New("invalid token")
+	}
+
+	return claims, nil
+}
+
+// GenerateChallengeToken issues a short-lived token proving the user passed the
+// password step of a two-factor login. It can only be exchanged at /login/2fa.
+func GenerateChallengeToken(user models.User, ttl time.Duration) (string, error) {
+	if keys == nil {
+		return "", errors.New("signing keys not initialized")
+	}
+
+	claims := &JWTClaim{
+		UserID: user.ID,
+		RegisteredClaims: jwt.RegisteredClaims{
+			ID:        uuid.New().String(),
+			Audience:  jwt.ClaimStrings{challengeAudience},
+			ExpiresAt: jwt.NewNumericDate(time.Now().Add(ttl)),
+			IssuedAt:  jwt.NewNumericDate(time.Now()),
+			NotBefore: jwt.NewNumericDate(time.Now()),
+			Issuer:    "blog-api",
+			Subject:   user.Username,
+		},
+	}
+
+	return keys.sign(claims)
+}
+
+// ValidateChallengeToken checks a challenge token that hasn't been used yet.
+// CompleteTwoFactorLogin uses it up along with the second factor.
+func ValidateChallengeToken(signedToken string) (*JWTClaim, error) {
+	claims, err := parseToken(signedToken)
+	if err != nil {
+		return nil, err
+	}
+
+	if !claims.VerifyAudience(challengeAudience, true) {
+		return nil, errors.New("invalid challenge token")
+	}
+
+	// Used challenges are recorded like revoked tokens
+	revoked, err := IsTokenRevoked(claims)
+	if err != nil {
+		return nil, err
+	}
+	if revoked {
+		return nil, ErrChallengeUsed
+	}
+
+	return claims, nil
+}
+
+func parseToken(signedToken string) (*JWTClaim, error) {
+	if keys == nil {
+		return nil, errors.New("signing keys not initialized")
+	}
+
 	token, err := jwt.ParseWithClaims(
 		signedToken,
 		&JWTClaim{},
-		func(token *jwt.Token) (interface{}, error) {
-			return []byte(jwtSecret), nil
-		},
+		keys.keyFunc,
 	)
 
 	if err != nil {
@@ -57,7 +128,7 @@ func ValidateToken(signedToken string, jwtSecret string) (*JWTClaim, error) {
 		return nil, errors.New("couldn't parse claims")
 	}
 
-	if claims.ExpiresAt.Time.Before(time.Now()) {
+	if claims.ExpiresAt == nil || claims.ExpiresAt.Time.Before(time.Now()) {
 		return nil, errors.New("token expired")
 	}
 
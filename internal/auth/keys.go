@@ -0,0 +1,235 @@
+package auth
+
+import (
+	"crypto"
+	"crypto/ed25519"
+	"crypto/rsa"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"math/big"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/golang-jwt/jwt/v4"
+	"github.com/terkoizmy/go-blog-api/config"
+)
+
+// signingKey is a single key that tokens can be signed or verified with.
+// Verification-only keys (retired keys kept for outstanding tokens) have no
+// private part.
+type signingKey struct {
+	kid     string
+	method  jwt.SigningMethod
+	private crypto.PrivateKey
+	public  crypto.PublicKey
+}
+
+// KeySet holds every key the API accepts and the one it currently signs with
+type KeySet struct {
+	activeKid string
+	keys      map[string]*signingKey
+}
+
+// JWK is a public key in JSON Web Key format
+type JWK struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid"`
+	Use string `json:"use"`
+	Alg string `json:"alg"`
+	N   string `json:"n,omitempty"`
+	E   string `json:"e,omitempty"`
+	Crv string `json:"crv,omitempty"`
+	X   string `json:"x,omitempty"`
+}
+
+// JWKSet is the document served from /.well-known/jwks.json
+type JWKSet struct {
+	Keys []JWK `json:"keys"`
+}
+
+// hmacKid identifies the shared-secret key when HS256 is in use
+const hmacKid = "hs256"
+
+var keys *KeySet
+
+// InitKeys loads the signing keys described by the config. It must be called
+// once at startup before tokens are generated or validated.
+func InitKeys(cfg config.Config) error {
+	ks, err := LoadKeySet(cfg)
+	if err != nil {
+		return err
+	}
+
+	keys = ks
+	return nil
+}
+
+// LoadKeySet builds a KeySet from the config. With JWT_SIGNING_METHOD=HS256 the
+// shared JWT_SECRET is used. With RS256 or EdDSA every "<kid>.pem" file in
+// JWT_KEYS_DIR is loaded and JWT_ACTIVE_KID selects the one used for signing;
+// the others stay valid for verification so keys can be rotated without
+// invalidating outstanding tokens. JWT_SECRET is then only accepted for
+// verification if JWT_ACCEPT_HS256 is set.
+func LoadKeySet(cfg config.Config) (*KeySet, error) {
+	ks := &KeySet{keys: map[string]*signingKey{}}
+
+	method := strings.ToUpper(cfg.JWTSigningMethod)
+	acceptHMAC := method == "" || method == "HS256" || cfg.JWTAcceptHS256
+	if cfg.JWTSecret != "" && acceptHMAC {
+		secret := []byte(cfg.JWTSecret)
+		ks.keys[hmacKid] = &signingKey{
+			kid:     hmacKid,
+			method:  jwt.SigningMethodHS256,
+			private: secret,
+			public:  secret,
+		}
+	}
+
+	if cfg.JWTKeysDir != "" {
+		files, err := filepath.Glob(filepath.Join(cfg.JWTKeysDir, "*.pem"))
+		if err != nil {
+			return nil, err
+		}
+
+		for _, file := range files {
+			key, err := loadPEMKey(file)
+			if err != nil {
+				return nil, err
+			}
+			// Tokens without a kid are verified with the shared secret
+			if key.kid == hmacKid {
+				return nil, fmt.Errorf("%s: key id %q is reserved for JWT_SECRET", file, hmacKid)
+			}
+			ks.keys[key.kid] = key
+		}
+	}
+
+	switch method {
+	case "", "HS256":
+		if _, ok := ks.keys[hmacKid]; !ok {
+			return nil, errors.New("JWT_SECRET is required for HS256 signing")
+		}
+		ks.activeKid = hmacKid
+	case "RS256", "EDDSA":
+		key, ok := ks.keys[cfg.JWTActiveKid]
+		if !ok || key.kid == hmacKid {
+			return nil, fmt.Errorf("active signing key %q not found in %s", cfg.JWTActiveKid, cfg.JWTKeysDir)
+		}
+		if !strings.EqualFold(key.method.Alg(), cfg.JWTSigningMethod) {
+			return nil, fmt.Errorf("active signing key %q is not a %s key", key.kid, cfg.JWTSigningMethod)
+		}
+		if key.private == nil {
+			return nil, fmt.Errorf("active signing key %q has no private key", key.kid)
+		}
+		ks.activeKid = key.kid
+	default:
+		return nil, fmt.Errorf("unsupported JWT signing method %q", cfg.JWTSigningMethod)
+	}
+
+	return ks, nil
+}
+
+// loadPEMKey reads an RSA or Ed25519 key, private or public, from a PEM file.
+// The file name without extension is used as the kid.
+func loadPEMKey(file string) (*signingKey, error) {
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+
+	key := &signingKey{kid: strings.TrimSuffix(filepath.Base(file), ".pem")}
+
+	if rsaKey, err := jwt.ParseRSAPrivateKeyFromPEM(data); err == nil {
+		key.method, key.private, key.public = jwt.SigningMethodRS256, rsaKey, &rsaKey.PublicKey
+		return key, nil
+	}
+	if rsaKey, err := jwt.ParseRSAPublicKeyFromPEM(data); err == nil {
+		key.method, key.public = jwt.SigningMethodRS256, rsaKey
+		return key, nil
+	}
+	if edKey, err := jwt.ParseEdPrivateKeyFromPEM(data); err == nil {
+		if private, ok := edKey.(ed25519.PrivateKey); ok {
+			key.method, key.private, key.public = jwt.SigningMethodEdDSA, private, private.Public()
+			return key, nil
+		}
+	}
+	if edKey, err := jwt.ParseEdPublicKeyFromPEM(data); err == nil {
+		key.method, key.public = jwt.SigningMethodEdDSA, edKey
+		return key, nil
+	}
+
+	return nil, fmt.Errorf("%s is not an RSA or Ed25519 PEM key", file)
+}
+
+func (ks *KeySet) sign(claims jwt.Claims) (string, error) {
+	key := ks.keys[ks.activeKid]
+
+	token := jwt.NewWithClaims(key.method, claims)
+	if key.kid != hmacKid {
+		token.Header["kid"] = key.kid
+	}
+
+	return token.SignedString(key.private)
+}
+
+// keyFunc selects the verification key by the token's kid and rejects tokens
+// whose algorithm doesn't match that key
+func (ks *KeySet) keyFunc(token *jwt.Token) (interface{}, error) {
+	kid, _ := token.Header["kid"].(string)
+	if kid == "" {
+		kid = hmacKid
+	}
+
+	key, ok := ks.keys[kid]
+	if !ok {
+		return nil, errors.New("unknown signing key")
+	}
+
+	if token.Method.Alg() != key.method.Alg() {
+		return nil, errors.New("unexpected signing method")
+	}
+
+	return key.public, nil
+}
+
+// JWKS returns the public part of every asymmetric key
+func (ks *KeySet) JWKS() JWKSet {
+	set := JWKSet{Keys: []JWK{}}
+
+	for _, key := range ks.keys {
+		switch public := key.public.(type) {
+		case *rsa.PublicKey:
+			set.Keys = append(set.Keys, JWK{
+				Kty: "RSA",
+				Kid: key.kid,
+				Use: "sig",
+				Alg: key.method.Alg(),
+				N:   base64.RawURLEncoding.EncodeToString(public.N.Bytes()),
+				E:   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(public.E)).Bytes()),
+			})
+		case ed25519.PublicKey:
+			set.Keys = append(set.Keys, JWK{
+				Kty: "OKP",
+				Kid: key.kid,
+				Use: "sig",
+				Alg: key.method.Alg(),
+				Crv: "Ed25519",
+				X:   base64.RawURLEncoding.EncodeToString(public),
+			})
+		}
+	}
+
+	sort.Slice(set.Keys, func(i, j int) bool { return set.Keys[i].Kid < set.Keys[j].Kid })
+	return set
+}
+
+// PublicJWKS returns the JWKS document for the keys loaded by InitKeys
+func PublicJWKS() (JWKSet, error) {
+	if keys == nil {
+		return JWKSet{}, errors.New("signing keys not initialized")
+	}
+	return keys.JWKS(), nil
+}
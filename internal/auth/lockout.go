@@ -0,0 +1,218 @@
+package auth
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/terkoizmy/go-blog-api/config"
+	"github.com/terkoizmy/go-blog-api/internal/db"
+	"github.com/terkoizmy/go-blog-api/internal/models"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// loginAttemptRetention is how long login attempts are kept for users to review
+const loginAttemptRetention = 30 * 24 * time.Hour
+
+// LockoutPolicy controls how failed logins are throttled
+type LockoutPolicy struct {
+	MaxAttempts   int
+	IPMaxAttempts int
+	Window        time.Duration
+	BaseDelay     time.Duration
+	MaxDelay      time.Duration
+}
+
+// LockedOutError is returned while an account or client address is locked out
+type LockedOutError struct {
+	RetryAfter time.Duration
+}
+
+func (e *LockedOutError) Error() string {
+	return "too many failed login attempts, try again later"
+}
+
+// LockoutPolicyFromConfig reads the lockout settings from the config
+func LockoutPolicyFromConfig(cfg config.Config) LockoutPolicy {
+	return LockoutPolicy{
+		MaxAttempts:   cfg.LoginMaxAttempts,
+		IPMaxAttempts: cfg.LoginIPMaxAttempts,
+		Window:        cfg.LoginAttemptWindow,
+		BaseDelay:     cfg.LoginLockoutBase,
+		MaxDelay:      cfg.LoginLockoutMax,
+	}
+}
+
+// CheckLoginAllowed returns a *LockedOutError if the client address or, when
+// userID is not nil, the account is currently locked out
+func CheckLoginAllowed(userID *uuid.UUID, ip string) error {
+	keys := []string{ipThrottleKey(ip)}
+	if userID != nil {
+		keys = append(keys, userThrottleKey(*userID))
+	}
+
+	var throttles []models.LoginThrottle
+	if err := db.DB.Where("key IN ?", keys).Find(&throttles).Error; err != nil {
+		return err
+	}
+
+	now := time.Now()
+	var retryAfter time.Duration
+	for _, t := range throttles {
+		if t.LockedUntil != nil && t.LockedUntil.After(now) {
+			if wait := t.LockedUntil.Sub(now); wait > retryAfter {
+				retryAfter = wait
+			}
+		}
+	}
+
+	if retryAfter > 0 {
+		return &LockedOutError{RetryAfter: retryAfter}
+	}
+	return nil
+}
+
+// RecordFailedLogin logs a failed attempt and bumps the failure counters of
+// the client address and, when userID is not nil, the account
+func RecordFailedLogin(userID *uuid.UUID, username string, ip string, policy LockoutPolicy) error {
+	return db.DB.Transaction(func(tx *gorm.DB) error {
+		if err := recordLoginAttempt(tx, userID, username, ip, false); err != nil {
+			return err
+		}
+
+		if err := bumpThrottle(tx, ipThrottleKey(ip), policy.IPMaxAttempts, policy); err != nil {
+			return err
+		}
+		if userID != nil {
+			return bumpThrottle(tx, userThrottleKey(*userID), policy.MaxAttempts, policy)
+		}
+		return nil
+	})
+}
+
+// RecordSuccessfulLogin logs a successful attempt and clears the account's
+// failure counter. The client address counter is left alone so one valid
+// account can't be used to reset it.
+func RecordSuccessfulLogin(userID uuid.UUID, username string, ip string) error {
+	return db.DB.Transaction(func(tx *gorm.DB) error {
+		if err := recordLoginAttempt(tx, &userID, username, ip, true); err != nil {
+			return err
+		}
+		return tx.Where("key = ?", userThrottleKey(userID)).Delete(&models.LoginThrottle{}).Error
+	})
+}
+
+// UnlockAccount lifts an account lockout and resets its failure counter
+func UnlockAccount(userID uuid.UUID) error {
+	return db.DB.Where("key = ?", userThrottleKey(userID)).Delete(&models.LoginThrottle{}).Error
+}
+
+// AccountLockedUntil returns when the account's lockout ends, or nil if it
+// isn't locked
+func AccountLockedUntil(userID uuid.UUID) (*time.Time, error) {
+	var throttle models.LoginThrottle
+	result := db.DB.Where("key = ?", userThrottleKey(userID)).Limit(1).Find(&throttle)
+	if result.Error != nil {
+		return nil, result.Error
+	}
+	if result.RowsAffected == 0 || throttle.LockedUntil == nil || !throttle.LockedUntil.After(time.Now()) {
+		return nil, nil
+	}
+	return throttle.LockedUntil, nil
+}
+
+// ListLoginAttempts returns the user's most recent login attempts, newest first
+func ListLoginAttempts(userID uuid.UUID, failedOnly bool, limit int) ([]models.LoginAttempt, error) {
+	query := db.DB.Where("user_id = ?", userID)
+	if failedOnly {
+		query = query.Where("succeeded = ?", false)
+	}
+
+	var attempts []models.LoginAttempt
+	err := query.Order("created_at DESC").Limit(limit).Find(&attempts).Error
+	return attempts, err
+}
+
+func recordLoginAttempt(tx *gorm.DB, userID *uuid.UUID, username string, ip string, succeeded bool) error {
+	// Drop attempts that are too old to be useful
+	tx.Where("created_at < ?", time.Now().Add(-loginAttemptRetention)).Delete(&models.LoginAttempt{})
+
+	return tx.Create(&models.LoginAttempt{
+		UserID:    userID,
+		Username:  username,
+		IPAddress: ip,
+		Succeeded: succeeded,
+	}).Error
+}
+
+// bumpThrottle counts another failure for key. Once maxAttempts is reached the
+// key is locked out, for twice as long with every further failure.
+func bumpThrottle(tx *gorm.DB, key string, maxAttempts int, policy LockoutPolicy) error {
+	if maxAttempts <= 0 {
+		return nil
+	}
+
+	// Create the row first, so concurrent first failures don't both insert it
+	// and the lock below always has a row to wait on
+	err := tx.Clauses(clause.OnConflict{Columns: []clause.Column{{Name: "key"}}, DoNothing: true}).
+		Create(&models.LoginThrottle{Key: key}).Error
+	if err != nil {
+		return err
+	}
+
+	now := time.Now()
+	var throttle models.LoginThrottle
+	if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Where("key = ?", key).First(&throttle).Error; err != nil {
+		return err
+	}
+
+	// Forget old failures once the window has passed since the last failure
+	// or the end of the last lockout
+	lastActivity := throttle.LastFailureAt
+	if throttle.LockedUntil != nil && throttle.LockedUntil.After(lastActivity) {
+		lastActivity = *throttle.LockedUntil
+	}
+	if now.Sub(lastActivity) > policy.Window {
+		throttle.Failures = 0
+		throttle.LockedUntil = nil
+	}
+
+	throttle.Failures++
+	throttle.LastFailureAt = now
+
+	if throttle.Failures >= maxAttempts {
+		lockedUntil := now.Add(lockoutDelay(throttle.Failures-maxAttempts, policy))
+		throttle.LockedUntil = &lockedUntil
+	}
+
+	return tx.Save(&throttle).Error
+}
+
+// lockoutDelay doubles the base delay for every failure past the threshold
+func lockoutDelay(extraFailures int, policy LockoutPolicy) time.Duration {
+	delay := policy.BaseDelay
+	for i := 0; i < extraFailures && delay < policy.MaxDelay; i++ {
+		delay *= 2
+	}
+	if policy.MaxDelay > 0 && delay > policy.MaxDelay {
+		delay = policy.MaxDelay
+	}
+	return delay
+}
+
+func userThrottleKey(userID uuid.UUID) string {
+	return fmt.Sprintf("user:%s", userID)
+}
+
+func ipThrottleKey(ip string) string {
+	return "ip:" + ip
+}
+
+// IsLockedOut reports whether err is a *LockedOutError and returns it
+func IsLockedOut(err error) (*LockedOutError, bool) {
+	var lockedOut *LockedOutError
+	ok := errors.As(err, &lockedOut)
+	return lockedOut, ok
+}
@@ -0,0 +1,189 @@
+package auth
+
+import (
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/terkoizmy/go-blog-api/internal/db"
+	"github.com/terkoizmy/go-blog-api/internal/models"
+)
+
+var testLockoutPolicy = LockoutPolicy{
+	MaxAttempts:   3,
+	IPMaxAttempts: 10,
+	Window:        15 * time.Minute,
+	BaseDelay:     time.Minute,
+	MaxDelay:      4 * time.Minute,
+}
+
+func TestLockoutDelay(t *testing.T) {
+	tests := []struct {
+		extraFailures int
+		want          time.Duration
+	}{
+		{0, time.Minute},
+		{1, 2 * time.Minute},
+		{2, 4 * time.Minute},
+		{3, 4 * time.Minute},
+		{100, 4 * time.Minute},
+	}
+	for _, tt := range tests {
+		if got := lockoutDelay(tt.extraFailures, testLockoutPolicy); got != tt.want {
+			t.Errorf("lockoutDelay(%d) = %v, want %v", tt.extraFailures, got, tt.want)
+		}
+	}
+}
+
+// throttle returns the failure counter stored for key
+func throttle(t *testing.T, key string) models.LoginThrottle {
+	t.Helper()
+	var throttle models.LoginThrottle
+	if err := db.DB.Where("key = ?", key).Limit(1).Find(&throttle).Error; err != nil {
+		t.Fatal(err)
+	}
+	return throttle
+}
+
+func TestRecordFailedLogin(t *testing.T) {
+	user := newTestUser(t)
+	const ip = "192.0.2.1"
+
+	fail := func() {
+		t.Helper()
+		if err := RecordFailedLogin(&user.ID, user.Username, ip, testLockoutPolicy); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	for i := 1; i < testLockoutPolicy.MaxAttempts; i++ {
+		fail()
+		if err := CheckLoginAllowed(&user.ID, ip); err != nil {
+			t.Fatalf("locked out after %d failures: %v", i, err)
+		}
+	}
+
+	// Reaching the threshold locks the account for the base delay, doubling
+	// with every further failure up to the maximum
+	for _, want := range []time.Duration{time.Minute, 2 * time.Minute, 4 * time.Minute, 4 * time.Minute} {
+		fail()
+		lockedOut, ok := IsLockedOut(CheckLoginAllowed(&user.ID, ip))
+		if !ok {
+			t.Fatalf("not locked out, want %v", want)
+		}
+		if lockedOut.RetryAfter > want || lockedOut.RetryAfter < want-time.Second {
+			t.Errorf("retry after %v, want %v", lockedOut.RetryAfter, want)
+		}
+	}
+
+	// The address is below its own threshold, so other accounts can still
+	// log in from it
+	if err := CheckLoginAllowed(nil, ip); err != nil {
+		t.Errorf("address locked out: %v", err)
+	}
+	if got := throttle(t, ipThrottleKey(ip)).Failures; got != testLockoutPolicy.MaxAttempts+3 {
+		t.Errorf("address has %d failures, want %d", got, testLockoutPolicy.MaxAttempts+3)
+	}
+}
+
+func TestFailedLoginWindow(t *testing.T) {
+	user := newTestUser(t)
+	const ip = "192.0.2.1"
+	key := userThrottleKey(user.ID)
+
+	for i := 0; i < testLockoutPolicy.MaxAttempts; i++ {
+		if err := RecordFailedLogin(&user.ID, user.Username, ip, testLockoutPolicy); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// Move the last failure and the end of the lockout back past the window
+	past := time.Now().Add(-testLockoutPolicy.Window - time.Minute)
+	if err := db.DB.Model(&models.LoginThrottle{}).Where("key = ?", key).
+		Updates(map[string]interface{}{"last_failure_at": past, "locked_until": past}).Error; err != nil {
+		t.Fatal(err)
+	}
+	if err := CheckLoginAllowed(&user.ID, ip); err != nil {
+		t.Fatalf("still locked out after the lockout ended: %v", err)
+	}
+
+	if err := RecordFailedLogin(&user.ID, user.Username, ip, testLockoutPolicy); err != nil {
+		t.Fatal(err)
+	}
+	if got := throttle(t, key); got.Failures != 1 || got.LockedUntil != nil {
+		t.Errorf("after the window: %d failures, locked until %v; want 1 and unlocked", got.Failures, got.LockedUntil)
+	}
+
+	// The end of a lockout counts as activity, so failing again within the
+	// window of it keeps counting
+	lockedUntil := time.Now().Add(-time.Minute)
+	if err := db.DB.Model(&models.LoginThrottle{}).Where("key = ?", key).
+		Updates(map[string]interface{}{"failures": testLockoutPolicy.MaxAttempts, "last_failure_at": past, "locked_until": lockedUntil}).Error; err != nil {
+		t.Fatal(err)
+	}
+	if err := RecordFailedLogin(&user.ID, user.Username, ip, testLockoutPolicy); err != nil {
+		t.Fatal(err)
+	}
+	if got := throttle(t, key); got.Failures != testLockoutPolicy.MaxAttempts+1 {
+		t.Errorf("within the window of the last lockout: %d failures, want %d", got.Failures, testLockoutPolicy.MaxAttempts+1)
+	}
+}
+
+func TestRecordSuccessfulLogin(t *testing.T) {
+	user := newTestUser(t)
+	const ip = "192.0.2.1"
+
+	for i := 0; i < testLockoutPolicy.MaxAttempts-1; i++ {
+		if err := RecordFailedLogin(&user.ID, user.Username, ip, testLockoutPolicy); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// Failures for unknown usernames only count against the address
+	if err := RecordFailedLogin(nil, "nobody", ip, testLockoutPolicy); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := RecordSuccessfulLogin(user.ID, user.Username, ip); err != nil {
+		t.Fatal(err)
+	}
+
+	if got := throttle(t, userThrottleKey(user.ID)).Failures; got != 0 {
+		t.Errorf("account has %d failures after a success, want 0", got)
+	}
+	if got := throttle(t, ipThrottleKey(ip)).Failures; got != testLockoutPolicy.MaxAttempts {
+		t.Errorf("address has %d failures after a success, want %d", got, testLockoutPolicy.MaxAttempts)
+	}
+
+	attempts, err := ListLoginAttempts(user.ID, false, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(attempts) != testLockoutPolicy.MaxAttempts || !attempts[0].Succeeded {
+		t.Errorf("got %d attempts, newest succeeded %v; want %d ending in a success", len(attempts), len(attempts) > 0 && attempts[0].Succeeded, testLockoutPolicy.MaxAttempts)
+	}
+}
+
+func TestUnlockAccount(t *testing.T) {
+	user := newTestUser(t)
+	other := uuid.New()
+	const ip = "192.0.2.1"
+
+	for i := 0; i < testLockoutPolicy.MaxAttempts; i++ {
+		if err := RecordFailedLogin(&user.ID, user.Username, ip, testLockoutPolicy); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if until, err := AccountLockedUntil(user.ID); err != nil || until == nil {
+		t.Fatalf("locked until %v, %v; want locked", until, err)
+	}
+
+	if err := UnlockAccount(user.ID); err != nil {
+		t.Fatal(err)
+	}
+	if err := CheckLoginAllowed(&user.ID, ip); err != nil {
+		t.Errorf("locked out after unlocking: %v", err)
+	}
+	if until, err := AccountLockedUntil(other); err != nil || until != nil {
+		t.Errorf("unknown account locked until %v, %v", until, err)
+	}
+}
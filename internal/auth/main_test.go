@@ -0,0 +1,21 @@
+package auth
+
+import (
+	"testing"
+
+	"github.com/terkoizmy/go-blog-api/internal/db"
+	"github.com/terkoizmy/go-blog-api/internal/dbtest"
+	"github.com/terkoizmy/go-blog-api/internal/models"
+)
+
+// newTestUser opens a fresh database and adds an account to it
+func newTestUser(t *testing.T) models.User {
+	t.Helper()
+
+	dbtest.Open(t)
+	user := models.User{Username: "alice", Email: "alice@example.com", Password: "-", Role: RoleUser}
+	if err := db.DB.Create(&user).Error; err != nil {
+		t.Fatal(err)
+	}
+	return user
+}
@@ -0,0 +1,444 @@
+package auth
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net/http"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/coreos/go-oidc/v3/oidc"
+	"github.com/google/uuid"
+	"github.com/terkoizmy/go-blog-api/config"
+	"github.com/terkoizmy/go-blog-api/internal/db"
+	"github.com/terkoizmy/go-blog-api/internal/models"
+	"golang.org/x/oauth2"
+	"gorm.io/gorm"
+)
+
+var (
+	ErrUnknownOIDCProvider   = errors.New("unknown identity provider")
+	ErrInvalidOAuthState     = errors.New("invalid or expired login request")
+	ErrOIDCEmailRequired     = errors.New("identity provider didn't return an email address")
+	ErrOIDCAccountExists     = errors.New("an account with this email already exists; log in and link the identity from your account instead")
+	ErrIdentityAlreadyLinked = errors.New("this identity is already linked to another account")
+	ErrIdentityNotFound      = errors.New("linked identity not found")
+)
+
+// OIDCIdentity is the verified result of an OpenID Connect login
+type OIDCIdentity struct {
+	Provider      string
+	Subject       string
+	Email         string
+	EmailVerified bool
+	Username      string
+	FirstName     string
+	LastName      string
+}
+
+// oidcProvider is a configured provider. Discovery happens on first use so the
+// API can start while a provider is unreachable.
+type oidcProvider struct {
+	oauth2   oauth2.Config
+	verifier *oidc.IDTokenVerifier
+}
+
+var (
+	oidcMu         sync.Mutex
+	oidcConfigs    = map[string]config.OIDCProvider{}
+	oidcProviders  = map[string]*oidcProvider{}
+	oidcHTTPClient *http.Client
+)
+
+var usernameCleaner = regexp.MustCompile(`[^a-zA-Z0-9_.-]+`)
+
+// InitOIDC registers the identity providers from the config
+func InitOIDC(cfg config.Config) error {
+	configs := map[string]config.OIDCProvider{}
+	for _, p := range cfg.OIDCProviders {
+		if p.Issuer == "" || p.ClientID == "" {
+			return fmt.Errorf("identity provider %q needs an issuer and a client ID", p.Name)
+		}
+		configs[p.Name] = p
+	}
+
+	oidcMu.Lock()
+	defer oidcMu.Unlock()
+	oidcConfigs = configs
+	oidcProviders = map[string]*oidcProvider{}
+	return nil
+}
+
+// SetOIDCHTTPClient replaces the HTTP client used to talk to identity
+// providers, e.g. to trust a local test issuer
+func SetOIDCHTTPClient(client *http.Client) {
+	oidcMu.Lock()
+	defer oidcMu.Unlock()
+	oidcHTTPClient = client
+	oidcProviders = map[string]*oidcProvider{}
+}
+
+// OIDCProviderNames returns the names of the configured identity providers
+func OIDCProviderNames() []string {
+	oidcMu.Lock()
+	defer oidcMu.Unlock()
+
+	names := make([]string, 0, len(oidcConfigs))
+	for name := range oidcConfigs {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// BeginOIDCLogin stores a pending authorization request and returns the
+// provider URL to send the user to. binding is a secret kept by the browser
+// that starts the request, e.g. in a cookie; only that browser can complete
+// it. Pass linkUserID to link the identity to an existing account instead of
+// logging in.
+func BeginOIDCLogin(ctx context.Context, providerName string, binding string, linkUserID *uuid.UUID, ttl time.Duration) (string, error) {
+	p, err := getOIDCProvider(ctx, providerName)
+	if err != nil {
+		return "", err
+	}
+
+	state, stateHash, err := GenerateOpaqueToken()
+	if err != nil {
+		return "", err
+	}
+	nonce, _, err := GenerateOpaqueToken()
+	if err != nil {
+		return "", err
+	}
+	verifier := oauth2.GenerateVerifier()
+
+	// Drop requests that were never completed
+	db.DB.Unscoped().Where("expires_at < ?", time.Now()).Delete(&models.OAuthState{})
+
+	if err := db.DB.Create(&models.OAuthState{
+		StateHash:    stateHash,
+		Provider:     providerName,
+		CodeVerifier: verifier,
+		Nonce:        nonce,
+		UserID:       linkUserID,
+		ExpiresAt:    time.Now().Add(ttl),
+		BindingHash:  HashToken(binding),
+	}).Error; err != nil {
+		return "", err
+	}
+
+	return p.oauth2.AuthCodeURL(state, oidc.Nonce(nonce), oauth2.S256ChallengeOption(verifier)), nil
+}
+
+// CompleteOIDCLogin consumes the authorization request identified by state,
+// checks it was started with the same binding, exchanges the code and
+// verifies the ID token. It also returns the user ID passed to
+// BeginOIDCLogin, if any.
+func CompleteOIDCLogin(ctx context.Context, providerName string, state string, binding string, code string) (OIDCIdentity, *uuid.UUID, error) {
+	p, err := getOIDCProvider(ctx, providerName)
+	if err != nil {
+		return OIDCIdentity{}, nil, err
+	}
+
+	var pending models.OAuthState
+	err = db.DB.Transaction(func(tx *gorm.DB) error {
+		result := tx.Where("state_hash = ? AND provider = ?", HashToken(state), providerName).Limit(1).Find(&pending)
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return ErrInvalidOAuthState
+		}
+
+		// Each request can only be completed once
+		return tx.Unscoped().Delete(&pending).Error
+	})
+	if err != nil {
+		return OIDCIdentity{}, nil, err
+	}
+	if pending.ExpiresAt.Before(time.Now()) {
+		return OIDCIdentity{}, nil, ErrInvalidOAuthState
+	}
+	// A request started in another browser, e.g. a link sent by an attacker,
+	// must not complete in this one
+	if subtle.ConstantTimeCompare([]byte(pending.BindingHash), []byte(HashToken(binding))) != 1 {
+		return OIDCIdentity{}, nil, ErrInvalidOAuthState
+	}
+
+	ctx = oidcContext(ctx)
+	token, err := p.oauth2.Exchange(ctx, code, oauth2.VerifierOption(pending.CodeVerifier))
+	if err != nil {
+		return OIDCIdentity{}, nil, fmt.Errorf("couldn't exchange authorization code: %w", err)
+	}
+
+	rawIDToken, ok := token.Extra("id_token").(string)
+	if !ok {
+		return OIDCIdentity{}, nil, errors.New("identity provider didn't return an ID token")
+	}
+
+	idToken, err := p.verifier.Verify(ctx, rawIDToken)
+	if err != nil {
+		return OIDCIdentity{}, nil, fmt.Errorf("invalid ID token: %w", err)
+	}
+	if idToken.Nonce != pending.Nonce {
+		return OIDCIdentity{}, nil, errors.New("invalid ID token: nonce mismatch")
+	}
+
+	var claims struct {
+		Email             string `json:"email"`
+		EmailVerified     bool   `json:"email_verified"`
+		PreferredUsername string `json:"preferred_username"`
+		Nickname          string `json:"nickname"`
+		GivenName         string `json:"given_name"`
+		FamilyName        string `json:"family_name"`
+	}
+	if err := idToken.Claims(&claims); err != nil {
+		return OIDCIdentity{}, nil, fmt.Errorf("invalid ID token claims: %w", err)
+	}
+
+	username := claims.PreferredUsername
+	if username == "" {
+		username = claims.Nickname
+	}
+
+	return OIDCIdentity{
+		Provider:      providerName,
+		Subject:       idToken.Subject,
+		Email:         strings.TrimSpace(claims.Email),
+		EmailVerified: claims.EmailVerified,
+		Username:      username,
+		FirstName:     claims.GivenName,
+		LastName:      claims.FamilyName,
+	}, pending.UserID, nil
+}
+
+// ResolveOIDCUser returns the user an identity belongs to. Unknown identities
+// are linked to the account with the same email when both sides have verified
+// it, and otherwise get a new account.
+func ResolveOIDCUser(identity OIDCIdentity) (models.User, error) {
+	var user models.User
+
+	var linked models.LinkedIdentity
+	result := db.DB.Where("provider = ? AND subject = ?", identity.Provider, identity.Subject).Limit(1).Find(&linked)
+	if result.Error != nil {
+		return models.User{}, result.Error
+	}
+	if result.RowsAffected > 0 {
+		if err := db.DB.Where("id = ?", linked.UserID).First(&user).Error; err != nil {
+			return models.User{}, err
+		}
+
+		now := time.Now()
+		db.DB.Model(&linked).Updates(map[string]interface{}{"last_login_at": now, "email": identity.Email})
+		return user, nil
+	}
+
+	if identity.Email == "" {
+		return models.User{}, ErrOIDCEmailRequired
+	}
+
+	result = db.DB.Where("email = ?", identity.Email).Limit(1).Find(&user)
+	if result.Error != nil {
+		return models.User{}, result.Error
+	}
+	if result.RowsAffected > 0 {
+		// Linking by email is only safe if both sides proved they own it
+		if !identity.EmailVerified || user.EmailVerifiedAt == nil {
+			return models.User{}, ErrOIDCAccountExists
+		}
+		if _, err := LinkOIDCIdentity(user.ID, identity); err != nil {
+			return models.User{}, err
+		}
+		return user, nil
+	}
+
+	return provisionOIDCUser(identity)
+}
+
+// LinkOIDCIdentity links an identity to the user
+func LinkOIDCIdentity(userID uuid.UUID, identity OIDCIdentity) (models.LinkedIdentity, error) {
+	var linked models.LinkedIdentity
+	result := db.DB.Where("provider = ? AND subject = ?", identity.Provider, identity.Subject).Limit(1).Find(&linked)
+	if result.Error != nil {
+		return models.LinkedIdentity{}, result.Error
+	}
+	if result.RowsAffected > 0 {
+		if linked.UserID != userID {
+			return models.LinkedIdentity{}, ErrIdentityAlreadyLinked
+		}
+		return linked, nil
+	}
+
+	now := time.Now()
+	linked = models.LinkedIdentity{
+		UserID:      userID,
+		Provider:    identity.Provider,
+		Subject:     identity.Subject,
+		Email:       identity.Email,
+		LastLoginAt: &now,
+	}
+	if err := db.DB.Create(&linked).Error; err != nil {
+		return models.LinkedIdentity{}, err
+	}
+
+	return linked, nil
+}
+
+// ListLinkedIdentities returns the identities linked to the user
+func ListLinkedIdentities(userID uuid.UUID) ([]models.LinkedIdentity, error) {
+	var identities []models.LinkedIdentity
+	err := db.DB.Where("user_id = ?", userID).Order("created_at").Find(&identities).Error
+	return identities, err
+}
+
+// UnlinkIdentity removes one of the user's linked identities
+func UnlinkIdentity(userID uuid.UUID, identityID uuid.UUID) error {
+	result := db.DB.Unscoped().Where("id = ? AND user_id = ?", identityID, userID).Delete(&models.LinkedIdentity{})
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return ErrIdentityNotFound
+	}
+	return nil
+}
+
+// provisionOIDCUser creates an account for a first-time identity. The account
+// gets a random password, which the user can replace with a password reset.
+func provisionOIDCUser(identity OIDCIdentity) (models.User, error) {
+	password, _, err := GenerateOpaqueToken()
+	if err != nil {
+		return models.User{}, err
+	}
+	hashedPassword, err := HashPassword(password)
+	if err != nil {
+		return models.User{}, err
+	}
+
+	username, err := availableUsername(identity)
+	if err != nil {
+		return models.User{}, err
+	}
+
+	user := models.User{
+		Username:  username,
+		Email:     identity.Email,
+		Password:  hashedPassword,
+		FirstName: identity.FirstName,
+		LastName:  identity.LastName,
+		Role:      DefaultRole,
+	}
+	if identity.EmailVerified {
+		now := time.Now()
+		user.EmailVerifiedAt = &now
+	}
+
+	err = db.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(&user).Error; err != nil {
+			return err
+		}
+
+		now := time.Now()
+		return tx.Create(&models.LinkedIdentity{
+			UserID:      user.ID,
+			Provider:    identity.Provider,
+			Subject:     identity.Subject,
+			Email:       identity.Email,
+			LastLoginAt: &now,
+		}).Error
+	})
+	if err != nil {
+		return models.User{}, err
+	}
+
+	return user, nil
+}
+
+// availableUsername derives a free username from the identity's preferred
+// username or email address
+func availableUsername(identity OIDCIdentity) (string, error) {
+	base := identity.Username
+	if base == "" {
+		base = strings.SplitN(identity.Email, "@", 2)[0]
+	}
+	base = usernameCleaner.ReplaceAllString(base, "")
+	if len(base) > 40 {
+		base = base[:40]
+	}
+	for len(base) < 3 {
+		base += "_"
+	}
+
+	candidate := base
+	for i := 0; i < 5; i++ {
+		var count int64
+		if err := db.DB.Model(&models.User{}).Where("username = ?", candidate).Count(&count).Error; err != nil {
+			return "", err
+		}
+		if count == 0 {
+			return candidate, nil
+		}
+
+		suffix := make([]byte, 3)
+		if _, err := rand.Read(suffix); err != nil {
+			return "", err
+		}
+		candidate = base + "-" + hex.EncodeToString(suffix)
+	}
+
+	return "", errors.New("couldn't find a free username")
+}
+
+func getOIDCProvider(ctx context.Context, name string) (*oidcProvider, error) {
+	oidcMu.Lock()
+	defer oidcMu.Unlock()
+
+	if p, ok := oidcProviders[name]; ok {
+		return p, nil
+	}
+
+	cfg, ok := oidcConfigs[name]
+	if !ok {
+		return nil, ErrUnknownOIDCProvider
+	}
+
+	provider, err := oidc.NewProvider(oidcContextLocked(ctx), cfg.Issuer)
+	if err != nil {
+		return nil, fmt.Errorf("couldn't discover identity provider %q: %w", name, err)
+	}
+
+	p := &oidcProvider{
+		oauth2: oauth2.Config{
+			ClientID:     cfg.ClientID,
+			ClientSecret: cfg.ClientSecret,
+			RedirectURL:  cfg.RedirectURL,
+			Endpoint:     provider.Endpoint(),
+			Scopes:       cfg.Scopes,
+		},
+		verifier: provider.Verifier(&oidc.Config{ClientID: cfg.ClientID}),
+	}
+	oidcProviders[name] = p
+	return p, nil
+}
+
+// oidcContext attaches the configured HTTP client to ctx for the oauth2 and
+// oidc packages
+func oidcContext(ctx context.Context) context.Context {
+	oidcMu.Lock()
+	defer oidcMu.Unlock()
+	return oidcContextLocked(ctx)
+}
+
+func oidcContextLocked(ctx context.Context) context.Context {
+	if oidcHTTPClient == nil {
+		return ctx
+	}
+	return oidc.ClientContext(ctx, oidcHTTPClient)
+}
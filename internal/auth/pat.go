@@ -0,0 +1,109 @@
+package auth
+
+import (
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/terkoizmy/go-blog-api/internal/db"
+	"github.com/terkoizmy/go-blog-api/internal/models"
+	"gorm.io/gorm"
+)
+
+// PersonalAccessTokenPrefix starts every personal access token so they can be
+// told apart from JWTs in the Authorization header
+const PersonalAccessTokenPrefix = "bpat_"
+
+// lastUsedResolution limits how often last_used_at is written for busy tokens
+const lastUsedResolution = time.Minute
+
+var (
+	ErrInvalidPersonalAccessToken  = errors.New("invalid or expired personal access token")
+	ErrPersonalAccessTokenNotFound = errors.New("personal access token not found")
+)
+
+// IsPersonalAccessToken reports whether the token looks like a personal access
+// token rather than a JWT
+func IsPersonalAccessToken(token string) bool {
+	return strings.HasPrefix(token, PersonalAccessTokenPrefix)
+}
+
+// CreatePersonalAccessToken stores a new token for the user and returns the
+// record together with the plain token, which is not retrievable afterwards
+func CreatePersonalAccessToken(userID uuid.UUID, name string, scopes []string, expiresAt *time.Time) (models.PersonalAccessToken, string, error) {
+	secret, _, err := GenerateOpaqueToken()
+	if err != nil {
+		return models.PersonalAccessToken{}, "", err
+	}
+
+	token := PersonalAccessTokenPrefix + secret
+	record := models.PersonalAccessToken{
+		UserID:      userID,
+		Name:        name,
+		TokenPrefix: token[:len(PersonalAccessTokenPrefix)+6],
+		TokenHash:   HashToken(token),
+		Scopes:      scopes,
+		ExpiresAt:   expiresAt,
+	}
+
+	if err := db.DB.Create(&record).Error; err != nil {
+		return models.PersonalAccessToken{}, "", err
+	}
+
+	return record, token, nil
+}
+
+// ListPersonalAccessTokens returns all tokens of the user, newest first
+func ListPersonalAccessTokens(userID uuid.UUID) ([]models.PersonalAccessToken, error) {
+	var tokens []models.PersonalAccessToken
+	err := db.DB.Where("user_id = ?", userID).Order("created_at DESC").Find(&tokens).Error
+	return tokens, err
+}
+
+// RevokePersonalAccessToken revokes one of the user's tokens
+func RevokePersonalAccessToken(userID uuid.UUID, tokenID uuid.UUID) error {
+	result := db.DB.Model(&models.PersonalAccessToken{}).
+		Where("id = ? AND user_id = ? AND revoked_at IS NULL", tokenID, userID).
+		Update("revoked_at", time.Now())
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return ErrPersonalAccessTokenNotFound
+	}
+	return nil
+}
+
+// revokeUserPersonalAccessTokens revokes every outstanding personal access
+// token of a user
+func revokeUserPersonalAccessTokens(tx *gorm.DB, userID uuid.UUID) error {
+	return tx.Model(&models.PersonalAccessToken{}).
+		Where("user_id = ? AND revoked_at IS NULL", userID).
+		Update("revoked_at", time.Now()).Error
+}
+
+// AuthenticatePersonalAccessToken looks up a token presented in a request and
+// returns it with its owner, recording when it was last used
+func AuthenticatePersonalAccessToken(token string) (models.PersonalAccessToken, models.User, error) {
+	var record models.PersonalAccessToken
+	if result := db.DB.Where("token_hash = ?", HashToken(token)).First(&record); result.Error != nil {
+		return models.PersonalAccessToken{}, models.User{}, ErrInvalidPersonalAccessToken
+	}
+
+	now := time.Now()
+	if record.RevokedAt != nil || (record.ExpiresAt != nil && record.ExpiresAt.Before(now)) {
+		return models.PersonalAccessToken{}, models.User{}, ErrInvalidPersonalAccessToken
+	}
+
+	var user models.User
+	if result := db.DB.Where("id = ?", record.UserID).First(&user); result.Error != nil {
+		return models.PersonalAccessToken{}, models.User{}, ErrInvalidPersonalAccessToken
+	}
+
+	if record.LastUsedAt == nil || now.Sub(*record.LastUsedAt) > lastUsedResolution {
+		db.DB.Model(&record).UpdateColumn("last_used_at", now)
+	}
+
+	return record, user, nil
+}
@@ -0,0 +1,74 @@
+package auth
+
+// Permissions that can be granted to roles
+const (
+	PermPostsPublish     = "posts:publish"
+	PermPostsReview      = "posts:review"
+	PermPostsEditAny     = "posts:edit_any"
+	PermPostsDeleteAny   = "posts:delete_any"
+	PermCommentsModerate = "comments:moderate"
+	PermCategoriesManage = "categories:manage"
+	PermTagsManage       = "tags:manage"
+	PermUsersManage      = "users:manage"
+	PermRolesManage      = "roles:manage"
+)
+
+var Permissions = []string{
+	PermPostsPublish,
+	PermPostsReview,
+	PermPostsEditAny,
+	PermPostsDeleteAny,
+	PermCommentsModerate,
+	PermCategoriesManage,
+	PermTagsManage,
+	PermUsersManage,
+	PermRolesManage,
+}
+
+// Built-in roles. They are created at startup and can't be deleted.
+const (
+	RoleAdmin     = "admin"
+	RoleEditor    = "editor"
+	RoleAuthor    = "author"
+	RoleModerator = "moderator"
+	RoleUser      = "user"
+)
+
+// DefaultRole is assigned to newly registered users
+const DefaultRole = RoleUser
+
+type builtInRole struct {
+	name        string
+	description string
+	permissions []string
+}
+
+// builtInRoles holds the permissions each built-in role starts with. Apart
+// from admin, which always has every permission, they can be changed later,
+// so permissions added to them afterwards need a migration to reach
+// existing databases.
+var builtInRoles = []builtInRole{
+	{RoleAdmin, "Full access to everything", Permissions},
+	{RoleEditor, "Reviews, publishes and edits any post, moderates comments and manages categories and tags", []string{
+		PermPostsPublish,
+		PermPostsReview,
+		PermPostsEditAny,
+		PermPostsDeleteAny,
+		PermCommentsModerate,
+		PermCategoriesManage,
+		PermTagsManage,
+	}},
+	{RoleAuthor, "Trusted writer who publishes their own posts without review", []string{PermPostsPublish}},
+	{RoleModerator, "Moderates comments", []string{PermCommentsModerate}},
+	{RoleUser, "Default role for registered users, whose posts go through review", []string{}},
+}
+
+// IsValidPermission reports whether permission can be granted to a role
+func IsValidPermission(permission string) bool {
+	for _, p := range Permissions {
+		if p == permission {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,67 @@
+package auth
+
+import (
+	"log"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+// Can reports whether the authenticated user's role grants the permission.
+// Lookup errors are logged and treated as a denial.
+func Can(c *gin.Context, permission string) bool {
+	allowed, err := RoleHasPermission(c.GetString("role"), permission)
+	if err != nil {
+		log.Printf("Failed to check permission %s: %v", permission, err)
+		return false
+	}
+	return allowed
+}
+
+// CanModify reports whether the authenticated user owns a resource or has the
+// permission to act on anyone's
+func CanModify(c *gin.Context, ownerID uuid.UUID, permission string) bool {
+	userID, exists := c.Get("userID")
+	if !exists {
+		return false
+	}
+
+	if id, ok := userID.(uuid.UUID); ok && id == ownerID {
+		return true
+	}
+
+	return Can(c, permission)
+}
+
+// CanSeeUnpublished reports whether the authenticated user may see the
+// unpublished posts of an author: their own, or anyone's when reviewing or
+// editing posts
+func CanSeeUnpublished(c *gin.Context, authorID uuid.UUID) bool {
+	return CanModify(c, authorID, PermPostsEditAny) || Can(c, PermPostsReview)
+}
+
+// CanManageUser reports whether the authenticated user may change the
+// credentials of, or delete, the user with the given ID and role. Besides
+// users:manage, that takes roles:manage when the user's role grants anything
+// the authenticated user's role doesn't, so managing users can't be used to
+// take over more privileged accounts.
+func CanManageUser(c *gin.Context, userID uuid.UUID, role string) bool {
+	currentID, exists := c.Get("userID")
+	if !exists {
+		return false
+	}
+	if id, ok := currentID.(uuid.UUID); ok && id == userID {
+		return true
+	}
+
+	if !Can(c, PermUsersManage) {
+		return false
+	}
+
+	outranks, err := RoleOutranks(role, c.GetString("role"))
+	if err != nil {
+		log.Printf("Failed to compare roles %s and %s: %v", role, c.GetString("role"), err)
+		return false
+	}
+	return !outranks || Can(c, PermRolesManage)
+}
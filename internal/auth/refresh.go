@@ -0,0 +1,145 @@
+package auth
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/terkoizmy/go-blog-api/internal/db"
+	"github.com/terkoizmy/go-blog-api/internal/models"
+	"gorm.io/gorm"
+)
+
+var (
+	ErrInvalidRefreshToken = errors.New("invalid refresh token")
+	ErrRefreshTokenExpired = errors.New("refresh token expired")
+	ErrRefreshTokenReused  = errors.New("refresh token reuse detected")
+)
+
+// GenerateOpaqueToken returns a random URL-safe token and its SHA-256 hash.
+// Only the hash should ever be stored.
+func GenerateOpaqueToken() (token string, hash string, err error) {
+	buf := make([]byte, 32)
+	if _, err = rand.Read(buf); err != nil {
+		return "", "", err
+	}
+
+	token = base64.RawURLEncoding.EncodeToString(buf)
+	return token, HashToken(token), nil
+}
+
+// HashToken hashes an opaque token for storage and lookup
+func HashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+// IssueRefreshToken stores a new refresh token for the user. Pass uuid.Nil as
+// familyID to start a new token family (e.g. on login).
+func IssueRefreshToken(userID uuid.UUID, familyID uuid.UUID, ttl time.Duration) (string, error) {
+	return issueRefreshToken(db.DB, userID, familyID, ttl)
+}
+
+func issueRefreshToken(tx *gorm.DB, userID uuid.UUID, familyID uuid.UUID, ttl time.Duration) (string, error) {
+	token, hash, err := GenerateOpaqueToken()
+	if err != nil {
+		return "", err
+	}
+
+	if familyID == uuid.Nil {
+		familyID = uuid.New()
+	}
+
+	record := models.RefreshToken{
+		UserID:    userID,
+		FamilyID:  familyID,
+		TokenHash: hash,
+		ExpiresAt: time.Now().Add(ttl),
+	}
+
+	if err := tx.Create(&record).Error; err != nil {
+		return "", err
+	}
+
+	return token, nil
+}
+
+// RotateRefreshToken exchanges a refresh token for a new one in the same
+// family and returns the owning user. Presenting a token that has already been
+// rotated revokes every token in its family.
+func RotateRefreshToken(token string, ttl time.Duration) (user models.User, newToken string, err error) {
+	reused := false
+
+	err = db.DB.Transaction(func(tx *gorm.DB) error {
+		var record models.RefreshToken
+		if result := tx.Where("token_hash = ?", HashToken(token)).First(&record); result.Error != nil {
+			return ErrInvalidRefreshToken
+		}
+
+		if record.RevokedAt != nil {
+			return ErrInvalidRefreshToken
+		}
+
+		if record.RotatedAt != nil {
+			reused = true
+			return nil
+		}
+
+		now := time.Now()
+		if record.ExpiresAt.Before(now) {
+			return ErrRefreshTokenExpired
+		}
+
+		// Guard against two concurrent refreshes with the same token
+		result := tx.Model(&models.RefreshToken{}).
+			Where("id = ? AND rotated_at IS NULL", record.ID).
+			Update("rotated_at", now)
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			reused = true
+			return nil
+		}
+
+		if result := tx.Where("id = ?", record.UserID).First(&user); result.Error != nil {
+			return ErrInvalidRefreshToken
+		}
+
+		newToken, err = issueRefreshToken(tx, record.UserID, record.FamilyID, ttl)
+		return err
+	})
+
+	if reused {
+		if err := RevokeRefreshTokenFamily(token); err != nil {
+			return models.User{}, "", err
+		}
+		return models.User{}, "", ErrRefreshTokenReused
+	}
+
+	return user, newToken, err
+}
+
+// RevokeRefreshTokenFamily revokes every token issued in the same family as
+// the given token
+func RevokeRefreshTokenFamily(token string) error {
+	var record models.RefreshToken
+	if result := db.DB.Where("token_hash = ?", HashToken(token)).First(&record); result.Error != nil {
+		return ErrInvalidRefreshToken
+	}
+
+	return db.DB.Model(&models.RefreshToken{}).
+		Where("family_id = ? AND revoked_at IS NULL", record.FamilyID).
+		Update("revoked_at", time.Now()).Error
+}
+
+// revokeUserRefreshTokens revokes every outstanding refresh token of a user
+func revokeUserRefreshTokens(tx *gorm.DB, userID uuid.UUID) error {
+	return tx.Model(&models.RefreshToken{}).
+		Where("user_id = ? AND revoked_at IS NULL", userID).
+		Update("revoked_at", time.Now()).Error
+}
@@ -0,0 +1,97 @@
+package auth
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/terkoizmy/go-blog-api/internal/db"
+	"github.com/terkoizmy/go-blog-api/internal/models"
+)
+
+func TestRotateRefreshToken(t *testing.T) {
+	user := newTestUser(t)
+
+	token, err := IssueRefreshToken(user.ID, uuid.Nil, time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	owner, rotated, err := RotateRefreshToken(token, time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if owner.ID != user.ID {
+		t.Errorf("rotated for %s, want %s", owner.ID, user.ID)
+	}
+	if rotated == "" || rotated == token {
+		t.Fatalf("rotation returned %q", rotated)
+	}
+
+	var first, second models.RefreshToken
+	db.DB.Where("token_hash = ?", HashToken(token)).First(&first)
+	db.DB.Where("token_hash = ?", HashToken(rotated)).First(&second)
+	if first.RotatedAt == nil {
+		t.Error("old token isn't marked as rotated")
+	}
+	if second.FamilyID != first.FamilyID {
+		t.Errorf("new token is in family %s, want %s", second.FamilyID, first.FamilyID)
+	}
+
+	// The new token rotates in turn
+	if _, _, err := RotateRefreshToken(rotated, time.Hour); err != nil {
+		t.Errorf("rotate the new token: %v", err)
+	}
+
+	if _, _, err := RotateRefreshToken("not-a-token", time.Hour); !errors.Is(err, ErrInvalidRefreshToken) {
+		t.Errorf("unknown token: got %v, want ErrInvalidRefreshToken", err)
+	}
+}
+
+func TestRefreshTokenReuseRevokesFamily(t *testing.T) {
+	user := newTestUser(t)
+
+	token, err := IssueRefreshToken(user.ID, uuid.Nil, time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, rotated, err := RotateRefreshToken(token, time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Another login of the same user is a separate family
+	other, err := IssueRefreshToken(user.ID, uuid.Nil, time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, _, err := RotateRefreshToken(token, time.Hour); !errors.Is(err, ErrRefreshTokenReused) {
+		t.Fatalf("reuse: got %v, want ErrRefreshTokenReused", err)
+	}
+	if _, _, err := RotateRefreshToken(rotated, time.Hour); !errors.Is(err, ErrInvalidRefreshToken) {
+		t.Errorf("latest token of the family after reuse: got %v, want ErrInvalidRefreshToken", err)
+	}
+	if _, _, err := RotateRefreshToken(other, time.Hour); err != nil {
+		t.Errorf("token of another family: %v", err)
+	}
+}
+
+func TestRefreshTokenExpiry(t *testing.T) {
+	user := newTestUser(t)
+
+	token, err := IssueRefreshToken(user.ID, uuid.Nil, -time.Minute)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, _, err := RotateRefreshToken(token, time.Hour); !errors.Is(err, ErrRefreshTokenExpired) {
+		t.Fatalf("expired token: got %v, want ErrRefreshTokenExpired", err)
+	}
+
+	// A failed rotation doesn't use the token up, so it still reads as expired
+	// rather than reused
+	if _, _, err := RotateRefreshToken(token, time.Hour); !errors.Is(err, ErrRefreshTokenExpired) {
+		t.Errorf("expired token again: got %v, want ErrRefreshTokenExpired", err)
+	}
+}
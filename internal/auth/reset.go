@@ -0,0 +1,82 @@
+package auth
+
+import (
+	"errors"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/terkoizmy/go-blog-api/internal/db"
+	"github.com/terkoizmy/go-blog-api/internal/models"
+	"gorm.io/gorm"
+)
+
+var ErrInvalidResetToken = errors.New("invalid or expired reset token")
+
+// CreatePasswordResetToken stores a new single-use reset token for the user
+// and returns the plain token to be emailed
+func CreatePasswordResetToken(userID uuid.UUID, ttl time.Duration) (string, error) {
+	token, hash, err := GenerateOpaqueToken()
+	if err != nil {
+		return "", err
+	}
+
+	record := models.PasswordResetToken{
+		UserID:    userID,
+		TokenHash: hash,
+		ExpiresAt: time.Now().Add(ttl),
+	}
+
+	if err := db.DB.Create(&record).Error; err != nil {
+		return "", err
+	}
+
+	return token, nil
+}
+
+// ResetPassword consumes a reset token and sets the user's new password. All
+// other outstanding reset tokens and sessions of the user are revoked.
+func ResetPassword(token string, newPassword string) (user models.User, err error) {
+	hashedPassword, err := HashPassword(newPassword)
+	if err != nil {
+		return models.User{}, err
+	}
+
+	err = db.DB.Transaction(func(tx *gorm.DB) error {
+		var record models.PasswordResetToken
+		if result := tx.Where("token_hash = ?", HashToken(token)).First(&record); result.Error != nil {
+			return ErrInvalidResetToken
+		}
+
+		now := time.Now()
+		if record.UsedAt != nil || record.ExpiresAt.Before(now) {
+			return ErrInvalidResetToken
+		}
+
+		// Mark every unused token of the user as used so the link can't be
+		// replayed, including concurrently
+		result := tx.Model(&models.PasswordResetToken{}).
+			Where("user_id = ? AND used_at IS NULL", record.UserID).
+			Update("used_at", now)
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return ErrInvalidResetToken
+		}
+
+		if result := tx.Where("id = ?", record.UserID).First(&user); result.Error != nil {
+			return ErrInvalidResetToken
+		}
+
+		return tx.Model(&user).Update("password", hashedPassword).Error
+	})
+	if err != nil {
+		return models.User{}, err
+	}
+
+	if err := RevokeAllUserTokens(user.ID); err != nil {
+		return models.User{}, err
+	}
+
+	return user, nil
+}
@@ -0,0 +1,112 @@
+package auth
+
+import (
+	"errors"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/terkoizmy/go-blog-api/internal/db"
+	"github.com/terkoizmy/go-blog-api/internal/models"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+var ErrChallengeUsed = errors.New("challenge token already used")
+
+// RevokeToken revokes a single access token by its jti until it expires
+func RevokeToken(claims *JWTClaim) error {
+	if claims.ID == "" {
+		return nil
+	}
+
+	expiresAt := time.Now()
+	if claims.ExpiresAt != nil {
+		expiresAt = claims.ExpiresAt.Time
+	}
+
+	// Drop entries for tokens that have expired on their own
+	db.DB.Where("expires_at < ?", time.Now()).Delete(&models.RevokedToken{})
+
+	return db.DB.Create(&models.RevokedToken{
+		JTI:       claims.ID,
+		UserID:    claims.UserID,
+		ExpiresAt: expiresAt,
+	}).Error
+}
+
+// consumeChallengeToken marks a two-factor challenge token as used, so it
+// can't complete another login. Only the first of concurrent calls succeeds.
+func consumeChallengeToken(tx *gorm.DB, claims *JWTClaim) error {
+	if claims.ID == "" {
+		return ErrChallengeUsed
+	}
+
+	expiresAt := time.Now()
+	if claims.ExpiresAt != nil {
+		expiresAt = claims.ExpiresAt.Time
+	}
+
+	result := tx.Clauses(clause.OnConflict{DoNothing: true}).Create(&models.RevokedToken{
+		JTI:       claims.ID,
+		UserID:    claims.UserID,
+		ExpiresAt: expiresAt,
+	})
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return ErrChallengeUsed
+	}
+	return nil
+}
+
+// RevokeAllUserTokens logs a user out of every session by rejecting all access
+// tokens issued up to now and revoking their refresh tokens. Personal access
+// tokens are revoked too, so none taken from the account outlives it.
+func RevokeAllUserTokens(userID uuid.UUID) error {
+	return db.DB.Transaction(func(tx *gorm.DB) error {
+		return revokeAllUserTokens(tx, userID)
+	})
+}
+
+// revokeAllUserTokens is RevokeAllUserTokens within a transaction
+func revokeAllUserTokens(tx *gorm.DB, userID uuid.UUID) error {
+	if err := tx.Model(&models.User{}).
+		Where("id = ?", userID).
+		Update("tokens_revoked_at", time.Now()).Error; err != nil {
+		return err
+	}
+
+	if err := revokeUserRefreshTokens(tx, userID); err != nil {
+		return err
+	}
+
+	return revokeUserPersonalAccessTokens(tx, userID)
+}
+
+// IsTokenRevoked reports whether the token was revoked individually, was issued
+// before its owner's last "log out all sessions", or belongs to a deleted user
+func IsTokenRevoked(claims *JWTClaim) (bool, error) {
+	if claims.ID != "" {
+		var count int64
+		if err := db.DB.Model(&models.RevokedToken{}).Where("jti = ?", claims.ID).Count(&count).Error; err != nil {
+			return false, err
+		}
+		if count > 0 {
+			return true, nil
+		}
+	}
+
+	var user models.User
+	if result := db.DB.Select("id", "tokens_revoked_at").Where("id = ?", claims.UserID).Limit(1).Find(&user); result.Error != nil {
+		return false, result.Error
+	} else if result.RowsAffected == 0 {
+		return true, nil
+	}
+
+	if user.TokensRevokedAt != nil && claims.IssuedAt != nil && claims.IssuedAt.Time.Before(*user.TokensRevokedAt) {
+		return true, nil
+	}
+
+	return false, nil
+}
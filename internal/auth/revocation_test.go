@@ -0,0 +1,40 @@
+package auth
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v4"
+	"github.com/google/uuid"
+)
+
+func TestRevokeAllUserTokens(t *testing.T) {
+	user := newTestUser(t)
+
+	token, err := IssueRefreshToken(user.ID, uuid.Nil, time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, pat, err := CreatePersonalAccessToken(user.ID, "ci", []string{ScopePostsRead}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := RevokeAllUserTokens(user.ID); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, _, err := RotateRefreshToken(token, time.Hour); !errors.Is(err, ErrInvalidRefreshToken) {
+		t.Errorf("refresh token: got %v, want ErrInvalidRefreshToken", err)
+	}
+	if _, _, err := AuthenticatePersonalAccessToken(pat); !errors.Is(err, ErrInvalidPersonalAccessToken) {
+		t.Errorf("personal access token: got %v, want ErrInvalidPersonalAccessToken", err)
+	}
+
+	claims := &JWTClaim{UserID: user.ID}
+	claims.IssuedAt = jwt.NewNumericDate(time.Now().Add(-time.Minute))
+	if revoked, err := IsTokenRevoked(claims); err != nil || !revoked {
+		t.Errorf("access token issued before: revoked %v, %v", revoked, err)
+	}
+}
@@ -0,0 +1,343 @@
+package auth
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"slices"
+
+	"github.com/google/uuid"
+	"github.com/terkoizmy/go-blog-api/internal/db"
+	"github.com/terkoizmy/go-blog-api/internal/models"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+var (
+	ErrRoleNotFound      = errors.New("role not found")
+	ErrRoleExists        = errors.New("role already exists")
+	ErrInvalidRoleName   = errors.New("role names may only contain lowercase letters, digits, - and _")
+	ErrInvalidPermission = errors.New("invalid permission")
+	ErrBuiltInRole       = errors.New("built-in roles can't be deleted")
+	ErrAdminRoleLocked   = errors.New("the admin role can't be changed")
+	ErrRoleInUse         = errors.New("role is still assigned to users")
+	ErrLastAdmin         = errors.New("can't remove the last admin")
+	ErrUserNotFound      = errors.New("user not found")
+)
+
+var roleNamePattern = regexp.MustCompile(`^[a-z0-9_-]+$`)
+
+// SeedRoles creates any missing built-in roles and makes sure admin holds
+// every permission. Permissions of the other built-in roles are left alone
+// once created so they can be customised.
+func SeedRoles() error {
+	for _, r := range builtInRoles {
+		var role models.Role
+		result := db.DB.Where("name = ?", r.name).Limit(1).Find(&role)
+		if result.Error != nil {
+			return result.Error
+		}
+
+		if result.RowsAffected == 0 {
+			role = models.Role{
+				Name:        r.name,
+				Description: r.description,
+				Permissions: r.permissions,
+				BuiltIn:     true,
+			}
+			if err := db.DB.Create(&role).Error; err != nil {
+				return err
+			}
+			continue
+		}
+
+		if r.name == RoleAdmin {
+			role.Permissions = r.permissions
+			if err := db.DB.Save(&role).Error; err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+// ListRoles returns every role ordered by name
+func ListRoles() ([]models.Role, error) {
+	var roles []models.Role
+	err := db.DB.Order("name").Find(&roles).Error
+	return roles, err
+}
+
+// GetRole looks up a role by name
+func GetRole(name string) (models.Role, error) {
+	var role models.Role
+	if result := db.DB.Where("name = ?", name).Limit(1).Find(&role); result.Error != nil {
+		return models.Role{}, result.Error
+	} else if result.RowsAffected == 0 {
+		return models.Role{}, ErrRoleNotFound
+	}
+	return role, nil
+}
+
+// CreateRole adds a custom role
+func CreateRole(name, description string, permissions []string) (models.Role, error) {
+	if !roleNamePattern.MatchString(name) {
+		return models.Role{}, ErrInvalidRoleName
+	}
+	if err := validatePermissions(permissions); err != nil {
+		return models.Role{}, err
+	}
+
+	if _, err := GetRole(name); err == nil {
+		return models.Role{}, ErrRoleExists
+	} else if !errors.Is(err, ErrRoleNotFound) {
+		return models.Role{}, err
+	}
+
+	if permissions == nil {
+		permissions = []string{}
+	}
+
+	role := models.Role{
+		Name:        name,
+		Description: description,
+		Permissions: permissions,
+	}
+	if err := db.DB.Create(&role).Error; err != nil {
+		return models.Role{}, err
+	}
+
+	return role, nil
+}
+
+// UpdateRole changes the description and, if permissions is not nil, the
+// permissions of a role
+func UpdateRole(name string, description *string, permissions []string) (models.Role, error) {
+	if name == RoleAdmin {
+		return models.Role{}, ErrAdminRoleLocked
+	}
+
+	role, err := GetRole(name)
+	if err != nil {
+		return models.Role{}, err
+	}
+
+	if description != nil {
+		role.Description = *description
+	}
+	if permissions != nil {
+		if err := validatePermissions(permissions); err != nil {
+			return models.Role{}, err
+		}
+		role.Permissions = permissions
+	}
+
+	if err := db.DB.Save(&role).Error; err != nil {
+		return models.Role{}, err
+	}
+
+	return role, nil
+}
+
+// DeleteRole removes a custom role that no user is assigned to
+func DeleteRole(name string) error {
+	role, err := GetRole(name)
+	if err != nil {
+		return err
+	}
+	if role.BuiltIn {
+		return ErrBuiltInRole
+	}
+
+	var count int64
+	if err := db.DB.Model(&models.User{}).Where("role = ?", name).Count(&count).Error; err != nil {
+		return err
+	}
+	if count > 0 {
+		return ErrRoleInUse
+	}
+
+	return db.DB.Delete(&role).Error
+}
+
+// AssignRole gives the user a new role. The user's sessions are revoked since
+// access tokens carry the old role.
+func AssignRole(userID uuid.UUID, roleName string) (models.User, error) {
+	if _, err := GetRole(roleName); err != nil {
+		return models.User{}, err
+	}
+
+	var user models.User
+	err := db.DB.Transaction(func(tx *gorm.DB) error {
+		if result := tx.Where("id = ?", userID).Limit(1).Find(&user); result.Error != nil {
+			return result.Error
+		} else if result.RowsAffected == 0 {
+			return ErrUserNotFound
+		}
+
+		return changeRole(tx, &user, roleName)
+	})
+	if err != nil {
+		return models.User{}, err
+	}
+
+	return user, nil
+}
+
+// UpdateUser saves changes to a user and gives them roleName, if it's set,
+// all or nothing. Their sessions are revoked when the role changes or when
+// revokeSessions is set, such as for a new password.
+func UpdateUser(user *models.User, roleName string, revokeSessions bool) error {
+	changeRoles := roleName != "" && roleName != user.Role
+	if changeRoles {
+		if _, err := GetRole(roleName); err != nil {
+			return err
+		}
+	}
+
+	return db.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Save(user).Error; err != nil {
+			return err
+		}
+
+		if changeRoles {
+			if err := changeRole(tx, user, roleName); err != nil {
+				return err
+			}
+		} else if revokeSessions {
+			return revokeAllUserTokens(tx, user.ID)
+		}
+		return nil
+	})
+}
+
+// DeleteUser soft-deletes a user, unless they are the last admin
+func DeleteUser(userID uuid.UUID) error {
+	return db.DB.Transaction(func(tx *gorm.DB) error {
+		if err := lockAdmins(tx); err != nil {
+			return err
+		}
+
+		var user models.User
+		if result := tx.Where("id = ?", userID).Limit(1).Find(&user); result.Error != nil {
+			return result.Error
+		} else if result.RowsAffected == 0 {
+			return ErrUserNotFound
+		}
+
+		if user.Role == RoleAdmin {
+			if err := requireAnotherAdmin(tx, user.ID); err != nil {
+				return err
+			}
+		}
+
+		return tx.Delete(&user).Error
+	})
+}
+
+// changeRole gives a user loaded in the transaction a new role and revokes
+// their sessions, unless they are the last admin
+func changeRole(tx *gorm.DB, user *models.User, roleName string) error {
+	if user.Role == roleName {
+		return nil
+	}
+
+	if user.Role == RoleAdmin {
+		if err := lockAdmins(tx); err != nil {
+			return err
+		}
+		if err := requireAnotherAdmin(tx, user.ID); err != nil {
+			return err
+		}
+	}
+
+	if err := tx.Model(user).Update("role", roleName).Error; err != nil {
+		return err
+	}
+	return revokeAllUserTokens(tx, user.ID)
+}
+
+// lockAdmins locks the admins' rows until the transaction ends. Removing an
+// admin takes the lock first, so concurrent removals can't both see another
+// admin and leave none.
+func lockAdmins(tx *gorm.DB) error {
+	var ids []uuid.UUID
+	return tx.Model(&models.User{}).
+		Clauses(clause.Locking{Strength: "UPDATE"}).
+		Where("role = ?", RoleAdmin).
+		Order("id").
+		Pluck("id", &ids).Error
+}
+
+// requireAnotherAdmin fails with ErrLastAdmin unless an admin other than the
+// user is left
+func requireAnotherAdmin(tx *gorm.DB, userID uuid.UUID) error {
+	var admins int64
+	if err := tx.Model(&models.User{}).Where("role = ? AND id <> ?", RoleAdmin, userID).Count(&admins).Error; err != nil {
+		return err
+	}
+	if admins == 0 {
+		return ErrLastAdmin
+	}
+	return nil
+}
+
+// RoleOutranks reports whether the named role grants any permission the other
+// role doesn't. Unknown roles grant nothing.
+func RoleOutranks(roleName, otherName string) (bool, error) {
+	if roleName == otherName {
+		return false, nil
+	}
+
+	role, err := GetRole(roleName)
+	if errors.Is(err, ErrRoleNotFound) {
+		return false, nil
+	} else if err != nil {
+		return false, err
+	}
+
+	var granted []string
+	if other, err := GetRole(otherName); err == nil {
+		granted = other.Permissions
+	} else if !errors.Is(err, ErrRoleNotFound) {
+		return false, err
+	}
+
+	for _, p := range role.Permissions {
+		if !slices.Contains(granted, p) {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// RoleHasPermission reports whether the named role grants the permission
+func RoleHasPermission(roleName string, permission string) (bool, error) {
+	if roleName == "" {
+		return false, nil
+	}
+
+	role, err := GetRole(roleName)
+	if errors.Is(err, ErrRoleNotFound) {
+		return false, nil
+	} else if err != nil {
+		return false, err
+	}
+
+	for _, p := range role.Permissions {
+		if p == permission {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+func validatePermissions(permissions []string) error {
+	for _, p := range permissions {
+		if !IsValidPermission(p) {
+			return fmt.Errorf("%w: %s", ErrInvalidPermission, p)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,42 @@
+package auth
+
+// Scopes that can be granted to personal access tokens. Requests
+// authenticated with a JWT are not limited by scopes.
+const (
+	ScopePostsRead       = "posts:read"
+	ScopePostsWrite      = "posts:write"
+	ScopeCommentsWrite   = "comments:write"
+	ScopeCategoriesWrite = "categories:write"
+	ScopeTagsWrite       = "tags:write"
+	ScopeUsersRead       = "users:read"
+	ScopeUsersWrite      = "users:write"
+)
+
+var PersonalAccessTokenScopes = []string{
+	ScopePostsRead,
+	ScopePostsWrite,
+	ScopeCommentsWrite,
+	ScopeCategoriesWrite,
+	ScopeTagsWrite,
+	ScopeUsersRead,
+	ScopeUsersWrite,
+}
+
+// IsValidScope reports whether scope can be granted to a personal access token
+func IsValidScope(scope string) bool {
+	for _, s := range PersonalAccessTokenScopes {
+		if s == scope {
+			return true
+		}
+	}
+	return false
+}
+
+func hasScope(scopes []string, scope string) bool {
+	for _, s := range scopes {
+		if s == scope {
+			return true
+		}
+	}
+	return false
+}
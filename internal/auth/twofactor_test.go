@@ -0,0 +1,126 @@
+package auth
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v4"
+	"github.com/google/uuid"
+	"github.com/terkoizmy/go-blog-api/internal/db"
+	"github.com/terkoizmy/go-blog-api/internal/models"
+)
+
+// enableTwoFactor turns on two-factor authentication for the user and
+// returns them reloaded, with their TOTP key, recovery codes and the current
+// time step, whose code is still unused
+func enableTwoFactor(t *testing.T, user models.User) (models.User, []byte, []string, int64) {
+	t.Helper()
+
+	// Keep clear of the end of a time step, so the current one doesn't move
+	// while a test runs
+	if left := totpPeriod - time.Now().Unix()%totpPeriod; left < 3 {
+		time.Sleep(time.Duration(left) * time.Second)
+	}
+	step := time.Now().Unix() / totpPeriod
+
+	secret, _, err := EnrollTOTP(user, "test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	key, err := totpEncoding.DecodeString(secret)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Enable with the code of the previous step, which leaves the current
+	// one for the tests
+	user.TOTPSecret = secret
+	codes, err := EnableTOTP(user, totpCode(key, step-1))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := db.DB.First(&user, "id = ?", user.ID).Error; err != nil {
+		t.Fatal(err)
+	}
+	return user, key, codes, step
+}
+
+func TestVerifySecondFactorTOTP(t *testing.T) {
+	user, key, _, step := enableTwoFactor(t, newTestUser(t))
+
+	if err := VerifySecondFactor(user, totpCode(key, step-1)); !errors.Is(err, ErrInvalidTwoFactorCode) {
+		t.Errorf("code used to enable: got %v, want ErrInvalidTwoFactorCode", err)
+	}
+	if err := VerifySecondFactor(user, totpCode(key, step)); err != nil {
+		t.Fatalf("current code: %v", err)
+	}
+	if err := VerifySecondFactor(user, totpCode(key, step)); !errors.Is(err, ErrInvalidTwoFactorCode) {
+		t.Errorf("replayed code: got %v, want ErrInvalidTwoFactorCode", err)
+	}
+
+	// Codes of a later step are accepted for clock drift, after which the
+	// earlier steps are spent too
+	if err := VerifySecondFactor(user, totpCode(key, step+1)); err != nil {
+		t.Fatalf("next code: %v", err)
+	}
+	if err := VerifySecondFactor(user, totpCode(key, step)); !errors.Is(err, ErrInvalidTwoFactorCode) {
+		t.Errorf("code older than the last used one: got %v, want ErrInvalidTwoFactorCode", err)
+	}
+	if err := VerifySecondFactor(user, totpCode(key, step+2)); !errors.Is(err, ErrInvalidTwoFactorCode) {
+		t.Errorf("code beyond the allowed drift: got %v, want ErrInvalidTwoFactorCode", err)
+	}
+}
+
+func TestVerifySecondFactorRecoveryCode(t *testing.T) {
+	user, _, codes, _ := enableTwoFactor(t, newTestUser(t))
+	if len(codes) != recoveryCodeCount {
+		t.Fatalf("got %d recovery codes, want %d", len(codes), recoveryCodeCount)
+	}
+
+	if err := VerifySecondFactor(user, codes[0]); err != nil {
+		t.Fatalf("recovery code: %v", err)
+	}
+	if err := VerifySecondFactor(user, codes[0]); !errors.Is(err, ErrInvalidTwoFactorCode) {
+		t.Errorf("used recovery code: got %v, want ErrInvalidTwoFactorCode", err)
+	}
+
+	// Codes are accepted without the dash and in any case
+	typed := " " + strings.ToUpper(strings.ReplaceAll(codes[1], "-", "")) + " "
+	if err := VerifySecondFactor(user, typed); err != nil {
+		t.Errorf("recovery code as typed %q: %v", typed, err)
+	}
+
+	if err := VerifySecondFactor(user, "aaaaa-bbbbb"); !errors.Is(err, ErrInvalidTwoFactorCode) {
+		t.Errorf("unknown recovery code: got %v, want ErrInvalidTwoFactorCode", err)
+	}
+}
+
+func TestCompleteTwoFactorLogin(t *testing.T) {
+	user, _, codes, _ := enableTwoFactor(t, newTestUser(t))
+	challenge := &JWTClaim{
+		UserID: user.ID,
+		RegisteredClaims: jwt.RegisteredClaims{
+			ID:        uuid.NewString(),
+			ExpiresAt: jwt.NewNumericDate(time.Now().Add(time.Minute)),
+		},
+	}
+
+	// A wrong code leaves the challenge usable
+	if err := CompleteTwoFactorLogin(challenge, user, "000000"); !errors.Is(err, ErrInvalidTwoFactorCode) {
+		t.Fatalf("wrong code: got %v, want ErrInvalidTwoFactorCode", err)
+	}
+	if err := CompleteTwoFactorLogin(challenge, user, codes[0]); err != nil {
+		t.Fatalf("recovery code: %v", err)
+	}
+
+	// A replayed challenge doesn't use up the code sent with it
+	if err := CompleteTwoFactorLogin(challenge, user, codes[1]); !errors.Is(err, ErrChallengeUsed) {
+		t.Fatalf("replayed challenge: got %v, want ErrChallengeUsed", err)
+	}
+	if err := VerifySecondFactor(user, codes[1]); err != nil {
+		t.Errorf("code sent with the replayed challenge: %v", err)
+	}
+}
@@ -0,0 +1,77 @@
+package auth
+
+import (
+	"errors"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/terkoizmy/go-blog-api/internal/db"
+	"github.com/terkoizmy/go-blog-api/internal/models"
+	"gorm.io/gorm"
+)
+
+var ErrInvalidVerificationToken = errors.New("invalid or expired verification token")
+
+// CreateEmailVerificationToken stores a new verification token for the given
+// address of the user and returns the plain token to be emailed
+func CreateEmailVerificationToken(userID uuid.UUID, email string, ttl time.Duration) (string, error) {
+	token, hash, err := GenerateOpaqueToken()
+	if err != nil {
+		return "", err
+	}
+
+	record := models.EmailVerificationToken{
+		UserID:    userID,
+		Email:     email,
+		TokenHash: hash,
+		ExpiresAt: time.Now().Add(ttl),
+	}
+
+	if err := db.DB.Create(&record).Error; err != nil {
+		return "", err
+	}
+
+	return token, nil
+}
+
+// VerifyEmail consumes a verification token and marks the user's email as
+// verified, as long as the token was issued for their current address
+func VerifyEmail(token string) (user models.User, err error) {
+	err = db.DB.Transaction(func(tx *gorm.DB) error {
+		var record models.EmailVerificationToken
+		if result := tx.Where("token_hash = ?", HashToken(token)).First(&record); result.Error != nil {
+			return ErrInvalidVerificationToken
+		}
+
+		now := time.Now()
+		if record.UsedAt != nil || record.ExpiresAt.Before(now) {
+			return ErrInvalidVerificationToken
+		}
+
+		if result := tx.Where("id = ?", record.UserID).First(&user); result.Error != nil {
+			return ErrInvalidVerificationToken
+		}
+
+		if user.Email != record.Email {
+			return ErrInvalidVerificationToken
+		}
+
+		result := tx.Model(&models.EmailVerificationToken{}).
+			Where("id = ? AND used_at IS NULL", record.ID).
+			Update("used_at", now)
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return ErrInvalidVerificationToken
+		}
+
+		user.EmailVerifiedAt = &now
+		return tx.Model(&user).Update("email_verified_at", now).Error
+	})
+	if err != nil {
+		return models.User{}, err
+	}
+
+	return user, nil
+}
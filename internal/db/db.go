@@ -1,37 +1,94 @@
 package db
 
 import (
+	"fmt"
 	"log"
+	"strings"
 
+	"github.com/glebarez/sqlite"
 	"github.com/terkoizmy/go-blog-api/config"
 	"gorm.io/driver/postgres"
 	"gorm.io/gorm"
 )
 
+const (
+	DriverPostgres = "postgres"
+	DriverSQLite   = "sqlite"
+)
+
 var DB *gorm.DB
 
-func InitDB(config config.Config) {
-	var err error
+// InitDB connects to the database configured by DB_DRIVER and DATABASE_URL
+// and makes it available as DB
+func InitDB(config config.Config) error {
+	conn, err := Open(config.DBDriver, config.DatabaseURL)
+	if err != nil {
+		return err
+	}
+
+	DB = conn
+	log.Println("Database connection established successfully")
+	return nil
+}
 
-	// Use the direct DATABASE_URL from .env
-	dsn := config.DatabaseURL
+// Open connects to a database. For postgres dsn is a connection URL; for
+// sqlite it is a file path, or ":memory:" for a throwaway in-memory database.
+func Open(driver, dsn string) (*gorm.DB, error) {
+	var dialector gorm.Dialector
+	switch driver {
+	case DriverPostgres, "":
+		dialector = postgres.Open(dsn)
+	case DriverSQLite:
+		dialector = sqlite.Open(sqliteDSN(dsn))
+	default:
+		return nil, fmt.Errorf("unsupported database driver %q", driver)
+	}
 
-	// Configure GORM
-	DB, err = gorm.Open(postgres.Open(dsn), &gorm.Config{})
+	conn, err := gorm.Open(dialector, &gorm.Config{})
 	if err != nil {
-		log.Fatalf("Failed to connect to database: %v", err)
+		return nil, fmt.Errorf("failed to connect to database: %w", err)
 	}
 
 	// Test the connection
-	sqlDB, err := DB.DB()
+	sqlDB, err := conn.DB()
 	if err != nil {
-		log.Fatalf("Failed to get database connection: %v", err)
+		return nil, fmt.Errorf("failed to get database connection: %w", err)
 	}
 
-	err = sqlDB.Ping()
-	if err != nil {
-		log.Fatalf("Failed to ping database: %v", err)
+	// Every connection to an in-memory database gets its own empty database,
+	// so keep a single one open for the lifetime of the pool
+	if InMemory(driver, dsn) {
+		sqlDB.SetMaxOpenConns(1)
+		sqlDB.SetConnMaxLifetime(0)
+		sqlDB.SetConnMaxIdleTime(0)
 	}
 
-	log.Println("Database connection established successfully")
+	if err := sqlDB.Ping(); err != nil {
+		return nil, fmt.Errorf("failed to ping database: %w", err)
+	}
+
+	return conn, nil
+}
+
+// sqliteDSN turns on foreign key enforcement, which SQLite leaves off by
+// default, and waits for locks instead of failing straight away
+func sqliteDSN(dsn string) string {
+	if dsn == "" {
+		dsn = ":memory:"
+	}
+
+	separator := "?"
+	if strings.Contains(dsn, "?") {
+		separator = "&"
+	}
+	return dsn + separator + "_pragma=foreign_keys(1)&_pragma=busy_timeout(5000)"
+}
+
+// InMemory reports whether the database is a throwaway in-memory one, which
+// starts out empty every time it is opened
+func InMemory(driver, dsn string) bool {
+	if driver != DriverSQLite {
+		return false
+	}
+	return dsn == "" || strings.Contains(dsn, ":memory:") || strings.Contains(dsn, "mode=memory")
 }
@@ -0,0 +1,39 @@
+// Package dbtest opens throwaway databases for tests, so they run without a
+// database server.
+package dbtest
+
+import (
+	"testing"
+
+	"github.com/terkoizmy/go-blog-api/internal/db"
+	"github.com/terkoizmy/go-blog-api/internal/migrations"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+// Open returns a new in-memory SQLite database with every migration applied.
+// It is also made the shared db.DB until the test ends.
+func Open(t testing.TB) *gorm.DB {
+	t.Helper()
+
+	conn, err := db.Open(db.DriverSQLite, ":memory:")
+	if err != nil {
+		t.Fatalf("open database: %v", err)
+	}
+	conn.Logger = logger.Discard
+
+	if _, err := migrations.Up(conn); err != nil {
+		t.Fatalf("migrate database: %v", err)
+	}
+
+	previous := db.DB
+	db.DB = conn
+	t.Cleanup(func() {
+		db.DB = previous
+		if sqlDB, err := conn.DB(); err == nil {
+			sqlDB.Close()
+		}
+	})
+
+	return conn
+}
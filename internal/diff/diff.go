@@ -0,0 +1,196 @@
+// Package diff compares texts line by line and formats the differences as
+// unified diffs.
+package diff
+
+import (
+	"fmt"
+	"strings"
+)
+
+// context is the number of unchanged lines shown around each change
+const context = 3
+
+// Limits on the work a diff may take. The time taken grows with the number
+// of lines times the number of changed lines, and the memory with the square
+// of the number of changed lines.
+const (
+	MaxLines   = 10000
+	MaxChanges = 2000
+)
+
+var (
+	ErrTooLarge       = fmt.Errorf("texts longer than %d lines can't be compared", MaxLines)
+	ErrTooManyChanges = fmt.Errorf("texts differing in more than %d lines can't be compared", MaxChanges)
+)
+
+type operation int
+
+const (
+	equal operation = iota
+	deleted
+	inserted
+)
+
+type edit struct {
+	op   operation
+	line string
+}
+
+// Unified returns a unified diff turning from into to, with fromName and
+// toName in its header, or an empty string if the texts are equal. It fails
+// with ErrTooLarge or ErrTooManyChanges beyond the limits.
+func Unified(fromName, toName, from, to string) (string, error) {
+	fromLines, toLines := splitLines(from), splitLines(to)
+	if len(fromLines) > MaxLines || len(toLines) > MaxLines {
+		return "", ErrTooLarge
+	}
+	edits, err := compute(fromLines, toLines)
+	if err != nil {
+		return "", err
+	}
+
+	// Group changes into hunks, merging those whose context would overlap
+	type span struct{ start, end int }
+	var hunks []span
+	for i, e := range edits {
+		if e.op == equal {
+			continue
+		}
+		start, end := max(i-context, 0), min(i+1+context, len(edits))
+		if n := len(hunks); n > 0 && start <= hunks[n-1].end {
+			hunks[n-1].end = end
+		} else {
+			hunks = append(hunks, span{start, end})
+		}
+	}
+	if len(hunks) == 0 {
+		return "", nil
+	}
+
+	// Line numbers in both texts before each edit
+	fromLine, toLine := make([]int, len(edits)+1), make([]int, len(edits)+1)
+	for i, e := range edits {
+		fromLine[i+1], toLine[i+1] = fromLine[i], toLine[i]
+		if e.op != inserted {
+			fromLine[i+1]++
+		}
+		if e.op != deleted {
+			toLine[i+1]++
+		}
+	}
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "--- %s\n+++ %s\n", fromName, toName)
+	for _, h := range hunks {
+		fmt.Fprintf(&b, "@@ -%s +%s @@\n",
+			hunkRange(fromLine[h.start], fromLine[h.end]-fromLine[h.start]),
+			hunkRange(toLine[h.start], toLine[h.end]-toLine[h.start]))
+		for _, e := range edits[h.start:h.end] {
+			switch e.op {
+			case equal:
+				b.WriteByte(' ')
+			case deleted:
+				b.WriteByte('-')
+			case inserted:
+				b.WriteByte('+')
+			}
+			b.WriteString(e.line)
+			b.WriteByte('\n')
+		}
+	}
+	return b.String(), nil
+}
+
+// hunkRange formats the start and length of a hunk. An empty range starts
+// at the line before it.
+func hunkRange(before, count int) string {
+	if count == 0 {
+		return fmt.Sprintf("%d,0", before)
+	}
+	if count == 1 {
+		return fmt.Sprintf("%d", before+1)
+	}
+	return fmt.Sprintf("%d,%d", before+1, count)
+}
+
+func splitLines(text string) []string {
+	if text == "" {
+		return nil
+	}
+	text = strings.ReplaceAll(text, "\r\n", "\n")
+	return strings.Split(strings.TrimSuffix(text, "\n"), "\n")
+}
+
+// compute finds a shortest edit script from a to b with Myers' algorithm
+func compute(a, b []string) ([]edit, error) {
+	n, m := len(a), len(b)
+	offset := n + m + 1
+	v := make([]int, 2*offset+1)
+
+	// trace keeps the furthest reaching paths before each step, to walk
+	// back along afterwards. Step d only reads diagonals -d-1 to d+1, so
+	// that is all that is kept of each.
+	var trace [][]int
+	steps := -1
+search:
+	for d := 0; d <= min(n+m, MaxChanges); d++ {
+		trace = append(trace, append([]int(nil), v[offset-d-1:offset+d+2]...))
+		for k := -d; k <= d; k += 2 {
+			var x int
+			if k == -d || (k != d && v[offset+k-1] < v[offset+k+1]) {
+				x = v[offset+k+1]
+			} else {
+				x = v[offset+k-1] + 1
+			}
+			y := x - k
+			for x < n && y < m && a[x] == b[y] {
+				x++
+				y++
+			}
+			v[offset+k] = x
+			if x >= n && y >= m {
+				steps = d
+				break search
+			}
+		}
+	}
+	if steps < 0 {
+		return nil, ErrTooManyChanges
+	}
+
+	var edits []edit
+	x, y := n, m
+	for d := steps; d >= 0; d-- {
+		// Diagonal k of step d is at index k+d+1
+		v := trace[d]
+		k := x - y
+
+		var prevK int
+		if k == -d || (k != d && v[k+d] < v[k+d+2]) {
+			prevK = k + 1
+		} else {
+			prevK = k - 1
+		}
+		prevX := v[prevK+d+1]
+		prevY := prevX - prevK
+
+		for x > prevX && y > prevY {
+			edits = append(edits, edit{equal, a[x-1]})
+			x--
+			y--
+		}
+		if d > 0 {
+			if x == prevX {
+				edits = append(edits, edit{inserted, b[y-1]})
+			} else {
+				edits = append(edits, edit{deleted, a[x-1]})
+			}
+		}
+		x, y = prevX, prevY
+	}
+
+	for i, j := 0, len(edits)-1; i < j; i, j = i+1, j-1 {
+		edits[i], edits[j] = edits[j], edits[i]
+	}
+	return edits, nil
+}
@@ -0,0 +1,118 @@
+package diff
+
+import (
+	"errors"
+	"math/rand"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+func TestUnified(t *testing.T) {
+	from := "one\ntwo\nthree\nfour\nfive\nsix\nseven\neight\nnine\nten\n"
+	to := "one\n2\nthree\nfour\nfive\nsix\nseven\neight\nnine\nten\neleven\n"
+
+	got, err := Unified("a", "b", from, to)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := `--- a
++++ b
+@@ -1,5 +1,5 @@
+ one
+-two
++2
+ three
+ four
+ five
+@@ -8,3 +8,4 @@
+ eight
+ nine
+ ten
++eleven
+`
+	if got != want {
+		t.Errorf("got\n%s\nwant\n%s", got, want)
+	}
+
+	if got, err := Unified("a", "b", from, from); got != "" || err != nil {
+		t.Errorf("equal texts: got %q, %v", got, err)
+	}
+}
+
+func TestComputeIsShortest(t *testing.T) {
+	random := rand.New(rand.NewSource(1))
+	lines := func(n int) []string {
+		out := make([]string, n)
+		for i := range out {
+			out[i] = strconv.Itoa(random.Intn(4))
+		}
+		return out
+	}
+
+	for i := 0; i < 200; i++ {
+		a, b := lines(random.Intn(30)), lines(random.Intn(30))
+		edits, err := compute(a, b)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		var gotA, gotB []string
+		changes := 0
+		for _, e := range edits {
+			if e.op != inserted {
+				gotA = append(gotA, e.line)
+			}
+			if e.op != deleted {
+				gotB = append(gotB, e.line)
+			}
+			if e.op != equal {
+				changes++
+			}
+		}
+		if strings.Join(gotA, ",") != strings.Join(a, ",") || strings.Join(gotB, ",") != strings.Join(b, ",") {
+			t.Fatalf("edits of %v to %v don't reproduce them", a, b)
+		}
+		if want := len(a) + len(b) - 2*lcs(a, b); changes != want {
+			t.Fatalf("%v to %v took %d changes, want %d", a, b, changes, want)
+		}
+	}
+}
+
+// lcs is the length of the longest common subsequence of a and b
+func lcs(a, b []string) int {
+	lengths := make([][]int, len(a)+1)
+	for i := range lengths {
+		lengths[i] = make([]int, len(b)+1)
+	}
+	for i := len(a) - 1; i >= 0; i-- {
+		for j := len(b) - 1; j >= 0; j-- {
+			if a[i] == b[j] {
+				lengths[i][j] = lengths[i+1][j+1] + 1
+			} else {
+				lengths[i][j] = max(lengths[i+1][j], lengths[i][j+1])
+			}
+		}
+	}
+	return lengths[0][0]
+}
+
+func TestLimits(t *testing.T) {
+	numbered := func(prefix string, n int) string {
+		var b strings.Builder
+		for i := 0; i < n; i++ {
+			b.WriteString(prefix + strconv.Itoa(i) + "\n")
+		}
+		return b.String()
+	}
+
+	if _, err := Unified("a", "b", numbered("", MaxLines+1), ""); !errors.Is(err, ErrTooLarge) {
+		t.Errorf("too many lines: got %v, want ErrTooLarge", err)
+	}
+	if _, err := Unified("a", "b", numbered("a", MaxChanges), numbered("b", 1)); !errors.Is(err, ErrTooManyChanges) {
+		t.Errorf("too many changes: got %v, want ErrTooManyChanges", err)
+	}
+	if _, err := Unified("a", "b", numbered("a", MaxChanges/2), numbered("b", MaxChanges/2)); err != nil {
+		t.Errorf("changes within the limit: %v", err)
+	}
+}
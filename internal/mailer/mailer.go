@@ -0,0 +1,71 @@
+package mailer
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/terkoizmy/go-blog-api/config"
+)
+
+// Message is a plain-text email
+type Message struct {
+	To      string
+	Subject string
+	Body    string
+}
+
+// Mailer delivers email messages
+type Mailer interface {
+	Send(msg Message) error
+}
+
+// ErrNotConfigured is returned by Send until InitMailer or SetMailer picks a
+// mailer
+var ErrNotConfigured = errors.New("no mailer configured")
+
+// unconfiguredMailer refuses every message, so commands that never set up a
+// mailer can't leak the tokens in them anywhere
+type unconfiguredMailer struct{}
+
+func (unconfiguredMailer) Send(Message) error {
+	return ErrNotConfigured
+}
+
+var current Mailer = unconfiguredMailer{}
+
+// InitMailer selects the mailer implementation from the config:
+// "file" (default), "smtp" or "log". Messages carry reset and verification
+// tokens, so "log" is refused unless gin runs in debug mode.
+func InitMailer(cfg config.Config) error {
+	switch strings.ToLower(cfg.Mailer) {
+	case "log":
+		if cfg.GinMode != "" && cfg.GinMode != gin.DebugMode {
+			return errors.New("the log mailer writes tokens to the application log and is only allowed with GIN_MODE=debug")
+		}
+		current = NewLogMailer()
+	case "", "file":
+		m, err := NewFileMailer(cfg.MailerFileDir)
+		if err != nil {
+			return err
+		}
+		current = m
+	case "smtp":
+		current = NewSMTPMailer(cfg.SMTPHost, cfg.SMTPPort, cfg.SMTPUsername, cfg.SMTPPassword, cfg.MailFrom)
+	default:
+		return fmt.Errorf("unsupported mailer %q", cfg.Mailer)
+	}
+
+	return nil
+}
+
+// SetMailer replaces the mailer used by Send
+func SetMailer(m Mailer) {
+	current = m
+}
+
+// Send delivers a message through the configured mailer
+func Send(msg Message) error {
+	return current.Send(msg)
+}
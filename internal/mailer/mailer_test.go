@@ -0,0 +1,12 @@
+package mailer
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestSendWithoutMailer(t *testing.T) {
+	if err := Send(Message{To: "alice@example.com", Subject: "Reset", Body: "token"}); !errors.Is(err, ErrNotConfigured) {
+		t.Errorf("got %v, want ErrNotConfigured", err)
+	}
+}
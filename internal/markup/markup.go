@@ -0,0 +1,57 @@
+// Package markup renders user content in its source format to HTML that is
+// safe to embed in a page.
+package markup
+
+import (
+	"errors"
+	"html"
+	"strings"
+
+	"github.com/russross/blackfriday/v2"
+)
+
+// Content formats
+const (
+	Markdown = "markdown"
+	HTML     = "html"
+	Plain    = "plain"
+)
+
+var ErrInvalidFormat = errors.New("content_format must be markdown, html or plain")
+
+// IsValidFormat reports whether format is one of the content formats
+func IsValidFormat(format string) bool {
+	return format == Markdown || format == HTML || format == Plain
+}
+
+// Render converts text in the given format to sanitized HTML. Unknown
+// formats, including the empty one, are treated as Markdown.
+func Render(format, text string) string {
+	switch format {
+	case HTML:
+		return Sanitize(text)
+	case Plain:
+		return renderPlain(text)
+	}
+
+	text = strings.ReplaceAll(text, "\r\n", "\n")
+	return Sanitize(string(blackfriday.Run([]byte(text))))
+}
+
+// renderPlain escapes text and turns blank lines into paragraphs and single
+// newlines into line breaks
+func renderPlain(text string) string {
+	text = strings.ReplaceAll(text, "\r\n", "\n")
+
+	var b strings.Builder
+	for _, paragraph := range strings.Split(text, "\n\n") {
+		paragraph = strings.Trim(paragraph, "\n")
+		if strings.TrimSpace(paragraph) == "" {
+			continue
+		}
+		b.WriteString("<p>")
+		b.WriteString(strings.ReplaceAll(html.EscapeString(paragraph), "\n", "<br>\n"))
+		b.WriteString("</p>\n")
+	}
+	return b.String()
+}
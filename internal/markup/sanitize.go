@@ -0,0 +1,192 @@
+package markup
+
+import (
+	"html"
+	"strings"
+
+	nethtml "golang.org/x/net/html"
+)
+
+// allowedTags maps the elements kept by Sanitize to the attributes kept on
+// them, besides the global ones
+var allowedTags = map[string][]string{
+	"p": nil, "br": nil, "hr": nil, "div": nil, "span": nil,
+	"h1": {"id"}, "h2": {"id"}, "h3": {"id"}, "h4": {"id"}, "h5": {"id"}, "h6": {"id"},
+	"blockquote": {"cite"}, "q": {"cite"}, "cite": nil, "pre": nil, "code": {"class"},
+	"em": nil, "strong": nil, "b": nil, "i": nil, "u": nil, "s": nil, "del": nil, "ins": nil,
+	"sup": nil, "sub": nil, "small": nil, "mark": nil, "kbd": nil, "abbr": nil,
+	"ul": nil, "ol": {"start"}, "li": nil, "dl": nil, "dt": nil, "dd": nil,
+	"a": {"href"}, "img": {"src", "alt", "width", "height"},
+	"figure": nil, "figcaption": nil,
+	"table": nil, "caption": nil, "thead": nil, "tbody": nil, "tfoot": nil, "tr": nil,
+	"th": {"align", "colspan", "rowspan"}, "td": {"align", "colspan", "rowspan"},
+}
+
+// globalAttributes are kept on every allowed element
+var globalAttributes = []string{"title"}
+
+// droppedTags are removed along with everything inside them
+var droppedTags = map[string]bool{
+	"script": true, "style": true, "iframe": true, "frame": true, "frameset": true,
+	"object": true, "embed": true, "applet": true, "noscript": true, "noembed": true,
+	"noframes": true, "template": true, "textarea": true, "select": true, "title": true,
+	"svg": true, "math": true, "xmp": true, "plaintext": true, "head": true,
+}
+
+var voidTags = map[string]bool{"br": true, "hr": true, "img": true}
+
+// urlAttributes hold links, which must be relative or use a safe scheme
+var urlAttributes = map[string]bool{"href": true, "src": true, "cite": true}
+
+var safeSchemes = map[string]bool{"http": true, "https": true, "mailto": true}
+
+// Sanitize strips everything from an HTML fragment but an allowlist of
+// formatting elements and attributes, and balances the tags left. Scripts,
+// styles, event handlers and javascript: links don't survive it. Other
+// disallowed elements are removed but their text is kept.
+func Sanitize(fragment string) string {
+	var b strings.Builder
+	var open []string
+	// skipping is the dropped element being skipped and depth how many of
+	// them are nested
+	var skipping string
+	var depth int
+
+	tokenizer := nethtml.NewTokenizer(strings.NewReader(fragment))
+	for {
+		tt := tokenizer.Next()
+		if tt == nethtml.ErrorToken {
+			break
+		}
+		token := tokenizer.Token()
+
+		if skipping != "" {
+			switch {
+			case tt == nethtml.StartTagToken && token.Data == skipping:
+				depth++
+			case tt == nethtml.EndTagToken && token.Data == skipping:
+				if depth--; depth == 0 {
+					skipping = ""
+				}
+			}
+			continue
+		}
+
+		switch tt {
+		case nethtml.TextToken:
+			b.WriteString(html.EscapeString(token.Data))
+
+		case nethtml.StartTagToken, nethtml.SelfClosingTagToken:
+			if droppedTags[token.Data] {
+				if tt == nethtml.StartTagToken {
+					skipping, depth = token.Data, 1
+				}
+				continue
+			}
+			if _, ok := allowedTags[token.Data]; !ok {
+				continue
+			}
+
+			writeStartTag(&b, token)
+			switch {
+			case voidTags[token.Data]:
+			case tt == nethtml.SelfClosingTagToken:
+				b.WriteString("</" + token.Data + ">")
+			default:
+				open = append(open, token.Data)
+			}
+
+		case nethtml.EndTagToken:
+			// Close the element and any left open inside it; stray end
+			// tags are dropped
+			for i := len(open) - 1; i >= 0; i-- {
+				if open[i] != token.Data {
+					continue
+				}
+				for j := len(open) - 1; j >= i; j-- {
+					b.WriteString("</" + open[j] + ">")
+				}
+				open = open[:i]
+				break
+			}
+		}
+	}
+
+	for i := len(open) - 1; i >= 0; i-- {
+		b.WriteString("</" + open[i] + ">")
+	}
+	return b.String()
+}
+
+func writeStartTag(b *strings.Builder, token nethtml.Token) {
+	b.WriteString("<" + token.Data)
+	for _, attr := range token.Attr {
+		if attr.Namespace != "" || !allowedAttribute(token.Data, attr.Key) {
+			continue
+		}
+		value := attr.Val
+		if urlAttributes[attr.Key] && !isSafeURL(value) {
+			continue
+		}
+		if token.Data == "code" && attr.Key == "class" && !isLanguageClass(value) {
+			continue
+		}
+		b.WriteString(" " + attr.Key + `="` + html.EscapeString(value) + `"`)
+	}
+	// Links to other sites don't get our endorsement
+	if token.Data == "a" {
+		b.WriteString(` rel="nofollow"`)
+	}
+	b.WriteString(">")
+}
+
+func allowedAttribute(tag, key string) bool {
+	for _, allowed := range globalAttributes {
+		if key == allowed {
+			return true
+		}
+	}
+	for _, allowed := range allowedTags[tag] {
+		if key == allowed {
+			return true
+		}
+	}
+	return false
+}
+
+// isSafeURL reports whether a link is relative or uses a safe scheme.
+// Browsers ignore whitespace and control characters in schemes, so they are
+// removed before looking at it.
+func isSafeURL(value string) bool {
+	value = strings.Map(func(r rune) rune {
+		if r <= ' ' || r == 0x7f {
+			return -1
+		}
+		return r
+	}, value)
+
+	colon := strings.IndexByte(value, ':')
+	if colon < 0 {
+		return true
+	}
+	// A colon after the start of the path, query or fragment isn't a scheme
+	if i := strings.IndexAny(value, "/?#"); i >= 0 && i < colon {
+		return true
+	}
+	return safeSchemes[strings.ToLower(value[:colon])]
+}
+
+// isLanguageClass reports whether class is the language of a code block, as
+// set by fenced code blocks
+func isLanguageClass(class string) bool {
+	name, ok := strings.CutPrefix(class, "language-")
+	if !ok || name == "" {
+		return false
+	}
+	for _, r := range name {
+		if !(r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9' || strings.ContainsRune("+#-_.", r)) {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,94 @@
+package markup
+
+import "testing"
+
+func TestSanitize(t *testing.T) {
+	tests := []struct {
+		name string
+		in   string
+		want string
+	}{
+		{"allowed markup", `<p>Some <em>text</em> <a href="https://example.com/" title="x">here</a></p>`, `<p>Some <em>text</em> <a href="https://example.com/" title="x" rel="nofollow">here</a></p>`},
+		{"script body", `<p>a</p><script>alert(1)</script><p>b</p>`, `<p>a</p><p>b</p>`},
+		{"script with a fake end tag", `<script>document.write("</p>")</script>ok`, `ok`},
+		{"style body", `<style>body { display: none }</style>ok`, `ok`},
+		{"self-closing script", `<script src="https://evil.example/x.js"/>ok`, `ok`},
+		{"nested dropped elements", `<svg><svg><a href="javascript:alert(1)">x</a></svg>still svg</svg>ok`, `ok`},
+		{"unclosed dropped element", `ok<iframe src="https://evil.example/">`, `ok`},
+		{"event handler", `<img src="x.png" onerror="alert(1)">`, `<img src="x.png">`},
+		{"event handler on link", `<a href="/a" onclick="alert(1)" onmouseover="alert(2)">a</a>`, `<a href="/a" rel="nofollow">a</a>`},
+		{"style attribute", `<p style="position:fixed">x</p>`, `<p>x</p>`},
+		{"javascript link", `<a href="javascript:alert(1)">x</a>`, `<a rel="nofollow">x</a>`},
+		{"mixed case scheme", `<a href="JaVaScRiPt:alert(1)">x</a>`, `<a rel="nofollow">x</a>`},
+		{"entity encoded scheme", `<a href="&#106;avascript&#58;alert(1)">x</a>`, `<a rel="nofollow">x</a>`},
+		{"hex entity encoded scheme", `<a href="&#x6A;&#x61;vascript:alert(1)">x</a>`, `<a rel="nofollow">x</a>`},
+		{"whitespace in scheme", "<a href=\"java\tscript:alert(1)\">x</a>", `<a rel="nofollow">x</a>`},
+		{"encoded whitespace in scheme", `<a href="java&#x0A;script:alert(1)">x</a>`, `<a rel="nofollow">x</a>`},
+		{"leading whitespace", `<a href="  javascript:alert(1)">x</a>`, `<a rel="nofollow">x</a>`},
+		{"vbscript", `<a href="vbscript:msgbox(1)">x</a>`, `<a rel="nofollow">x</a>`},
+		{"data image", `<img src="data:image/svg+xml;base64,PHN2Zz4=">`, `<img>`},
+		{"data link", `<a href="data:text/html,<script>alert(1)</script>">x</a>`, `<a rel="nofollow">x</a>`},
+		{"javascript cite", `<blockquote cite="javascript:alert(1)">q</blockquote>`, `<blockquote>q</blockquote>`},
+		{"relative links", `<a href="/posts/a?x=1:2#b">a</a><a href="page:1">b</a>`, `<a href="/posts/a?x=1:2#b" rel="nofollow">a</a><a rel="nofollow">b</a>`},
+		{"colon after the path", `<a href="posts/a:b">a</a>`, `<a href="posts/a:b" rel="nofollow">a</a>`},
+		{"mailto", `<a href="mailto:me@example.com">me</a>`, `<a href="mailto:me@example.com" rel="nofollow">me</a>`},
+		{"attribute escaping", `<a href="/a" title="&quot;><script>">x</a>`, `<a href="/a" title="&#34;&gt;&lt;script&gt;" rel="nofollow">x</a>`},
+		{"text escaping", `1 &lt; 2 &amp;&amp; <b>3 > 2</b>`, `1 &lt; 2 &amp;&amp; <b>3 &gt; 2</b>`},
+		{"unknown elements keep their text", `<font color="red">red</font> <custom-tag>x</custom-tag>`, `red x`},
+		{"unclosed tags", `<p><strong>bold`, `<p><strong>bold</strong></p>`},
+		{"stray end tags", `</div>text</em></p>`, `text`},
+		{"misnested tags", `<p><em>a<strong>b</em>c</strong></p>`, `<p><em>a<strong>b</strong></em>c</p>`},
+		{"end tag closes inner elements", `<ul><li>one<li>two</ul>after`, `<ul><li>one<li>two</li></li></ul>after`},
+		{"void elements", `a<br>b<br/>c<hr></hr>`, `a<br>b<br>c<hr>`},
+		{"self-closing element", `<p/>x`, `<p></p>x`},
+		{"comments", `a<!-- <script>alert(1)</script> -->b`, `ab`},
+		{"doctype", `<!DOCTYPE html>x`, `x`},
+		{"code language", `<pre><code class="language-go">x</code></pre>`, `<pre><code class="language-go">x</code></pre>`},
+		{"code language with symbols", `<code class="language-c++">x</code>`, `<code class="language-c++">x</code>`},
+		{"code other class", `<code class="evil">x</code>`, `<code>x</code>`},
+		{"code several classes", `<code class="language-go highlight">x</code>`, `<code>x</code>`},
+		{"code empty language", `<code class="language-">x</code>`, `<code>x</code>`},
+		{"code quote in class", `<code class='language-go" onclick="alert(1)'>x</code>`, `<code>x</code>`},
+		{"class on other elements", `<p class="language-go">x</p>`, `<p>x</p>`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := Sanitize(tt.in); got != tt.want {
+				t.Errorf("Sanitize(%q)\ngot  %q\nwant %q", tt.in, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestIsSafeURL(t *testing.T) {
+	tests := []struct {
+		url  string
+		want bool
+	}{
+		{"https://example.com/", true},
+		{"HTTP://example.com/", true},
+		{"mailto:me@example.com", true},
+		{"/relative/path", true},
+		{"relative/path", true},
+		{"#anchor", true},
+		{"?q=a:b", true},
+		{"//example.com/a", true},
+		{"", true},
+		{"javascript:alert(1)", false},
+		{"JAVASCRIPT:alert(1)", false},
+		{" javascript:alert(1)", false},
+		{"java\x00script:alert(1)", false},
+		{"java\nscript:alert(1)", false},
+		{"javascript\t:alert(1)", false},
+		{"\x7fjavascript:alert(1)", false},
+		{"data:text/html,x", false},
+		{"vbscript:x", false},
+		{"file:///etc/passwd", false},
+		{"ftp://example.com/", false},
+	}
+	for _, tt := range tests {
+		if got := isSafeURL(tt.url); got != tt.want {
+			t.Errorf("isSafeURL(%q) = %v, want %v", tt.url, got, tt.want)
+		}
+	}
+}
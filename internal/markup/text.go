@@ -0,0 +1,101 @@
+package markup
+
+import (
+	"strings"
+	"unicode/utf8"
+
+	nethtml "golang.org/x/net/html"
+)
+
+// maxExcerptLength is the most runes an excerpt is cut down to
+const maxExcerptLength = 300
+
+// wordsPerMinute is the reading speed reading times are estimated with
+const wordsPerMinute = 200
+
+// blockTags separate the text inside them from the text around it
+var blockTags = map[string]bool{
+	"p": true, "br": true, "hr": true, "div": true, "blockquote": true, "pre": true,
+	"h1": true, "h2": true, "h3": true, "h4": true, "h5": true, "h6": true,
+	"ul": true, "ol": true, "li": true, "dl": true, "dt": true, "dd": true,
+	"table": true, "tr": true, "th": true, "td": true, "figure": true, "figcaption": true,
+}
+
+// Text returns the text of an HTML fragment, one line per block
+func Text(fragment string) string {
+	var b strings.Builder
+	tokenizer := nethtml.NewTokenizer(strings.NewReader(fragment))
+	for {
+		switch tokenizer.Next() {
+		case nethtml.ErrorToken:
+			return strings.TrimSpace(b.String())
+		case nethtml.TextToken:
+			b.WriteString(tokenizer.Token().Data)
+		case nethtml.StartTagToken, nethtml.EndTagToken, nethtml.SelfClosingTagToken:
+			if blockTags[tokenizer.Token().Data] {
+				b.WriteByte('\n')
+			}
+		}
+	}
+}
+
+// Excerpt returns the text of the first paragraph of an HTML fragment, or of
+// its first block if it has no paragraphs, shortened to a few sentences
+func Excerpt(fragment string) string {
+	excerpt := firstParagraph(fragment)
+	if excerpt == "" {
+		excerpt, _, _ = strings.Cut(Text(fragment), "\n")
+	}
+	excerpt = strings.Join(strings.Fields(excerpt), " ")
+
+	if utf8.RuneCountInString(excerpt) <= maxExcerptLength {
+		return excerpt
+	}
+	// Cut at the last space that fits, or mid-word if there is none
+	runes := []rune(excerpt)[:maxExcerptLength]
+	cut := string(runes)
+	if i := strings.LastIndexByte(cut, ' '); i > 0 {
+		cut = cut[:i]
+	}
+	return strings.TrimRight(cut, " ,;:.") + "…"
+}
+
+func firstParagraph(fragment string) string {
+	var b strings.Builder
+	inParagraph := false
+	tokenizer := nethtml.NewTokenizer(strings.NewReader(fragment))
+	for {
+		switch tokenizer.Next() {
+		case nethtml.ErrorToken:
+			return strings.TrimSpace(b.String())
+		case nethtml.TextToken:
+			if inParagraph {
+				b.WriteString(tokenizer.Token().Data)
+			}
+		case nethtml.StartTagToken:
+			if tokenizer.Token().Data == "p" {
+				inParagraph = true
+			} else if inParagraph && tokenizer.Token().Data == "br" {
+				b.WriteByte(' ')
+			}
+		case nethtml.EndTagToken:
+			if tokenizer.Token().Data == "p" && inParagraph {
+				// Paragraphs of only images or other markup don't count
+				if text := strings.TrimSpace(b.String()); text != "" {
+					return text
+				}
+				inParagraph = false
+			}
+		}
+	}
+}
+
+// CountWords returns the number of words in the text of an HTML fragment
+func CountWords(fragment string) int {
+	return len(strings.Fields(Text(fragment)))
+}
+
+// ReadingTime estimates how many minutes reading words takes, rounded up
+func ReadingTime(words int) int {
+	return (words + wordsPerMinute - 1) / wordsPerMinute
+}
@@ -0,0 +1,159 @@
+package migrations_test
+
+import (
+	"testing"
+
+	"github.com/terkoizmy/go-blog-api/internal/db"
+	"github.com/terkoizmy/go-blog-api/internal/migrations"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+func openMemory(t *testing.T) *gorm.DB {
+	t.Helper()
+	conn, err := db.Open(db.DriverSQLite, ":memory:")
+	if err != nil {
+		t.Fatal(err)
+	}
+	conn.Logger = logger.Discard
+	t.Cleanup(func() {
+		if sqlDB, err := conn.DB(); err == nil {
+			sqlDB.Close()
+		}
+	})
+	return conn
+}
+
+func TestDialectsHaveTheSameMigrations(t *testing.T) {
+	var versions [][]migrations.Migration
+	for _, dialect := range migrations.Dialects {
+		loaded, err := migrations.Load(dialect)
+		if err != nil {
+			t.Fatal(err)
+		}
+		versions = append(versions, loaded)
+	}
+
+	for i := 1; i < len(versions); i++ {
+		if len(versions[i]) != len(versions[0]) {
+			t.Fatalf("%s has %d migrations, %s has %d",
+				migrations.Dialects[i], len(versions[i]), migrations.Dialects[0], len(versions[0]))
+		}
+		for j, m := range versions[i] {
+			if m.Version != versions[0][j].Version || m.Name != versions[0][j].Name {
+				t.Errorf("%s has %04d_%s where %s has %04d_%s", migrations.Dialects[i], m.Version, m.Name,
+					migrations.Dialects[0], versions[0][j].Version, versions[0][j].Name)
+			}
+			if m.Up == "" || m.Down == "" {
+				t.Errorf("%s %04d_%s is missing its up or down file", migrations.Dialects[i], m.Version, m.Name)
+			}
+		}
+	}
+}
+
+func TestUpAndDown(t *testing.T) {
+	conn := openMemory(t)
+
+	all, err := migrations.Load(db.DriverSQLite)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	applied, err := migrations.Up(conn)
+	if err != nil {
+		t.Fatalf("up: %v", err)
+	}
+	if len(applied) != len(all) {
+		t.Fatalf("applied %d migrations, want %d", len(applied), len(all))
+	}
+	if pending, err := migrations.Pending(conn); err != nil || len(pending) != 0 {
+		t.Fatalf("pending after up: %v, %v", pending, err)
+	}
+
+	// Applying again is a no-op
+	if again, err := migrations.Up(conn); err != nil || len(again) != 0 {
+		t.Fatalf("second up applied %d: %v", len(again), err)
+	}
+
+	rolledBack, err := migrations.Down(conn, len(all))
+	if err != nil {
+		t.Fatalf("down: %v", err)
+	}
+	if len(rolledBack) != len(all) {
+		t.Fatalf("rolled back %d migrations, want %d", len(rolledBack), len(all))
+	}
+	for _, table := range []string{"users", "posts", "categories", "comments", "tags"} {
+		if conn.Migrator().HasTable(table) {
+			t.Errorf("table %s is left after rolling everything back", table)
+		}
+	}
+
+	if _, err := migrations.Up(conn); err != nil {
+		t.Fatalf("up after down: %v", err)
+	}
+}
+
+func TestStatus(t *testing.T) {
+	conn := openMemory(t)
+
+	if _, err := migrations.Up(conn); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := migrations.Down(conn, 1); err != nil {
+		t.Fatal(err)
+	}
+
+	statuses, err := migrations.GetStatus(conn)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i, s := range statuses {
+		last := i == len(statuses)-1
+		if (s.AppliedAt == nil) != last {
+			t.Errorf("%04d_%s applied at %v", s.Version, s.Name, s.AppliedAt)
+		}
+	}
+}
+
+func TestBuiltInRolePermissions(t *testing.T) {
+	conn := openMemory(t)
+	if _, err := migrations.Up(conn); err != nil {
+		t.Fatal(err)
+	}
+
+	// Back to before 0013_builtin_role_permissions
+	all, err := migrations.Load(conn.Dialector.Name())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := migrations.Down(conn, len(all)-12); err != nil {
+		t.Fatal(err)
+	}
+
+	// Roles as seeded before editors could review and manage tags
+	err = conn.Exec(`INSERT INTO roles (name, permissions, built_in) VALUES
+		('editor', '["posts:publish","posts:edit_any"]', true),
+		('user', '["posts:publish"]', true),
+		('writer', '["posts:publish"]', false)`).Error
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := migrations.Up(conn); err != nil {
+		t.Fatal(err)
+	}
+
+	want := map[string]string{
+		"editor": `["posts:publish","posts:edit_any","posts:review","tags:manage"]`,
+		"user":   `[]`,
+		"writer": `["posts:publish"]`,
+	}
+	for name, permissions := range want {
+		var got string
+		if err := conn.Raw("SELECT permissions FROM roles WHERE name = ?", name).Scan(&got).Error; err != nil {
+			t.Fatal(err)
+		}
+		if got != permissions {
+			t.Errorf("%s has %s, want %s", name, got, permissions)
+		}
+	}
+}
@@ -0,0 +1,249 @@
+-- Schema as previously created by AutoMigrate. Everything is guarded with
+-- IF NOT EXISTS so databases created before migrations existed are adopted
+-- without changes. Columns added to existing tables after the first release
+-- are also added separately, since CREATE TABLE IF NOT EXISTS leaves the
+-- tables of older databases as they are.
+
+CREATE TABLE IF NOT EXISTS users (
+    id uuid NOT NULL,
+    created_at timestamptz,
+    updated_at timestamptz,
+    deleted_at timestamptz,
+    username varchar(255) NOT NULL,
+    email varchar(255) NOT NULL,
+    password varchar(255) NOT NULL,
+    first_name varchar(255),
+    last_name varchar(255),
+    role varchar(50) DEFAULT 'user',
+    email_verified_at timestamptz,
+    two_factor_enabled boolean DEFAULT false,
+    totp_secret varchar(64),
+    totp_last_used_step bigint,
+    tokens_revoked_at timestamptz,
+    PRIMARY KEY (id)
+);
+CREATE UNIQUE INDEX IF NOT EXISTS idx_users_username ON users (username);
+CREATE UNIQUE INDEX IF NOT EXISTS idx_users_email ON users (email);
+CREATE INDEX IF NOT EXISTS idx_users_deleted_at ON users (deleted_at);
+ALTER TABLE users ADD COLUMN IF NOT EXISTS email_verified_at timestamptz;
+ALTER TABLE users ADD COLUMN IF NOT EXISTS two_factor_enabled boolean DEFAULT false;
+ALTER TABLE users ADD COLUMN IF NOT EXISTS totp_secret varchar(64);
+ALTER TABLE users ADD COLUMN IF NOT EXISTS totp_last_used_step bigint;
+ALTER TABLE users ADD COLUMN IF NOT EXISTS tokens_revoked_at timestamptz;
+
+CREATE TABLE IF NOT EXISTS posts (
+    id uuid NOT NULL,
+    created_at timestamptz,
+    updated_at timestamptz,
+    deleted_at timestamptz,
+    title varchar(255) NOT NULL,
+    content text NOT NULL,
+    slug varchar(255) NOT NULL,
+    author_id uuid NOT NULL,
+    status varchar(50) DEFAULT 'draft',
+    published_at timestamptz,
+    PRIMARY KEY (id),
+    CONSTRAINT fk_users_posts FOREIGN KEY (author_id) REFERENCES users (id)
+);
+CREATE UNIQUE INDEX IF NOT EXISTS idx_posts_slug ON posts (slug);
+CREATE INDEX IF NOT EXISTS idx_posts_deleted_at ON posts (deleted_at);
+
+CREATE TABLE IF NOT EXISTS categories (
+    id uuid NOT NULL,
+    created_at timestamptz,
+    updated_at timestamptz,
+    deleted_at timestamptz,
+    name varchar(255) NOT NULL,
+    slug varchar(255) NOT NULL,
+    PRIMARY KEY (id)
+);
+CREATE UNIQUE INDEX IF NOT EXISTS idx_categories_name ON categories (name);
+CREATE UNIQUE INDEX IF NOT EXISTS idx_categories_slug ON categories (slug);
+CREATE INDEX IF NOT EXISTS idx_categories_deleted_at ON categories (deleted_at);
+
+CREATE TABLE IF NOT EXISTS post_categories (
+    category_id uuid NOT NULL,
+    post_id uuid NOT NULL,
+    PRIMARY KEY (category_id, post_id),
+    CONSTRAINT fk_post_categories_category FOREIGN KEY (category_id) REFERENCES categories (id),
+    CONSTRAINT fk_post_categories_post FOREIGN KEY (post_id) REFERENCES posts (id)
+);
+
+CREATE TABLE IF NOT EXISTS comments (
+    id uuid NOT NULL,
+    created_at timestamptz,
+    updated_at timestamptz,
+    deleted_at timestamptz,
+    content text NOT NULL,
+    post_id uuid NOT NULL,
+    author_id uuid NOT NULL,
+    parent_id uuid,
+    PRIMARY KEY (id),
+    CONSTRAINT fk_posts_comments FOREIGN KEY (post_id) REFERENCES posts (id),
+    CONSTRAINT fk_comments_author FOREIGN KEY (author_id) REFERENCES users (id),
+    CONSTRAINT fk_comments_replies FOREIGN KEY (parent_id) REFERENCES comments (id)
+);
+CREATE INDEX IF NOT EXISTS idx_comments_deleted_at ON comments (deleted_at);
+
+CREATE TABLE IF NOT EXISTS refresh_tokens (
+    id uuid NOT NULL,
+    created_at timestamptz,
+    updated_at timestamptz,
+    deleted_at timestamptz,
+    user_id uuid NOT NULL,
+    family_id uuid NOT NULL,
+    token_hash varchar(64) NOT NULL,
+    expires_at timestamptz NOT NULL,
+    rotated_at timestamptz,
+    revoked_at timestamptz,
+    PRIMARY KEY (id),
+    CONSTRAINT fk_refresh_tokens_user FOREIGN KEY (user_id) REFERENCES users (id)
+);
+CREATE UNIQUE INDEX IF NOT EXISTS idx_refresh_tokens_token_hash ON refresh_tokens (token_hash);
+CREATE INDEX IF NOT EXISTS idx_refresh_tokens_user_id ON refresh_tokens (user_id);
+CREATE INDEX IF NOT EXISTS idx_refresh_tokens_family_id ON refresh_tokens (family_id);
+CREATE INDEX IF NOT EXISTS idx_refresh_tokens_deleted_at ON refresh_tokens (deleted_at);
+
+CREATE TABLE IF NOT EXISTS revoked_tokens (
+    jti varchar(64) NOT NULL,
+    user_id uuid NOT NULL,
+    expires_at timestamptz NOT NULL,
+    created_at timestamptz,
+    PRIMARY KEY (jti)
+);
+CREATE INDEX IF NOT EXISTS idx_revoked_tokens_user_id ON revoked_tokens (user_id);
+CREATE INDEX IF NOT EXISTS idx_revoked_tokens_expires_at ON revoked_tokens (expires_at);
+
+CREATE TABLE IF NOT EXISTS password_reset_tokens (
+    id uuid NOT NULL,
+    created_at timestamptz,
+    updated_at timestamptz,
+    deleted_at timestamptz,
+    user_id uuid NOT NULL,
+    token_hash varchar(64) NOT NULL,
+    expires_at timestamptz NOT NULL,
+    used_at timestamptz,
+    PRIMARY KEY (id),
+    CONSTRAINT fk_password_reset_tokens_user FOREIGN KEY (user_id) REFERENCES users (id)
+);
+CREATE UNIQUE INDEX IF NOT EXISTS idx_password_reset_tokens_token_hash ON password_reset_tokens (token_hash);
+CREATE INDEX IF NOT EXISTS idx_password_reset_tokens_user_id ON password_reset_tokens (user_id);
+CREATE INDEX IF NOT EXISTS idx_password_reset_tokens_deleted_at ON password_reset_tokens (deleted_at);
+
+CREATE TABLE IF NOT EXISTS email_verification_tokens (
+    id uuid NOT NULL,
+    created_at timestamptz,
+    updated_at timestamptz,
+    deleted_at timestamptz,
+    user_id uuid NOT NULL,
+    email varchar(255) NOT NULL,
+    token_hash varchar(64) NOT NULL,
+    expires_at timestamptz NOT NULL,
+    used_at timestamptz,
+    PRIMARY KEY (id),
+    CONSTRAINT fk_email_verification_tokens_user FOREIGN KEY (user_id) REFERENCES users (id)
+);
+CREATE UNIQUE INDEX IF NOT EXISTS idx_email_verification_tokens_token_hash ON email_verification_tokens (token_hash);
+CREATE INDEX IF NOT EXISTS idx_email_verification_tokens_user_id ON email_verification_tokens (user_id);
+CREATE INDEX IF NOT EXISTS idx_email_verification_tokens_deleted_at ON email_verification_tokens (deleted_at);
+
+CREATE TABLE IF NOT EXISTS recovery_codes (
+    id uuid NOT NULL,
+    created_at timestamptz,
+    updated_at timestamptz,
+    deleted_at timestamptz,
+    user_id uuid NOT NULL,
+    code_hash varchar(64) NOT NULL,
+    used_at timestamptz,
+    PRIMARY KEY (id),
+    CONSTRAINT fk_recovery_codes_user FOREIGN KEY (user_id) REFERENCES users (id)
+);
+CREATE INDEX IF NOT EXISTS idx_recovery_codes_user_id ON recovery_codes (user_id);
+CREATE INDEX IF NOT EXISTS idx_recovery_codes_code_hash ON recovery_codes (code_hash);
+CREATE INDEX IF NOT EXISTS idx_recovery_codes_deleted_at ON recovery_codes (deleted_at);
+
+CREATE TABLE IF NOT EXISTS personal_access_tokens (
+    id uuid NOT NULL,
+    created_at timestamptz,
+    updated_at timestamptz,
+    deleted_at timestamptz,
+    user_id uuid NOT NULL,
+    name varchar(255) NOT NULL,
+    token_prefix varchar(16) NOT NULL,
+    token_hash varchar(64) NOT NULL,
+    scopes text,
+    last_used_at timestamptz,
+    expires_at timestamptz,
+    revoked_at timestamptz,
+    PRIMARY KEY (id),
+    CONSTRAINT fk_personal_access_tokens_user FOREIGN KEY (user_id) REFERENCES users (id)
+);
+CREATE UNIQUE INDEX IF NOT EXISTS idx_personal_access_tokens_token_hash ON personal_access_tokens (token_hash);
+CREATE INDEX IF NOT EXISTS idx_personal_access_tokens_user_id ON personal_access_tokens (user_id);
+CREATE INDEX IF NOT EXISTS idx_personal_access_tokens_deleted_at ON personal_access_tokens (deleted_at);
+
+CREATE TABLE IF NOT EXISTS roles (
+    name varchar(50) NOT NULL,
+    description varchar(255),
+    permissions text,
+    built_in boolean DEFAULT false,
+    created_at timestamptz,
+    updated_at timestamptz,
+    PRIMARY KEY (name)
+);
+
+CREATE TABLE IF NOT EXISTS login_attempts (
+    id uuid NOT NULL,
+    created_at timestamptz,
+    updated_at timestamptz,
+    deleted_at timestamptz,
+    user_id uuid,
+    username varchar(255),
+    ip_address varchar(64),
+    succeeded boolean DEFAULT false,
+    PRIMARY KEY (id)
+);
+CREATE INDEX IF NOT EXISTS idx_login_attempts_user_id ON login_attempts (user_id);
+CREATE INDEX IF NOT EXISTS idx_login_attempts_ip_address ON login_attempts (ip_address);
+CREATE INDEX IF NOT EXISTS idx_login_attempts_deleted_at ON login_attempts (deleted_at);
+
+CREATE TABLE IF NOT EXISTS login_throttles (
+    key varchar(128) NOT NULL,
+    failures bigint NOT NULL DEFAULT 0,
+    last_failure_at timestamptz,
+    locked_until timestamptz,
+    PRIMARY KEY (key)
+);
+
+CREATE TABLE IF NOT EXISTS linked_identities (
+    id uuid NOT NULL,
+    created_at timestamptz,
+    updated_at timestamptz,
+    deleted_at timestamptz,
+    user_id uuid NOT NULL,
+    provider varchar(50) NOT NULL,
+    subject varchar(255) NOT NULL,
+    email varchar(255),
+    last_login_at timestamptz,
+    PRIMARY KEY (id),
+    CONSTRAINT fk_linked_identities_user FOREIGN KEY (user_id) REFERENCES users (id)
+);
+CREATE UNIQUE INDEX IF NOT EXISTS idx_linked_identity_subject ON linked_identities (provider, subject);
+CREATE INDEX IF NOT EXISTS idx_linked_identities_user_id ON linked_identities (user_id);
+CREATE INDEX IF NOT EXISTS idx_linked_identities_deleted_at ON linked_identities (deleted_at);
+
+CREATE TABLE IF NOT EXISTS o_auth_states (
+    id uuid NOT NULL,
+    created_at timestamptz,
+    updated_at timestamptz,
+    deleted_at timestamptz,
+    state_hash varchar(64) NOT NULL,
+    provider varchar(50) NOT NULL,
+    code_verifier varchar(128) NOT NULL,
+    nonce varchar(64) NOT NULL,
+    user_id uuid,
+    expires_at timestamptz NOT NULL,
+    PRIMARY KEY (id)
+);
+CREATE UNIQUE INDEX IF NOT EXISTS idx_o_auth_states_state_hash ON o_auth_states (state_hash);
+CREATE INDEX IF NOT EXISTS idx_o_auth_states_deleted_at ON o_auth_states (deleted_at);
@@ -0,0 +1,2 @@
+DROP INDEX IF EXISTS idx_comments_post_feed;
+DROP INDEX IF EXISTS idx_posts_feed;
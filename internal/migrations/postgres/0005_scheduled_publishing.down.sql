@@ -0,0 +1,2 @@
+DROP INDEX IF EXISTS idx_posts_publish_at;
+ALTER TABLE posts DROP COLUMN IF EXISTS publish_at;
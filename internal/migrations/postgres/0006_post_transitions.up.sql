@@ -0,0 +1,19 @@
+-- History of post status changes. user_id is null for changes made by the
+-- server, such as scheduled publishing.
+
+CREATE TABLE IF NOT EXISTS post_transitions (
+    id uuid NOT NULL,
+    created_at timestamptz,
+    updated_at timestamptz,
+    deleted_at timestamptz,
+    post_id uuid NOT NULL,
+    from_status varchar(50),
+    to_status varchar(50) NOT NULL,
+    user_id uuid,
+    note text,
+    PRIMARY KEY (id),
+    CONSTRAINT fk_post_transitions_post FOREIGN KEY (post_id) REFERENCES posts (id),
+    CONSTRAINT fk_post_transitions_user FOREIGN KEY (user_id) REFERENCES users (id)
+);
+CREATE INDEX IF NOT EXISTS idx_post_transitions_post_id ON post_transitions (post_id);
+CREATE INDEX IF NOT EXISTS idx_post_transitions_deleted_at ON post_transitions (deleted_at);
@@ -0,0 +1,6 @@
+-- Content formats and rendered HTML. Rows from before are Markdown and get
+-- their HTML rendered when read, until they are next saved.
+
+ALTER TABLE posts ADD COLUMN IF NOT EXISTS content_format varchar(20) DEFAULT 'markdown';
+ALTER TABLE posts ADD COLUMN IF NOT EXISTS content_html text;
+ALTER TABLE comments ADD COLUMN IF NOT EXISTS content_html text;
@@ -0,0 +1,4 @@
+ALTER TABLE posts DROP COLUMN IF EXISTS reading_time;
+ALTER TABLE posts DROP COLUMN IF EXISTS word_count;
+ALTER TABLE posts DROP COLUMN IF EXISTS custom_excerpt;
+ALTER TABLE posts DROP COLUMN IF EXISTS excerpt;
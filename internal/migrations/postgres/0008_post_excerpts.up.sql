@@ -0,0 +1,9 @@
+-- Post excerpts, word counts and reading times. Clearing the HTML cache has
+-- existing posts rendered again with these, which `migrate up` stores once
+-- the migrations have been applied.
+
+ALTER TABLE posts ADD COLUMN IF NOT EXISTS excerpt text;
+ALTER TABLE posts ADD COLUMN IF NOT EXISTS custom_excerpt boolean DEFAULT false;
+ALTER TABLE posts ADD COLUMN IF NOT EXISTS word_count bigint DEFAULT 0;
+ALTER TABLE posts ADD COLUMN IF NOT EXISTS reading_time bigint DEFAULT 0;
+UPDATE posts SET content_html = NULL;
@@ -0,0 +1,4 @@
+-- Nested categories. Top-level categories have no parent.
+
+ALTER TABLE categories ADD COLUMN IF NOT EXISTS parent_id uuid REFERENCES categories (id);
+CREATE INDEX IF NOT EXISTS idx_categories_parent_id ON categories (parent_id);
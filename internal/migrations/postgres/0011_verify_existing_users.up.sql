@@ -0,0 +1,10 @@
+-- Accounts created before email verification existed were never sent a
+-- verification email, so turning on EMAIL_VERIFICATION would lock them out.
+-- Every account registered since has been sent at least one token, and
+-- accounts created through social login came later still, so the ones with
+-- neither are marked verified.
+
+UPDATE users SET email_verified_at = COALESCE(created_at, CURRENT_TIMESTAMP)
+WHERE email_verified_at IS NULL
+    AND NOT EXISTS (SELECT 1 FROM email_verification_tokens WHERE email_verification_tokens.user_id = users.id)
+    AND NOT EXISTS (SELECT 1 FROM linked_identities WHERE linked_identities.user_id = users.id);
@@ -0,0 +1,4 @@
+-- Pending social logins are bound to the browser that started them. Requests
+-- pending from before can't be completed and expire.
+
+ALTER TABLE o_auth_states ADD COLUMN binding_hash varchar(64) NOT NULL DEFAULT '';
@@ -0,0 +1,2 @@
+-- The permissions changed here can't be told apart from changes made to the
+-- roles since, so they stay as they are.
@@ -0,0 +1,13 @@
+-- Built-in roles keep the permissions they were created with, so existing
+-- databases are brought in line with new ones: editors review posts and
+-- manage tags, and the posts of registered users go through review instead
+-- of being published straight away.
+
+UPDATE roles SET permissions = (COALESCE(permissions, '[]')::jsonb || '["posts:review"]'::jsonb)::text
+WHERE name = 'editor' AND built_in AND NOT COALESCE(permissions, '[]')::jsonb @> '["posts:review"]'::jsonb;
+
+UPDATE roles SET permissions = (COALESCE(permissions, '[]')::jsonb || '["tags:manage"]'::jsonb)::text
+WHERE name = 'editor' AND built_in AND NOT COALESCE(permissions, '[]')::jsonb @> '["tags:manage"]'::jsonb;
+
+UPDATE roles SET permissions = (permissions::jsonb - 'posts:publish'::text)::text
+WHERE name = 'user' AND built_in AND permissions::jsonb @> '["posts:publish"]'::jsonb;
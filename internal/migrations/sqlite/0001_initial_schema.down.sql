@@ -0,0 +1,16 @@
+DROP TABLE IF EXISTS o_auth_states;
+DROP TABLE IF EXISTS linked_identities;
+DROP TABLE IF EXISTS login_throttles;
+DROP TABLE IF EXISTS login_attempts;
+DROP TABLE IF EXISTS roles;
+DROP TABLE IF EXISTS personal_access_tokens;
+DROP TABLE IF EXISTS recovery_codes;
+DROP TABLE IF EXISTS email_verification_tokens;
+DROP TABLE IF EXISTS password_reset_tokens;
+DROP TABLE IF EXISTS revoked_tokens;
+DROP TABLE IF EXISTS refresh_tokens;
+DROP TABLE IF EXISTS comments;
+DROP TABLE IF EXISTS post_categories;
+DROP TABLE IF EXISTS categories;
+DROP TABLE IF EXISTS posts;
+DROP TABLE IF EXISTS users;
@@ -0,0 +1,243 @@
+-- Schema as previously created by AutoMigrate. SQLite has no uuid or
+-- timestamp types; the declared names only decide how values are stored.
+-- SQLite support arrived after every column below existed, so databases
+-- AutoMigrate created are already complete and adopted as they are.
+
+CREATE TABLE IF NOT EXISTS users (
+    id uuid NOT NULL,
+    created_at datetime,
+    updated_at datetime,
+    deleted_at datetime,
+    username text NOT NULL,
+    email text NOT NULL,
+    password text NOT NULL,
+    first_name text,
+    last_name text,
+    role text DEFAULT 'user',
+    email_verified_at datetime,
+    two_factor_enabled numeric DEFAULT false,
+    totp_secret text,
+    totp_last_used_step integer,
+    tokens_revoked_at datetime,
+    PRIMARY KEY (id)
+);
+CREATE UNIQUE INDEX IF NOT EXISTS idx_users_username ON users (username);
+CREATE UNIQUE INDEX IF NOT EXISTS idx_users_email ON users (email);
+CREATE INDEX IF NOT EXISTS idx_users_deleted_at ON users (deleted_at);
+
+CREATE TABLE IF NOT EXISTS posts (
+    id uuid NOT NULL,
+    created_at datetime,
+    updated_at datetime,
+    deleted_at datetime,
+    title text NOT NULL,
+    content text NOT NULL,
+    slug text NOT NULL,
+    author_id uuid NOT NULL,
+    status text DEFAULT 'draft',
+    published_at datetime,
+    PRIMARY KEY (id),
+    CONSTRAINT fk_users_posts FOREIGN KEY (author_id) REFERENCES users (id)
+);
+CREATE UNIQUE INDEX IF NOT EXISTS idx_posts_slug ON posts (slug);
+CREATE INDEX IF NOT EXISTS idx_posts_deleted_at ON posts (deleted_at);
+
+CREATE TABLE IF NOT EXISTS categories (
+    id uuid NOT NULL,
+    created_at datetime,
+    updated_at datetime,
+    deleted_at datetime,
+    name text NOT NULL,
+    slug text NOT NULL,
+    PRIMARY KEY (id)
+);
+CREATE UNIQUE INDEX IF NOT EXISTS idx_categories_name ON categories (name);
+CREATE UNIQUE INDEX IF NOT EXISTS idx_categories_slug ON categories (slug);
+CREATE INDEX IF NOT EXISTS idx_categories_deleted_at ON categories (deleted_at);
+
+CREATE TABLE IF NOT EXISTS post_categories (
+    category_id uuid NOT NULL,
+    post_id uuid NOT NULL,
+    PRIMARY KEY (category_id, post_id),
+    CONSTRAINT fk_post_categories_category FOREIGN KEY (category_id) REFERENCES categories (id),
+    CONSTRAINT fk_post_categories_post FOREIGN KEY (post_id) REFERENCES posts (id)
+);
+
+CREATE TABLE IF NOT EXISTS comments (
+    id uuid NOT NULL,
+    created_at datetime,
+    updated_at datetime,
+    deleted_at datetime,
+    content text NOT NULL,
+    post_id uuid NOT NULL,
+    author_id uuid NOT NULL,
+    parent_id uuid,
+    PRIMARY KEY (id),
+    CONSTRAINT fk_posts_comments FOREIGN KEY (post_id) REFERENCES posts (id),
+    CONSTRAINT fk_comments_author FOREIGN KEY (author_id) REFERENCES users (id),
+    CONSTRAINT fk_comments_replies FOREIGN KEY (parent_id) REFERENCES comments (id)
+);
+CREATE INDEX IF NOT EXISTS idx_comments_deleted_at ON comments (deleted_at);
+
+CREATE TABLE IF NOT EXISTS refresh_tokens (
+    id uuid NOT NULL,
+    created_at datetime,
+    updated_at datetime,
+    deleted_at datetime,
+    user_id uuid NOT NULL,
+    family_id uuid NOT NULL,
+    token_hash text NOT NULL,
+    expires_at datetime NOT NULL,
+    rotated_at datetime,
+    revoked_at datetime,
+    PRIMARY KEY (id),
+    CONSTRAINT fk_refresh_tokens_user FOREIGN KEY (user_id) REFERENCES users (id)
+);
+CREATE UNIQUE INDEX IF NOT EXISTS idx_refresh_tokens_token_hash ON refresh_tokens (token_hash);
+CREATE INDEX IF NOT EXISTS idx_refresh_tokens_user_id ON refresh_tokens (user_id);
+CREATE INDEX IF NOT EXISTS idx_refresh_tokens_family_id ON refresh_tokens (family_id);
+CREATE INDEX IF NOT EXISTS idx_refresh_tokens_deleted_at ON refresh_tokens (deleted_at);
+
+CREATE TABLE IF NOT EXISTS revoked_tokens (
+    jti text NOT NULL,
+    user_id uuid NOT NULL,
+    expires_at datetime NOT NULL,
+    created_at datetime,
+    PRIMARY KEY (jti)
+);
+CREATE INDEX IF NOT EXISTS idx_revoked_tokens_user_id ON revoked_tokens (user_id);
+CREATE INDEX IF NOT EXISTS idx_revoked_tokens_expires_at ON revoked_tokens (expires_at);
+
+CREATE TABLE IF NOT EXISTS password_reset_tokens (
+    id uuid NOT NULL,
+    created_at datetime,
+    updated_at datetime,
+    deleted_at datetime,
+    user_id uuid NOT NULL,
+    token_hash text NOT NULL,
+    expires_at datetime NOT NULL,
+    used_at datetime,
+    PRIMARY KEY (id),
+    CONSTRAINT fk_password_reset_tokens_user FOREIGN KEY (user_id) REFERENCES users (id)
+);
+CREATE UNIQUE INDEX IF NOT EXISTS idx_password_reset_tokens_token_hash ON password_reset_tokens (token_hash);
+CREATE INDEX IF NOT EXISTS idx_password_reset_tokens_user_id ON password_reset_tokens (user_id);
+CREATE INDEX IF NOT EXISTS idx_password_reset_tokens_deleted_at ON password_reset_tokens (deleted_at);
+
+CREATE TABLE IF NOT EXISTS email_verification_tokens (
+    id uuid NOT NULL,
+    created_at datetime,
+    updated_at datetime,
+    deleted_at datetime,
+    user_id uuid NOT NULL,
+    email text NOT NULL,
+    token_hash text NOT NULL,
+    expires_at datetime NOT NULL,
+    used_at datetime,
+    PRIMARY KEY (id),
+    CONSTRAINT fk_email_verification_tokens_user FOREIGN KEY (user_id) REFERENCES users (id)
+);
+CREATE UNIQUE INDEX IF NOT EXISTS idx_email_verification_tokens_token_hash ON email_verification_tokens (token_hash);
+CREATE INDEX IF NOT EXISTS idx_email_verification_tokens_user_id ON email_verification_tokens (user_id);
+CREATE INDEX IF NOT EXISTS idx_email_verification_tokens_deleted_at ON email_verification_tokens (deleted_at);
+
+CREATE TABLE IF NOT EXISTS recovery_codes (
+    id uuid NOT NULL,
+    created_at datetime,
+    updated_at datetime,
+    deleted_at datetime,
+    user_id uuid NOT NULL,
+    code_hash text NOT NULL,
+    used_at datetime,
+    PRIMARY KEY (id),
+    CONSTRAINT fk_recovery_codes_user FOREIGN KEY (user_id) REFERENCES users (id)
+);
+CREATE INDEX IF NOT EXISTS idx_recovery_codes_user_id ON recovery_codes (user_id);
+CREATE INDEX IF NOT EXISTS idx_recovery_codes_code_hash ON recovery_codes (code_hash);
+CREATE INDEX IF NOT EXISTS idx_recovery_codes_deleted_at ON recovery_codes (deleted_at);
+
+CREATE TABLE IF NOT EXISTS personal_access_tokens (
+    id uuid NOT NULL,
+    created_at datetime,
+    updated_at datetime,
+    deleted_at datetime,
+    user_id uuid NOT NULL,
+    name text NOT NULL,
+    token_prefix text NOT NULL,
+    token_hash text NOT NULL,
+    scopes text,
+    last_used_at datetime,
+    expires_at datetime,
+    revoked_at datetime,
+    PRIMARY KEY (id),
+    CONSTRAINT fk_personal_access_tokens_user FOREIGN KEY (user_id) REFERENCES users (id)
+);
+CREATE UNIQUE INDEX IF NOT EXISTS idx_personal_access_tokens_token_hash ON personal_access_tokens (token_hash);
+CREATE INDEX IF NOT EXISTS idx_personal_access_tokens_user_id ON personal_access_tokens (user_id);
+CREATE INDEX IF NOT EXISTS idx_personal_access_tokens_deleted_at ON personal_access_tokens (deleted_at);
+
+CREATE TABLE IF NOT EXISTS roles (
+    name text NOT NULL,
+    description text,
+    permissions text,
+    built_in numeric DEFAULT false,
+    created_at datetime,
+    updated_at datetime,
+    PRIMARY KEY (name)
+);
+
+CREATE TABLE IF NOT EXISTS login_attempts (
+    id uuid NOT NULL,
+    created_at datetime,
+    updated_at datetime,
+    deleted_at datetime,
+    user_id uuid,
+    username text,
+    ip_address text,
+    succeeded numeric DEFAULT false,
+    PRIMARY KEY (id)
+);
+CREATE INDEX IF NOT EXISTS idx_login_attempts_user_id ON login_attempts (user_id);
+CREATE INDEX IF NOT EXISTS idx_login_attempts_ip_address ON login_attempts (ip_address);
+CREATE INDEX IF NOT EXISTS idx_login_attempts_deleted_at ON login_attempts (deleted_at);
+
+CREATE TABLE IF NOT EXISTS login_throttles (
+    key text NOT NULL,
+    failures integer NOT NULL DEFAULT 0,
+    last_failure_at datetime,
+    locked_until datetime,
+    PRIMARY KEY (key)
+);
+
+CREATE TABLE IF NOT EXISTS linked_identities (
+    id uuid NOT NULL,
+    created_at datetime,
+    updated_at datetime,
+    deleted_at datetime,
+    user_id uuid NOT NULL,
+    provider text NOT NULL,
+    subject text NOT NULL,
+    email text,
+    last_login_at datetime,
+    PRIMARY KEY (id),
+    CONSTRAINT fk_linked_identities_user FOREIGN KEY (user_id) REFERENCES users (id)
+);
+CREATE UNIQUE INDEX IF NOT EXISTS idx_linked_identity_subject ON linked_identities (provider, subject);
+CREATE INDEX IF NOT EXISTS idx_linked_identities_user_id ON linked_identities (user_id);
+CREATE INDEX IF NOT EXISTS idx_linked_identities_deleted_at ON linked_identities (deleted_at);
+
+CREATE TABLE IF NOT EXISTS o_auth_states (
+    id uuid NOT NULL,
+    created_at datetime,
+    updated_at datetime,
+    deleted_at datetime,
+    state_hash text NOT NULL,
+    provider text NOT NULL,
+    code_verifier text NOT NULL,
+    nonce text NOT NULL,
+    user_id uuid,
+    expires_at datetime NOT NULL,
+    PRIMARY KEY (id)
+);
+CREATE UNIQUE INDEX IF NOT EXISTS idx_o_auth_states_state_hash ON o_auth_states (state_hash);
+CREATE INDEX IF NOT EXISTS idx_o_auth_states_deleted_at ON o_auth_states (deleted_at);
@@ -0,0 +1 @@
+-- Nothing to do: SQLite searches with LIKE, which can't use an index.
@@ -0,0 +1,4 @@
+-- Indexes for cursor pagination of the post and comment feeds.
+
+CREATE INDEX IF NOT EXISTS idx_posts_feed ON posts ((COALESCE(published_at, created_at)) DESC, id DESC);
+CREATE INDEX IF NOT EXISTS idx_comments_post_feed ON comments (post_id, created_at, id);
@@ -0,0 +1,20 @@
+-- Post revision history. Categories are stored as a JSON snapshot.
+
+CREATE TABLE IF NOT EXISTS post_revisions (
+    id uuid NOT NULL,
+    created_at datetime,
+    updated_at datetime,
+    deleted_at datetime,
+    post_id uuid NOT NULL,
+    number integer NOT NULL,
+    author_id uuid NOT NULL,
+    title text NOT NULL,
+    content text NOT NULL,
+    categories text,
+    restored_from integer,
+    PRIMARY KEY (id),
+    CONSTRAINT fk_post_revisions_post FOREIGN KEY (post_id) REFERENCES posts (id),
+    CONSTRAINT fk_post_revisions_author FOREIGN KEY (author_id) REFERENCES users (id)
+);
+CREATE UNIQUE INDEX IF NOT EXISTS idx_post_revisions_post_number ON post_revisions (post_id, number);
+CREATE INDEX IF NOT EXISTS idx_post_revisions_deleted_at ON post_revisions (deleted_at);
@@ -0,0 +1,4 @@
+-- Scheduled publishing. The scheduler looks up due posts by publish_at.
+
+ALTER TABLE posts ADD COLUMN publish_at datetime;
+CREATE INDEX IF NOT EXISTS idx_posts_publish_at ON posts (publish_at) WHERE status = 'scheduled';
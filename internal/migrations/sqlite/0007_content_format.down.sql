@@ -0,0 +1,3 @@
+ALTER TABLE comments DROP COLUMN content_html;
+ALTER TABLE posts DROP COLUMN content_html;
+ALTER TABLE posts DROP COLUMN content_format;
@@ -0,0 +1,6 @@
+-- Content formats and rendered HTML. Rows from before are Markdown and get
+-- their HTML rendered when read, until they are next saved.
+
+ALTER TABLE posts ADD COLUMN content_format text DEFAULT 'markdown';
+ALTER TABLE posts ADD COLUMN content_html text;
+ALTER TABLE comments ADD COLUMN content_html text;
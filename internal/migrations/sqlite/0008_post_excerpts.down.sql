@@ -0,0 +1,4 @@
+ALTER TABLE posts DROP COLUMN reading_time;
+ALTER TABLE posts DROP COLUMN word_count;
+ALTER TABLE posts DROP COLUMN custom_excerpt;
+ALTER TABLE posts DROP COLUMN excerpt;
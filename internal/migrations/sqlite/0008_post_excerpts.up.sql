@@ -0,0 +1,9 @@
+-- Post excerpts, word counts and reading times. Clearing the HTML cache has
+-- existing posts rendered again with these, which `migrate up` stores once
+-- the migrations have been applied.
+
+ALTER TABLE posts ADD COLUMN excerpt text;
+ALTER TABLE posts ADD COLUMN custom_excerpt numeric DEFAULT false;
+ALTER TABLE posts ADD COLUMN word_count integer DEFAULT 0;
+ALTER TABLE posts ADD COLUMN reading_time integer DEFAULT 0;
+UPDATE posts SET content_html = NULL;
@@ -0,0 +1,2 @@
+-- Backfilled addresses can't be told apart from ones verified since, so they
+-- stay verified.
@@ -0,0 +1 @@
+ALTER TABLE o_auth_states DROP COLUMN binding_hash;
@@ -0,0 +1,16 @@
+-- Built-in roles keep the permissions they were created with, so existing
+-- databases are brought in line with new ones: editors review posts and
+-- manage tags, and the posts of registered users go through review instead
+-- of being published straight away.
+
+UPDATE roles SET permissions = json_insert(COALESCE(permissions, '[]'), '$[#]', 'posts:review')
+WHERE name = 'editor' AND built_in
+    AND NOT EXISTS (SELECT 1 FROM json_each(COALESCE(roles.permissions, '[]')) WHERE value = 'posts:review');
+
+UPDATE roles SET permissions = json_insert(COALESCE(permissions, '[]'), '$[#]', 'tags:manage')
+WHERE name = 'editor' AND built_in
+    AND NOT EXISTS (SELECT 1 FROM json_each(COALESCE(roles.permissions, '[]')) WHERE value = 'tags:manage');
+
+UPDATE roles SET permissions = (SELECT json_group_array(value) FROM json_each(roles.permissions) WHERE value <> 'posts:publish')
+WHERE name = 'user' AND built_in
+    AND EXISTS (SELECT 1 FROM json_each(roles.permissions) WHERE value = 'posts:publish');
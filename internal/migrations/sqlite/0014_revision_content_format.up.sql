@@ -0,0 +1,6 @@
+-- The content format of each revision, so restoring one renders its content
+-- the way it was written. Earlier revisions are taken to be in the current
+-- format of their post.
+
+ALTER TABLE post_revisions ADD COLUMN content_format text DEFAULT 'markdown';
+UPDATE post_revisions SET content_format = COALESCE((SELECT content_format FROM posts WHERE posts.id = post_revisions.post_id), 'markdown');
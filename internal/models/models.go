@@ -1,15 +1,19 @@
 package models
 
 import (
+	"encoding/json"
 	"time"
 
 	"github.com/google/uuid"
+	"github.com/terkoizmy/go-blog-api/internal/markup"
 	"gorm.io/gorm"
 )
 
-// Base model with UUID instead of auto-incrementing integer
+// Base model with UUID instead of auto-incrementing integer. UUID columns are
+// declared as type:uuid and long strings as type:text, which Postgres maps to
+// its native types and SQLite stores as text, so models stay portable.
 type Base struct {
-	ID        uuid.UUID      `gorm:"type:uuid;primary_key" json:"id"`
+	ID        uuid.UUID      `gorm:"type:uuid;primaryKey" json:"id"`
 	CreatedAt time.Time      `json:"created_at"`
 	UpdatedAt time.Time      `json:"updated_at"`
 	DeletedAt gorm.DeletedAt `gorm:"index" json:"-"`
@@ -25,26 +29,115 @@ func (base *Base) BeforeCreate(tx *gorm.DB) error {
 
 type User struct {
 	Base
-	Username  string `gorm:"uniqueIndex;size:255;not null" json:"username"`
-	Email     string `gorm:"uniqueIndex;size:255;not null" json:"email"`
-	Password  string `gorm:"size:255;not null" json:"-"`
-	FirstName string `gorm:"size:255" json:"first_name"`
-	LastName  string `gorm:"size:255" json:"last_name"`
-	Role      string `gorm:"size:50;default:'user'" json:"role"`
-	Posts     []Post `gorm:"foreignKey:AuthorID" json:"-"`
+	Username        string     `gorm:"uniqueIndex;size:255;not null" json:"username"`
+	Email           string     `gorm:"uniqueIndex;size:255;not null" json:"email"`
+	Password        string     `gorm:"size:255;not null" json:"-"`
+	FirstName       string     `gorm:"size:255" json:"first_name"`
+	LastName        string     `gorm:"size:255" json:"last_name"`
+	Role            string     `gorm:"size:50;default:'user'" json:"role"`
+	Posts           []Post     `gorm:"foreignKey:AuthorID" json:"-"`
+	EmailVerifiedAt *time.Time `json:"email_verified_at,omitempty"`
+	// Two-factor authentication. TOTPSecret is set on enrollment but only
+	// enforced once TwoFactorEnabled is true.
+	TwoFactorEnabled bool   `gorm:"default:false" json:"two_factor_enabled"`
+	TOTPSecret       string `gorm:"size:64" json:"-"`
+	TOTPLastUsedStep int64  `json:"-"`
+	// Access tokens issued before this time are rejected
+	TokensRevokedAt *time.Time `json:"-"`
 }
 
 type Post struct {
 	Base
 	Title       string     `gorm:"size:255;not null" json:"title"`
-	Content     string     `gorm:"type:text;not null" json:"content"`
+	Content     string     `gorm:"type:text;not null" json:"content,omitempty"`
 	Slug        string     `gorm:"uniqueIndex;size:255;not null" json:"slug"`
 	AuthorID    uuid.UUID  `gorm:"type:uuid;not null" json:"author_id"`
 	Author      User       `gorm:"foreignKey:AuthorID" json:"author"`
 	Status      string     `gorm:"size:50;default:'draft'" json:"status"`
 	PublishedAt *time.Time `json:"published_at,omitempty"`
-	Categories  []Category `gorm:"many2many:post_categories;" json:"categories"`
-	Comments    []Comment  `gorm:"foreignKey:PostID" json:"comments,omitempty"`
+	// PublishAt is when a scheduled post is due to be published
+	PublishAt  *time.Time `json:"publish_at,omitempty"`
+	Categories []Category `gorm:"many2many:post_categories;" json:"categories"`
+	Tags       []Tag      `gorm:"many2many:post_tags;" json:"tags"`
+	Comments   []Comment  `gorm:"foreignKey:PostID" json:"comments,omitempty"`
+	// ContentFormat is how Content is written: markdown, html or plain.
+	// ContentHTML caches it rendered and sanitized; it's only returned on
+	// request.
+	ContentFormat string `gorm:"size:20;default:'markdown'" json:"content_format"`
+	ContentHTML   string `gorm:"type:text" json:"-"`
+	// Excerpt is a plain text summary, taken from the first paragraph unless
+	// CustomExcerpt. ReadingTime is in minutes.
+	Excerpt       string `gorm:"type:text" json:"excerpt"`
+	CustomExcerpt bool   `gorm:"default:false" json:"custom_excerpt"`
+	WordCount     int    `gorm:"default:0" json:"word_count"`
+	ReadingTime   int    `gorm:"default:0" json:"reading_time"`
+}
+
+// BeforeSave renders the content into the HTML cache and updates the
+// excerpt and counts
+func (post *Post) BeforeSave(tx *gorm.DB) error {
+	post.render()
+	return nil
+}
+
+// AfterFind renders the content of posts saved before it was cached
+func (post *Post) AfterFind(tx *gorm.DB) error {
+	if post.ContentHTML == "" && post.Content != "" {
+		post.render()
+	}
+	return nil
+}
+
+func (post *Post) render() {
+	post.ContentHTML = markup.Render(post.ContentFormat, post.Content)
+	post.WordCount = markup.CountWords(post.ContentHTML)
+	post.ReadingTime = markup.ReadingTime(post.WordCount)
+	if !post.CustomExcerpt {
+		post.Excerpt = markup.Excerpt(post.ContentHTML)
+	}
+}
+
+// RenderedPost is a post along with its content rendered to HTML
+type RenderedPost struct {
+	Post
+	ContentHTML string `json:"content_html"`
+}
+
+// PostRevision is a snapshot of a post's title, content and categories,
+// recorded each time they change. Revisions are never modified; Number
+// counts the revisions of a post from 1.
+type PostRevision struct {
+	Base
+	PostID     uuid.UUID          `gorm:"type:uuid;not null;uniqueIndex:idx_post_revisions_post_number" json:"post_id"`
+	Number     int                `gorm:"not null;uniqueIndex:idx_post_revisions_post_number" json:"number"`
+	AuthorID   uuid.UUID          `gorm:"type:uuid;not null" json:"author_id"`
+	Author     User               `gorm:"foreignKey:AuthorID" json:"author"`
+	Title      string             `gorm:"size:255;not null" json:"title"`
+	Content    string             `gorm:"type:text;not null" json:"content"`
+	Categories []RevisionCategory `gorm:"serializer:json;type:text" json:"categories"`
+	// RestoredFrom is the number of the revision this one restored
+	RestoredFrom *int `json:"restored_from,omitempty"`
+	// ContentFormat is the format Content is written in
+	ContentFormat string `gorm:"size:20;default:'markdown'" json:"content_format"`
+}
+
+// PostTransition records a change of a post's status. UserID is nil for
+// changes made by the server, such as publishing a scheduled post.
+type PostTransition struct {
+	Base
+	PostID     uuid.UUID  `gorm:"type:uuid;not null;index" json:"post_id"`
+	FromStatus string     `gorm:"size:50" json:"from_status"`
+	ToStatus   string     `gorm:"size:50;not null" json:"to_status"`
+	UserID     *uuid.UUID `gorm:"type:uuid" json:"user_id,omitempty"`
+	User       *User      `gorm:"foreignKey:UserID" json:"user,omitempty"`
+	Note       string     `gorm:"type:text" json:"note,omitempty"`
+}
+
+// RevisionCategory is a category as it was when a revision was recorded
+type RevisionCategory struct {
+	ID   uuid.UUID `json:"id"`
+	Name string    `json:"name"`
+	Slug string    `json:"slug"`
 }
 
 type Category struct {
@@ -52,6 +145,39 @@ type Category struct {
 	Name  string `gorm:"uniqueIndex;size:255;not null" json:"name"`
 	Slug  string `gorm:"uniqueIndex;size:255;not null" json:"slug"`
 	Posts []Post `gorm:"many2many:post_categories;" json:"-"`
+	// ParentID nests the category under another one. Path lists the
+	// categories from the top level down to this one; it's only filled in on
+	// posts.
+	ParentID *uuid.UUID     `gorm:"type:uuid;index" json:"parent_id"`
+	Path     []CategoryLink `gorm:"-" json:"path,omitempty"`
+}
+
+// CategoryLink identifies a category in a breadcrumb path
+type CategoryLink struct {
+	ID   uuid.UUID `json:"id"`
+	Name string    `json:"name"`
+	Slug string    `json:"slug"`
+}
+
+// CategoryNode is a category along with its subcategories
+type CategoryNode struct {
+	Category
+	Children []CategoryNode `json:"children"`
+}
+
+// Tag is a free-form label writers put on posts. Tags are created when first
+// used and identified by their slug; Name is the spelling first used.
+type Tag struct {
+	Base
+	Name  string `gorm:"size:255;not null" json:"name"`
+	Slug  string `gorm:"uniqueIndex;size:255;not null" json:"slug"`
+	Posts []Post `gorm:"many2many:post_tags;" json:"-"`
+}
+
+// TagUsage is a tag with the number of published posts it is on
+type TagUsage struct {
+	Tag
+	PostCount int64 `json:"post_count"`
 }
 
 type Comment struct {
@@ -64,6 +190,148 @@ type Comment struct {
 	ParentID *uuid.UUID `gorm:"type:uuid" json:"parent_id,omitempty"`
 	Parent   *Comment   `gorm:"foreignKey:ParentID" json:"-"`
 	Replies  []Comment  `gorm:"foreignKey:ParentID" json:"replies,omitempty"`
+	// ContentHTML caches the Markdown content rendered and sanitized
+	ContentHTML string `gorm:"type:text" json:"content_html"`
+}
+
+// BeforeSave renders the content into the HTML cache
+func (comment *Comment) BeforeSave(tx *gorm.DB) error {
+	comment.ContentHTML = markup.Render(markup.Markdown, comment.Content)
+	return nil
+}
+
+// AfterFind renders the content of comments saved before it was cached
+func (comment *Comment) AfterFind(tx *gorm.DB) error {
+	if comment.ContentHTML == "" && comment.Content != "" {
+		comment.ContentHTML = markup.Render(markup.Markdown, comment.Content)
+	}
+	return nil
+}
+
+// Role is a named set of permissions. Users reference their role by name.
+type Role struct {
+	Name        string    `gorm:"primaryKey;size:50" json:"name"`
+	Description string    `gorm:"size:255" json:"description"`
+	Permissions []string  `gorm:"serializer:json;type:text" json:"permissions"`
+	BuiltIn     bool      `gorm:"default:false" json:"built_in"`
+	CreatedAt   time.Time `json:"created_at"`
+	UpdatedAt   time.Time `json:"updated_at"`
+}
+
+// RefreshToken is a server-side record of an issued refresh token. Tokens
+// issued from the same login share a FamilyID so that reuse of a rotated
+// token can revoke the whole chain.
+type RefreshToken struct {
+	Base
+	UserID    uuid.UUID  `gorm:"type:uuid;not null;index" json:"user_id"`
+	User      User       `gorm:"foreignKey:UserID" json:"-"`
+	FamilyID  uuid.UUID  `gorm:"type:uuid;not null;index" json:"family_id"`
+	TokenHash string     `gorm:"uniqueIndex;size:64;not null" json:"-"`
+	ExpiresAt time.Time  `gorm:"not null" json:"expires_at"`
+	RotatedAt *time.Time `json:"rotated_at,omitempty"`
+	RevokedAt *time.Time `json:"revoked_at,omitempty"`
+}
+
+// RevokedToken records an access token that was revoked before it expired.
+// Rows can be removed once ExpiresAt has passed.
+type RevokedToken struct {
+	JTI       string    `gorm:"primaryKey;size:64" json:"jti"`
+	UserID    uuid.UUID `gorm:"type:uuid;not null;index" json:"user_id"`
+	ExpiresAt time.Time `gorm:"not null;index" json:"expires_at"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// PasswordResetToken is a single-use token emailed to a user who forgot their
+// password. Only its hash is stored.
+type PasswordResetToken struct {
+	Base
+	UserID    uuid.UUID  `gorm:"type:uuid;not null;index" json:"user_id"`
+	User      User       `gorm:"foreignKey:UserID" json:"-"`
+	TokenHash string     `gorm:"uniqueIndex;size:64;not null" json:"-"`
+	ExpiresAt time.Time  `gorm:"not null" json:"expires_at"`
+	UsedAt    *time.Time `json:"used_at,omitempty"`
+}
+
+// EmailVerificationToken confirms that a user controls Email. A token is only
+// valid while Email is still the user's address.
+type EmailVerificationToken struct {
+	Base
+	UserID    uuid.UUID  `gorm:"type:uuid;not null;index" json:"user_id"`
+	User      User       `gorm:"foreignKey:UserID" json:"-"`
+	Email     string     `gorm:"size:255;not null" json:"email"`
+	TokenHash string     `gorm:"uniqueIndex;size:64;not null" json:"-"`
+	ExpiresAt time.Time  `gorm:"not null" json:"expires_at"`
+	UsedAt    *time.Time `json:"used_at,omitempty"`
+}
+
+// RecoveryCode is a single-use two-factor backup code. Only its hash is stored.
+type RecoveryCode struct {
+	Base
+	UserID   uuid.UUID  `gorm:"type:uuid;not null;index" json:"user_id"`
+	User     User       `gorm:"foreignKey:UserID" json:"-"`
+	CodeHash string     `gorm:"size:64;not null;index" json:"-"`
+	UsedAt   *time.Time `json:"used_at,omitempty"`
+}
+
+// PersonalAccessToken is a named, scoped API key for automation. Only its hash
+// is stored; TokenPrefix lets users tell their tokens apart.
+type PersonalAccessToken struct {
+	Base
+	UserID      uuid.UUID  `gorm:"type:uuid;not null;index" json:"user_id"`
+	User        User       `gorm:"foreignKey:UserID" json:"-"`
+	Name        string     `gorm:"size:255;not null" json:"name"`
+	TokenPrefix string     `gorm:"size:16;not null" json:"token_prefix"`
+	TokenHash   string     `gorm:"uniqueIndex;size:64;not null" json:"-"`
+	Scopes      []string   `gorm:"serializer:json;type:text" json:"scopes"`
+	LastUsedAt  *time.Time `json:"last_used_at,omitempty"`
+	ExpiresAt   *time.Time `json:"expires_at,omitempty"`
+	RevokedAt   *time.Time `json:"revoked_at,omitempty"`
+}
+
+// LinkedIdentity links an account at an external OpenID Connect provider to a
+// user. Subject is the provider's stable user identifier.
+type LinkedIdentity struct {
+	Base
+	UserID      uuid.UUID  `gorm:"type:uuid;not null;index" json:"user_id"`
+	User        User       `gorm:"foreignKey:UserID" json:"-"`
+	Provider    string     `gorm:"size:50;not null;uniqueIndex:idx_linked_identity_subject" json:"provider"`
+	Subject     string     `gorm:"size:255;not null;uniqueIndex:idx_linked_identity_subject" json:"subject"`
+	Email       string     `gorm:"size:255" json:"email"`
+	LastLoginAt *time.Time `json:"last_login_at,omitempty"`
+}
+
+// OAuthState is a pending OpenID Connect authorization request. Only the hash
+// of the state parameter is stored. UserID is set when an already logged in
+// user is linking a new identity.
+type OAuthState struct {
+	Base
+	StateHash    string     `gorm:"uniqueIndex;size:64;not null" json:"-"`
+	Provider     string     `gorm:"size:50;not null" json:"provider"`
+	CodeVerifier string     `gorm:"size:128;not null" json:"-"`
+	Nonce        string     `gorm:"size:64;not null" json:"-"`
+	UserID       *uuid.UUID `gorm:"type:uuid" json:"user_id,omitempty"`
+	ExpiresAt    time.Time  `gorm:"not null" json:"expires_at"`
+	// Hash of the value in the browser cookie the request was started with
+	BindingHash string `gorm:"size:64;not null;default:''" json:"-"`
+}
+
+// LoginAttempt records a password or two-factor login attempt. UserID is nil
+// when the username didn't match an account.
+type LoginAttempt struct {
+	Base
+	UserID    *uuid.UUID `gorm:"type:uuid;index" json:"user_id,omitempty"`
+	Username  string     `gorm:"size:255" json:"username"`
+	IPAddress string     `gorm:"size:64;index" json:"ip_address"`
+	Succeeded bool       `gorm:"default:false" json:"succeeded"`
+}
+
+// LoginThrottle tracks consecutive failed logins for an account ("user:<id>")
+// or a client address ("ip:<addr>") and how long it is locked out for
+type LoginThrottle struct {
+	Key           string     `gorm:"primaryKey;size:128" json:"key"`
+	Failures      int        `gorm:"not null;default:0" json:"failures"`
+	LastFailureAt time.Time  `json:"last_failure_at"`
+	LockedUntil   *time.Time `json:"locked_until,omitempty"`
 }
 
 // Request and response structures
@@ -81,8 +349,90 @@ type RegisterRequest struct {
 }
 
 type TokenResponse struct {
-	Token     string `json:"token"`
-	TokenType string `json:"token_type"`
+	Token        string `json:"token"`
+	TokenType    string `json:"token_type"`
+	ExpiresIn    int64  `json:"expires_in"`
+	RefreshToken string `json:"refresh_token"`
+}
+
+// TwoFactorChallengeResponse is returned by login instead of a token when the
+// account has two-factor authentication enabled
+type TwoFactorChallengeResponse struct {
+	TwoFactorRequired bool   `json:"two_factor_required"`
+	ChallengeToken    string `json:"challenge_token"`
+	ExpiresIn         int64  `json:"expires_in"`
+}
+
+type TwoFactorLoginRequest struct {
+	ChallengeToken string `json:"challenge_token" binding:"required"`
+	Code           string `json:"code" binding:"required"`
+}
+
+type TwoFactorCodeRequest struct {
+	Code string `json:"code" binding:"required"`
+}
+
+type TwoFactorEnrollResponse struct {
+	Secret     string `json:"secret"`
+	OTPAuthURI string `json:"otpauth_uri"`
+}
+
+type RecoveryCodesResponse struct {
+	RecoveryCodes []string `json:"recovery_codes"`
+}
+
+type PersonalAccessTokenRequest struct {
+	Name      string     `json:"name" binding:"required,max=255"`
+	Scopes    []string   `json:"scopes" binding:"required,min=1"`
+	ExpiresAt *time.Time `json:"expires_at"`
+}
+
+// PersonalAccessTokenResponse includes the plain token, which is only ever
+// returned when the token is created
+type PersonalAccessTokenResponse struct {
+	PersonalAccessToken
+	Token string `json:"token"`
+}
+
+type RoleRequest struct {
+	Name        string   `json:"name" binding:"required,max=50"`
+	Description string   `json:"description" binding:"max=255"`
+	Permissions []string `json:"permissions"`
+}
+
+type RoleUpdateRequest struct {
+	Description *string  `json:"description" binding:"omitempty,max=255"`
+	Permissions []string `json:"permissions"`
+}
+
+type RoleAssignmentRequest struct {
+	Role string `json:"role" binding:"required"`
+}
+
+// OAuthAuthorizationResponse points the client at the provider's login page
+type OAuthAuthorizationResponse struct {
+	AuthorizationURL string `json:"authorization_url"`
+}
+
+type RefreshTokenRequest struct {
+	RefreshToken string `json:"refresh_token" binding:"required"`
+}
+
+type ForgotPasswordRequest struct {
+	Email string `json:"email" binding:"required,email"`
+}
+
+type ResetPasswordRequest struct {
+	Token    string `json:"token" binding:"required"`
+	Password string `json:"password" binding:"required,min=6"`
+}
+
+type VerifyEmailRequest struct {
+	Token string `json:"token" binding:"required"`
+}
+
+type LogoutRequest struct {
+	RefreshToken string `json:"refresh_token"`
 }
 
 type PostRequest struct {
@@ -91,6 +441,23 @@ type PostRequest struct {
 	Slug        string      `json:"slug"`
 	Status      string      `json:"status"`
 	CategoryIDs []uuid.UUID `json:"category_ids"`
+	// PublishAt schedules the post; it implies the scheduled status
+	PublishAt *time.Time `json:"publish_at"`
+	// ContentFormat is markdown, html or plain; markdown by default
+	ContentFormat string `json:"content_format"`
+	// Tags are tag names, created if new. They replace the post's tags when
+	// given; an empty list removes them.
+	Tags []string `json:"tags" binding:"omitempty,max=20,dive,max=50"`
+	// Excerpt replaces the one taken from the content; an empty one goes back
+	// to it
+	Excerpt *string `json:"excerpt" binding:"omitempty,max=1000"`
+}
+
+type TransitionRequest struct {
+	Status string `json:"status" binding:"required"`
+	// PublishAt is required when scheduling
+	PublishAt *time.Time `json:"publish_at"`
+	Note      string     `json:"note" binding:"max=2000"`
 }
 
 type CommentRequest struct {
@@ -98,7 +465,78 @@ type CommentRequest struct {
 	ParentID *uuid.UUID `json:"parent_id"`
 }
 
+type TagRequest struct {
+	Name string `json:"name" binding:"required,max=50"`
+}
+
+type TagMergeRequest struct {
+	// Into is the slug of the tag to merge into
+	Into string `json:"into" binding:"required"`
+}
+
 type CategoryRequest struct {
 	Name string `json:"name" binding:"required"`
 	Slug string `json:"slug"`
+	// ParentID nests the category under another one, or makes it top level
+	// when null. New categories are top level without it and updates keep
+	// their parent.
+	ParentID NullableUUID `json:"parent_id" swaggertype:"string"`
+}
+
+// NullableUUID is a JSON UUID that tells a missing field from a null one.
+// Set is true when the field was given, with Value nil if it was null.
+type NullableUUID struct {
+	Set   bool
+	Value *uuid.UUID
+}
+
+func (n NullableUUID) MarshalJSON() ([]byte, error) {
+	return json.Marshal(n.Value)
+}
+
+func (n *NullableUUID) UnmarshalJSON(data []byte) error {
+	n.Set = true
+	return json.Unmarshal(data, &n.Value)
+}
+
+// SearchResult is a published post or a comment on one that matches a
+// search. Title and Slug are those of the post. Snippet is HTML-escaped text
+// with matches wrapped in <mark> tags.
+type SearchResult struct {
+	Type      string    `json:"type"`
+	ID        uuid.UUID `json:"id"`
+	PostID    uuid.UUID `json:"post_id"`
+	Title     string    `json:"title"`
+	Slug      string    `json:"slug"`
+	Snippet   string    `json:"snippet"`
+	AuthorID  uuid.UUID `json:"author_id"`
+	Author    string    `json:"author"`
+	Rank      float64   `json:"rank"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+type PostRevisionDiff struct {
+	PostID uuid.UUID `json:"post_id"`
+	From   int       `json:"from"`
+	To     int       `json:"to"`
+	// Diff is a unified diff of the title, categories and content
+	Diff string `json:"diff"`
+}
+
+// PaginatedResponse wraps one page of a listing
+type PaginatedResponse struct {
+	Data       interface{} `json:"data"`
+	Page       int         `json:"page"`
+	Limit      int         `json:"limit"`
+	Total      int64       `json:"total"`
+	TotalPages int         `json:"total_pages"`
+}
+
+// CursorResponse wraps one page of a feed. The cursors are null when there
+// is no next or previous page.
+type CursorResponse struct {
+	Data       interface{} `json:"data"`
+	Limit      int         `json:"limit"`
+	NextCursor *string     `json:"next_cursor"`
+	PrevCursor *string     `json:"prev_cursor"`
 }
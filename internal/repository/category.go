@@ -0,0 +1,152 @@
+package repository
+
+import (
+	"github.com/google/uuid"
+	"github.com/terkoizmy/go-blog-api/internal/models"
+	"gorm.io/gorm"
+)
+
+type gormCategoryRepository struct {
+	db *gorm.DB
+}
+
+// NewCategoryRepository returns a CategoryRepository backed by GORM
+func NewCategoryRepository(db *gorm.DB) CategoryRepository {
+	return &gormCategoryRepository{db: db}
+}
+
+func (r *gormCategoryRepository) FindByID(id uuid.UUID) (models.Category, error) {
+	var category models.Category
+	err := first(r.db.Where("id = ?", id), &category)
+	return category, err
+}
+
+func (r *gormCategoryRepository) FindBySlug(slug string) (models.Category, error) {
+	var category models.Category
+	err := first(r.db.Where("slug = ?", slug), &category)
+	return category, err
+}
+
+func (r *gormCategoryRepository) SlugExists(slug string, excludeID uuid.UUID) (bool, error) {
+	return exists(r.db.Where("slug = ? AND id != ?", slug, excludeID), &models.Category{})
+}
+
+func (r *gormCategoryRepository) List(page Page) ([]models.Category, int64, error) {
+	var categories []models.Category
+	total, err := paginate(r.db, &models.Category{}, page, "name", &categories)
+	return categories, total, err
+}
+
+func (r *gormCategoryRepository) Create(category *models.Category) error {
+	return r.db.Create(category).Error
+}
+
+func (r *gormCategoryRepository) Update(category *models.Category) error {
+	return r.db.Save(category).Error
+}
+
+func (r *gormCategoryRepository) Delete(category *models.Category) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(category).Association("Posts").Clear(); err != nil {
+			return err
+		}
+		// Subcategories move up to the deleted category's parent
+		err := tx.Model(&models.Category{}).Where("parent_id = ?", category.ID).
+			Update("parent_id", category.ParentID).Error
+		if err != nil {
+			return err
+		}
+		return tx.Delete(category).Error
+	})
+}
+
+func (r *gormCategoryRepository) Descendants(id uuid.UUID) ([]uuid.UUID, error) {
+	// UNION rather than UNION ALL stops at cycles
+	var ids []uuid.UUID
+	err := r.db.Raw(`WITH RECURSIVE descendants(id) AS (
+		SELECT id FROM categories WHERE parent_id = ? AND deleted_at IS NULL
+		UNION
+		SELECT categories.id FROM categories JOIN descendants ON categories.parent_id = descendants.id
+		WHERE categories.deleted_at IS NULL
+	) SELECT id FROM descendants`, id).Scan(&ids).Error
+	return ids, err
+}
+
+func (r *gormCategoryRepository) Tree() ([]models.CategoryNode, error) {
+	var categories []models.Category
+	if err := r.db.Order("name").Find(&categories).Error; err != nil {
+		return nil, err
+	}
+
+	known := make(map[uuid.UUID]bool, len(categories))
+	for _, category := range categories {
+		known[category.ID] = true
+	}
+	children := make(map[uuid.UUID][]models.Category)
+	var roots []models.Category
+	for _, category := range categories {
+		if category.ParentID == nil || !known[*category.ParentID] {
+			roots = append(roots, category)
+		} else {
+			children[*category.ParentID] = append(children[*category.ParentID], category)
+		}
+	}
+
+	var build func([]models.Category) []models.CategoryNode
+	build = func(categories []models.Category) []models.CategoryNode {
+		nodes := make([]models.CategoryNode, len(categories))
+		for i, category := range categories {
+			nodes[i] = models.CategoryNode{Category: category, Children: build(children[category.ID])}
+		}
+		return nodes
+	}
+	return build(roots), nil
+}
+
+// fillCategoryPaths sets the breadcrumb path of the categories of posts.
+// Categories are few, so all of them are loaded at once.
+func fillCategoryPaths(db *gorm.DB, posts []models.Post) error {
+	hasCategories := false
+	for _, post := range posts {
+		hasCategories = hasCategories || len(post.Categories) > 0
+	}
+	if !hasCategories {
+		return nil
+	}
+
+	var categories []models.Category
+	if err := db.Session(&gorm.Session{NewDB: true}).Find(&categories).Error; err != nil {
+		return err
+	}
+	byID := make(map[uuid.UUID]models.Category, len(categories))
+	for _, category := range categories {
+		byID[category.ID] = category
+	}
+
+	for i := range posts {
+		for j := range posts[i].Categories {
+			posts[i].Categories[j].Path = categoryPath(byID, posts[i].Categories[j])
+		}
+	}
+	return nil
+}
+
+// categoryPath walks up from category to the top level
+func categoryPath(byID map[uuid.UUID]models.Category, category models.Category) []models.CategoryLink {
+	path := []models.CategoryLink{{ID: category.ID, Name: category.Name, Slug: category.Slug}}
+	seen := map[uuid.UUID]bool{category.ID: true}
+	for category.ParentID != nil && !seen[*category.ParentID] {
+		parent, ok := byID[*category.ParentID]
+		if !ok {
+			break
+		}
+		seen[parent.ID] = true
+		path = append(path, models.CategoryLink{ID: parent.ID, Name: parent.Name, Slug: parent.Slug})
+		category = parent
+	}
+
+	for i, j := 0, len(path)-1; i < j; i, j = i+1, j-1 {
+		path[i], path[j] = path[j], path[i]
+	}
+	return path
+}
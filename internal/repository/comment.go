@@ -0,0 +1,54 @@
+package repository
+
+import (
+	"github.com/google/uuid"
+	"github.com/terkoizmy/go-blog-api/internal/models"
+	"gorm.io/gorm"
+)
+
+type gormCommentRepository struct {
+	db *gorm.DB
+}
+
+// NewCommentRepository returns a CommentRepository backed by GORM
+func NewCommentRepository(db *gorm.DB) CommentRepository {
+	return &gormCommentRepository{db: db}
+}
+
+func (r *gormCommentRepository) FindByID(id uuid.UUID) (models.Comment, error) {
+	var comment models.Comment
+	err := first(r.db.Preload("Author").Preload("Parent").Where("id = ?", id), &comment)
+	return comment, err
+}
+
+func (r *gormCommentRepository) FindByIDWithReplies(id uuid.UUID) (models.Comment, error) {
+	var comment models.Comment
+	err := first(r.db.Preload("Author").Preload("Parent").Preload("Replies").Where("id = ?", id), &comment)
+	return comment, err
+}
+
+func (r *gormCommentRepository) ListByPost(postID uuid.UUID, page Page) ([]models.Comment, int64, error) {
+	var comments []models.Comment
+	query := r.db.Preload("Author").Preload("Parent").Where("post_id = ?", postID)
+	total, err := paginate(query, &models.Comment{}, page, "created_at", &comments)
+	return comments, total, err
+}
+
+func (r *gormCommentRepository) FeedByPost(postID uuid.UUID, page CursorPage) ([]models.Comment, Cursors, error) {
+	query := r.db.Preload("Author").Preload("Parent").Where("post_id = ?", postID)
+	return keyset(query, "comments.created_at", "comments.id", false, page, func(comment models.Comment) Cursor {
+		return Cursor{Time: comment.CreatedAt, ID: comment.ID}
+	})
+}
+
+func (r *gormCommentRepository) Create(comment *models.Comment) error {
+	return r.db.Create(comment).Error
+}
+
+func (r *gormCommentRepository) Update(comment *models.Comment) error {
+	return r.db.Save(comment).Error
+}
+
+func (r *gormCommentRepository) Delete(comment *models.Comment) error {
+	return r.db.Delete(comment).Error
+}
@@ -0,0 +1,100 @@
+package repository
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// Cursor is a position in a feed ordered by a time and then an ID
+type Cursor struct {
+	Time time.Time
+	ID   uuid.UUID
+	// Before selects the records preceding the position instead of the ones
+	// following it
+	Before bool
+}
+
+// CursorPage selects the records next to a cursor. A nil Cursor starts at
+// the beginning of the feed.
+type CursorPage struct {
+	Cursor *Cursor
+	Limit  int
+}
+
+// Cursors point at the pages around a page of a feed. They are nil when
+// there is no such page.
+type Cursors struct {
+	Next *Cursor
+	Prev *Cursor
+}
+
+// keyset loads the page of query selected by page, ordering by timeColumn
+// and idColumn. Unlike offsets, the cursors stay valid when records are
+// added in front of them. key returns the position of a record.
+func keyset[T any](query *gorm.DB, timeColumn, idColumn string, descending bool, page CursorPage, key func(T) Cursor) ([]T, Cursors, error) {
+	query = query.Session(&gorm.Session{})
+
+	forward := page.Cursor == nil || !page.Cursor.Before
+	// Paging backwards walks the feed in reverse
+	ascending := descending != forward
+
+	if page.Cursor != nil {
+		op := "<"
+		if ascending {
+			op = ">"
+		}
+		query = query.Where(fmt.Sprintf("(%s, %s) %s (?, ?)", timeColumn, idColumn, op), page.Cursor.Time, page.Cursor.ID)
+	}
+
+	direction := "DESC"
+	if ascending {
+		direction = "ASC"
+	}
+	query = query.Order(fmt.Sprintf("%s %s, %s %s", timeColumn, direction, idColumn, direction))
+
+	// One extra record tells whether there is another page
+	if page.Limit > 0 {
+		query = query.Limit(page.Limit + 1)
+	}
+
+	var records []T
+	if err := query.Find(&records).Error; err != nil {
+		return nil, Cursors{}, err
+	}
+
+	more := page.Limit > 0 && len(records) > page.Limit
+	if more {
+		records = records[:page.Limit]
+	}
+	if !forward {
+		for i, j := 0, len(records)-1; i < j; i, j = i+1, j-1 {
+			records[i], records[j] = records[j], records[i]
+		}
+	}
+
+	var cursors Cursors
+	if len(records) == 0 {
+		return records, cursors, nil
+	}
+
+	first, last := key(records[0]), key(records[len(records)-1])
+	first.Before = true
+	if forward {
+		if more {
+			cursors.Next = &last
+		}
+		if page.Cursor != nil {
+			cursors.Prev = &first
+		}
+	} else {
+		if more {
+			cursors.Prev = &first
+		}
+		cursors.Next = &last
+	}
+
+	return records, cursors, nil
+}
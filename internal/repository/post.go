@@ -0,0 +1,330 @@
+package repository
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/terkoizmy/go-blog-api/internal/models"
+	"github.com/terkoizmy/go-blog-api/internal/workflow"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type gormPostRepository struct {
+	db *gorm.DB
+}
+
+// NewPostRepository returns a PostRepository backed by GORM
+func NewPostRepository(db *gorm.DB) PostRepository {
+	return &gormPostRepository{db: db}
+}
+
+func (r *gormPostRepository) FindByID(id uuid.UUID) (models.Post, error) {
+	var post models.Post
+	err := first(r.db.Where("id = ?", id), &post)
+	return post, err
+}
+
+func (r *gormPostRepository) FindByIDWithAuthor(id uuid.UUID) (models.Post, error) {
+	var post models.Post
+	err := first(r.db.Preload("Author").Preload("Categories").Preload("Tags").Where("id = ?", id), &post)
+	return post, r.withPath(post, err)
+}
+
+func (r *gormPostRepository) FindByIDWithRelations(id uuid.UUID) (models.Post, error) {
+	var post models.Post
+	err := first(r.withRelations().Where("id = ?", id), &post)
+	return post, r.withPath(post, err)
+}
+
+func (r *gormPostRepository) FindBySlugWithRelations(slug string) (models.Post, error) {
+	var post models.Post
+	err := first(r.withRelations().Where("slug = ?", slug), &post)
+	return post, r.withPath(post, err)
+}
+
+func (r *gormPostRepository) SlugExists(slug string, excludeID uuid.UUID) (bool, error) {
+	return exists(r.db.Where("slug = ? AND id != ?", slug, excludeID), &models.Post{})
+}
+
+func (r *gormPostRepository) List(filter PostFilter) ([]models.Post, int64, error) {
+	var posts []models.Post
+	total, err := listPosts(r.db.Preload("Author").Preload("Categories").Preload("Tags"), filter, &posts)
+	return posts, total, err
+}
+
+func (r *gormPostRepository) ListWithRelations(filter PostFilter) ([]models.Post, int64, error) {
+	var posts []models.Post
+	total, err := listPosts(r.withRelations(), filter, &posts)
+	return posts, total, err
+}
+
+func (r *gormPostRepository) ListByCategory(categoryID uuid.UUID, filter PostFilter) ([]models.Post, int64, error) {
+	query := r.db.Joins("JOIN post_categories ON posts.id = post_categories.post_id").
+		Where("post_categories.category_id = ?", categoryID).
+		Preload("Author").Preload("Categories").Preload("Tags")
+
+	var posts []models.Post
+	total, err := listPosts(query, filter, &posts)
+	return posts, total, err
+}
+
+func (r *gormPostRepository) ListByTag(tagID uuid.UUID, filter PostFilter) ([]models.Post, int64, error) {
+	query := r.db.Joins("JOIN post_tags ON posts.id = post_tags.post_id").
+		Where("post_tags.tag_id = ?", tagID).
+		Preload("Author").Preload("Categories").Preload("Tags")
+
+	var posts []models.Post
+	total, err := listPosts(query, filter, &posts)
+	return posts, total, err
+}
+
+func (r *gormPostRepository) Feed(filter PostFilter, page CursorPage) ([]models.Post, Cursors, error) {
+	return postFeed(r.db.Preload("Author").Preload("Categories").Preload("Tags"), filter, page)
+}
+
+func (r *gormPostRepository) FeedByCategory(categoryID uuid.UUID, filter PostFilter, page CursorPage) ([]models.Post, Cursors, error) {
+	query := r.db.Joins("JOIN post_categories ON posts.id = post_categories.post_id").
+		Where("post_categories.category_id = ?", categoryID).
+		Preload("Author").Preload("Categories").Preload("Tags")
+
+	return postFeed(query, filter, page)
+}
+
+func (r *gormPostRepository) FeedByTag(tagID uuid.UUID, filter PostFilter, page CursorPage) ([]models.Post, Cursors, error) {
+	query := r.db.Joins("JOIN post_tags ON posts.id = post_tags.post_id").
+		Where("post_tags.tag_id = ?", tagID).
+		Preload("Author").Preload("Categories").Preload("Tags")
+
+	return postFeed(query, filter, page)
+}
+
+func (r *gormPostRepository) ListScheduled(authorID uuid.UUID, page Page) ([]models.Post, int64, error) {
+	var posts []models.Post
+	query := filterPosts(r.db.Preload("Author").Preload("Categories").Preload("Tags"), PostFilter{Status: workflow.Scheduled, AuthorID: authorID})
+	total, err := paginate(query, &models.Post{}, page, "posts.publish_at, posts.id", &posts)
+	if err != nil {
+		return nil, 0, err
+	}
+	return posts, total, fillCategoryPaths(r.db, posts)
+}
+
+// Both scheduling updates are a single conditional statement, so a post
+// changes state at most once however many of them race
+func (r *gormPostRepository) PublishDue(now time.Time) (int64, error) {
+	var published []models.Post
+	err := r.db.Transaction(func(tx *gorm.DB) error {
+		err := tx.Model(&published).
+			Clauses(clause.Returning{Columns: []clause.Column{{Name: "id"}}}).
+			Where("status = ? AND publish_at <= ?", workflow.Scheduled, now).
+			Updates(map[string]interface{}{
+				"status":       workflow.Published,
+				"published_at": gorm.Expr("publish_at"),
+				"publish_at":   nil,
+				"updated_at":   now,
+			}).Error
+		if err != nil || len(published) == 0 {
+			return err
+		}
+
+		transitions := make([]models.PostTransition, len(published))
+		for i, post := range published {
+			transitions[i] = models.PostTransition{PostID: post.ID, FromStatus: workflow.Scheduled, ToStatus: workflow.Published}
+		}
+		return tx.Create(&transitions).Error
+	})
+	if err != nil {
+		return 0, err
+	}
+	return int64(len(published)), nil
+}
+
+func (r *gormPostRepository) CancelSchedule(id uuid.UUID, userID uuid.UUID) (bool, error) {
+	cancelled := false
+	err := r.db.Transaction(func(tx *gorm.DB) error {
+		result := tx.Model(&models.Post{}).
+			Where("id = ? AND status = ?", id, workflow.Scheduled).
+			Updates(map[string]interface{}{
+				"status":     workflow.Draft,
+				"publish_at": nil,
+				"updated_at": time.Now(),
+			})
+		if result.Error != nil || result.RowsAffected == 0 {
+			return result.Error
+		}
+
+		cancelled = true
+		return tx.Create(&models.PostTransition{PostID: id, FromStatus: workflow.Scheduled, ToStatus: workflow.Draft, UserID: &userID}).Error
+	})
+	return cancelled, err
+}
+
+func (r *gormPostRepository) Create(post *models.Post) error {
+	if len(post.Tags) == 0 {
+		return r.db.Create(post).Error
+	}
+
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		tags, err := findOrCreateTags(tx, post.Tags)
+		if err != nil {
+			return err
+		}
+		post.Tags = tags
+		return tx.Create(post).Error
+	})
+}
+
+func (r *gormPostRepository) Update(post *models.Post) error {
+	return r.db.Save(post).Error
+}
+
+func (r *gormPostRepository) UpdateIfStatus(post *models.Post, status string) (bool, error) {
+	return updateIfStatus(r.db, post, status)
+}
+
+func (r *gormPostRepository) UpdateWithTagsIfStatus(post *models.Post, status string, tags []models.Tag) (bool, error) {
+	updated := false
+	err := r.db.Transaction(func(tx *gorm.DB) error {
+		var err error
+		if updated, err = updateIfStatus(tx, post, status); err != nil || !updated {
+			return err
+		}
+
+		if len(tags) > 0 {
+			if tags, err = findOrCreateTags(tx, tags); err != nil {
+				return err
+			}
+		}
+		return tx.Model(post).Association("Tags").Replace(tags)
+	})
+	if err != nil {
+		return false, err
+	}
+	return updated, nil
+}
+
+func updateIfStatus(tx *gorm.DB, post *models.Post, status string) (bool, error) {
+	result := tx.Model(post).
+		Select("*").
+		Omit(clause.Associations).
+		Where("status = ?", status).
+		Updates(post)
+	return result.RowsAffected > 0, result.Error
+}
+
+func (r *gormPostRepository) Delete(post *models.Post) error {
+	return r.db.Delete(post).Error
+}
+
+func (r *gormPostRepository) SetCategories(post *models.Post, categoryIDs []uuid.UUID) error {
+	var categories []models.Category
+	if len(categoryIDs) > 0 {
+		if err := r.db.Where("id IN ?", categoryIDs).Find(&categories).Error; err != nil {
+			return err
+		}
+	}
+
+	return r.db.Model(post).Association("Categories").Replace(categories)
+}
+
+func (r *gormPostRepository) SetTags(post *models.Post, tags []models.Tag) error {
+	return r.db.Model(post).Association("Tags").Replace(tags)
+}
+
+// withPath fills in the category paths of a post just loaded, unless loading
+// it failed. The post is copied but its categories are shared, so they get
+// the paths.
+func (r *gormPostRepository) withPath(post models.Post, err error) error {
+	if err != nil {
+		return err
+	}
+	return fillCategoryPaths(r.db, []models.Post{post})
+}
+
+func (r *gormPostRepository) withRelations() *gorm.DB {
+	return r.db.Preload("Author").Preload("Categories").Preload("Tags").Preload("Comments.Author")
+}
+
+// postPublishedAt is the publication date of a post. Unpublished posts don't
+// have one and use their creation date instead.
+const postPublishedAt = "COALESCE(posts.published_at, posts.created_at)"
+
+// postSortColumns whitelists the expressions posts can be sorted by
+var postSortColumns = map[string]string{
+	PostSortPublishedAt: postPublishedAt,
+	PostSortCreatedAt:   "posts.created_at",
+	PostSortTitle:       "posts.title",
+	PostSortComments:    "(SELECT COUNT(*) FROM comments WHERE comments.post_id = posts.id AND comments.deleted_at IS NULL)",
+}
+
+// listPosts loads the page of posts selected by filter in the filter's order
+func listPosts(query *gorm.DB, filter PostFilter, posts *[]models.Post) (int64, error) {
+	sort := filter.Sort
+	if sort == "" {
+		sort = PostSortPublishedAt
+	}
+	column, ok := postSortColumns[sort]
+	if !ok {
+		return 0, ErrInvalidSort
+	}
+
+	direction := "DESC"
+	if filter.Ascending {
+		direction = "ASC"
+	}
+	// The ID breaks ties so pages don't overlap
+	order := fmt.Sprintf("%s %s, posts.id %s", column, direction, direction)
+
+	total, err := paginate(filterPosts(query, filter), &models.Post{}, Page{Offset: filter.Offset, Limit: filter.Limit}, order, posts)
+	if err != nil {
+		return 0, err
+	}
+	return total, fillCategoryPaths(query, *posts)
+}
+
+// postFeed loads the posts selected by filter next to the page's cursor,
+// newest first. The offset and limit of filter are ignored.
+func postFeed(query *gorm.DB, filter PostFilter, page CursorPage) ([]models.Post, Cursors, error) {
+	posts, cursors, err := keyset(filterPosts(query, filter), postPublishedAt, "posts.id", true, page, func(post models.Post) Cursor {
+		if post.PublishedAt != nil {
+			return Cursor{Time: *post.PublishedAt, ID: post.ID}
+		}
+		return Cursor{Time: post.CreatedAt, ID: post.ID}
+	})
+	if err != nil {
+		return nil, Cursors{}, err
+	}
+	return posts, cursors, fillCategoryPaths(query, posts)
+}
+
+func filterPosts(query *gorm.DB, filter PostFilter) *gorm.DB {
+	if filter.Status != "" {
+		query = query.Where("posts.status = ?", filter.Status)
+	}
+	if filter.AuthorID != uuid.Nil {
+		query = query.Where("posts.author_id = ?", filter.AuthorID)
+	}
+	if len(filter.CategoryIDs) > 0 {
+		if filter.AllCategories {
+			query = query.Where("(SELECT COUNT(DISTINCT pc.category_id) FROM post_categories pc WHERE pc.post_id = posts.id AND pc.category_id IN ?) = ?",
+				filter.CategoryIDs, len(filter.CategoryIDs))
+		} else {
+			query = query.Where("EXISTS (SELECT 1 FROM post_categories pc WHERE pc.post_id = posts.id AND pc.category_id IN ?)", filter.CategoryIDs)
+		}
+	}
+	if filter.From != nil {
+		query = query.Where(postPublishedAt+" >= ?", *filter.From)
+	}
+	if filter.To != nil {
+		query = query.Where(postPublishedAt+" < ?", *filter.To)
+	}
+	if filter.HasComments != nil {
+		hasComments := "EXISTS (SELECT 1 FROM comments WHERE comments.post_id = posts.id AND comments.deleted_at IS NULL)"
+		if !*filter.HasComments {
+			hasComments = "NOT " + hasComments
+		}
+		query = query.Where(hasComments)
+	}
+	return query
+}
@@ -0,0 +1,112 @@
+package repository
+
+import (
+	"time"
+
+	"github.com/terkoizmy/go-blog-api/internal/models"
+	"gorm.io/gorm"
+)
+
+// PurgeCounts reports how many records PurgeDeleted removed
+type PurgeCounts struct {
+	Comments   int64
+	Posts      int64
+	Categories int64
+	Users      int64
+}
+
+// userCredentials are removed together with a purged user
+var userCredentials = []interface{}{
+	&models.RefreshToken{},
+	&models.PasswordResetToken{},
+	&models.EmailVerificationToken{},
+	&models.RecoveryCode{},
+	&models.PersonalAccessToken{},
+	&models.LinkedIdentity{},
+	&models.LoginAttempt{},
+}
+
+// PurgeDeleted permanently removes users, posts, comments and categories
+// that were soft deleted before the cutoff. Comments, tag links, revisions
+// and status history of purged posts go with them. Records still referenced
+// by live ones, such as a deleted comment with live replies or a deleted user
+// who still has posts, are kept.
+func PurgeDeleted(db *gorm.DB, before time.Time) (PurgeCounts, error) {
+	var counts PurgeCounts
+	err := db.Transaction(func(tx *gorm.DB) error {
+		tx = tx.Unscoped().Session(&gorm.Session{})
+
+		deletedPosts := func() *gorm.DB {
+			return tx.Model(&models.Post{}).Select("id").Where("deleted_at < ?", before)
+		}
+
+		// Remove reply chains leaf first, until only comments with live
+		// replies are left
+		for {
+			result := tx.
+				Where("(deleted_at < ? OR post_id IN (?))", before, deletedPosts()).
+				Where("NOT EXISTS (SELECT 1 FROM comments replies WHERE replies.parent_id = comments.id)").
+				Delete(&models.Comment{})
+			if result.Error != nil {
+				return result.Error
+			}
+			if result.RowsAffected == 0 {
+				break
+			}
+			counts.Comments += result.RowsAffected
+		}
+
+		purgeablePosts := func() *gorm.DB {
+			return deletedPosts().Where("NOT EXISTS (SELECT 1 FROM comments WHERE comments.post_id = posts.id)")
+		}
+		if err := tx.Table("post_categories").Where("post_id IN (?)", purgeablePosts()).Delete(nil).Error; err != nil {
+			return err
+		}
+		if err := tx.Table("post_tags").Where("post_id IN (?)", purgeablePosts()).Delete(nil).Error; err != nil {
+			return err
+		}
+		if err := tx.Where("post_id IN (?)", purgeablePosts()).Delete(&models.PostRevision{}).Error; err != nil {
+			return err
+		}
+		if err := tx.Where("post_id IN (?)", purgeablePosts()).Delete(&models.PostTransition{}).Error; err != nil {
+			return err
+		}
+		result := tx.Where("id IN (?)", purgeablePosts()).Delete(&models.Post{})
+		if result.Error != nil {
+			return result.Error
+		}
+		counts.Posts = result.RowsAffected
+
+		deletedCategories := tx.Model(&models.Category{}).Select("id").Where("deleted_at < ?", before)
+		if err := tx.Table("post_categories").Where("category_id IN (?)", deletedCategories).Delete(nil).Error; err != nil {
+			return err
+		}
+		result = tx.Where("deleted_at < ?", before).Delete(&models.Category{})
+		if result.Error != nil {
+			return result.Error
+		}
+		counts.Categories = result.RowsAffected
+
+		purgeableUsers := func() *gorm.DB {
+			return tx.Model(&models.User{}).Select("id").
+				Where("deleted_at < ?", before).
+				Where("NOT EXISTS (SELECT 1 FROM posts WHERE posts.author_id = users.id)").
+				Where("NOT EXISTS (SELECT 1 FROM comments WHERE comments.author_id = users.id)").
+				Where("NOT EXISTS (SELECT 1 FROM post_revisions WHERE post_revisions.author_id = users.id)").
+				Where("NOT EXISTS (SELECT 1 FROM post_transitions WHERE post_transitions.user_id = users.id)")
+		}
+		for _, model := range userCredentials {
+			if err := tx.Where("user_id IN (?)", purgeableUsers()).Delete(model).Error; err != nil {
+				return err
+			}
+		}
+		result = tx.Where("id IN (?)", purgeableUsers()).Delete(&models.User{})
+		if result.Error != nil {
+			return result.Error
+		}
+		counts.Users = result.RowsAffected
+
+		return nil
+	})
+	return counts, err
+}
@@ -0,0 +1,58 @@
+package repository
+
+import (
+	"github.com/terkoizmy/go-blog-api/internal/models"
+	"gorm.io/gorm"
+)
+
+// renderBatchSize is how many records RenderContent loads at a time
+const renderBatchSize = 100
+
+// RenderCounts reports how many records RenderContent rendered
+type RenderCounts struct {
+	Posts    int64
+	Comments int64
+}
+
+// RenderContent fills in the HTML cache of posts and comments that don't
+// have one, such as those saved before it existed or whose cache a migration
+// cleared. They are rendered as they are loaded, so this only stores the
+// result instead of leaving every read to render them again.
+func RenderContent(db *gorm.DB) (RenderCounts, error) {
+	var counts RenderCounts
+	db = db.Unscoped().Session(&gorm.Session{})
+
+	var posts []models.Post
+	err := db.Where("(content_html IS NULL OR content_html = '') AND content <> ''").
+		FindInBatches(&posts, renderBatchSize, func(tx *gorm.DB, batch int) error {
+			for _, post := range posts {
+				err := db.Model(&post).UpdateColumns(map[string]interface{}{
+					"content_html": post.ContentHTML,
+					"excerpt":      post.Excerpt,
+					"word_count":   post.WordCount,
+					"reading_time": post.ReadingTime,
+				}).Error
+				if err != nil {
+					return err
+				}
+			}
+			counts.Posts += int64(len(posts))
+			return nil
+		}).Error
+	if err != nil {
+		return counts, err
+	}
+
+	var comments []models.Comment
+	err = db.Where("(content_html IS NULL OR content_html = '') AND content <> ''").
+		FindInBatches(&comments, renderBatchSize, func(tx *gorm.DB, batch int) error {
+			for _, comment := range comments {
+				if err := db.Model(&comment).UpdateColumn("content_html", comment.ContentHTML).Error; err != nil {
+					return err
+				}
+			}
+			counts.Comments += int64(len(comments))
+			return nil
+		}).Error
+	return counts, err
+}
@@ -0,0 +1,249 @@
+package repository
+
+import (
+	"errors"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/terkoizmy/go-blog-api/internal/models"
+	"gorm.io/gorm"
+)
+
+// ErrNotFound is returned when a lookup matches no record
+var ErrNotFound = errors.New("record not found")
+
+// ErrInvalidSort is returned when a listing is sorted by an unknown key
+var ErrInvalidSort = errors.New("invalid sort key")
+
+// Page selects part of a listing. A zero Limit selects every record.
+type Page struct {
+	Offset int
+	Limit  int
+}
+
+type UserRepository interface {
+	FindByID(id uuid.UUID) (models.User, error)
+	FindByUsername(username string) (models.User, error)
+	FindByEmail(email string) (models.User, error)
+	// UsernameExists and EmailExists ignore the user with excludeID, so pass
+	// uuid.Nil to check against every user
+	UsernameExists(username string, excludeID uuid.UUID) (bool, error)
+	EmailExists(email string, excludeID uuid.UUID) (bool, error)
+	// List returns a page of users, oldest first, and the total number of users
+	List(page Page) ([]models.User, int64, error)
+	Create(user *models.User) error
+	Update(user *models.User) error
+	Delete(user *models.User) error
+}
+
+// PostFilter narrows down post listings. Zero values mean no restriction.
+type PostFilter struct {
+	Status      string
+	AuthorID    uuid.UUID
+	CategoryIDs []uuid.UUID
+	// AllCategories requires every category of CategoryIDs instead of any
+	AllCategories bool
+	// From is inclusive and To exclusive. Both apply to the publication
+	// date, or the creation date of unpublished posts.
+	From *time.Time
+	To   *time.Time
+	// HasComments keeps only posts with comments when true and only posts
+	// without when false
+	HasComments *bool
+	// Sort is one of the PostSort keys, PostSortPublishedAt by default
+	Sort      string
+	Ascending bool
+	Offset    int
+	Limit     int
+}
+
+// Keys posts can be sorted by
+const (
+	PostSortPublishedAt = "published_at"
+	PostSortCreatedAt   = "created_at"
+	PostSortTitle       = "title"
+	PostSortComments    = "comments"
+)
+
+type PostRepository interface {
+	FindByID(id uuid.UUID) (models.Post, error)
+	// FindByIDWithAuthor also loads the author, categories and tags
+	FindByIDWithAuthor(id uuid.UUID) (models.Post, error)
+	// FindByIDWithRelations also loads the author, categories, tags and
+	// comments
+	FindByIDWithRelations(id uuid.UUID) (models.Post, error)
+	FindBySlugWithRelations(slug string) (models.Post, error)
+	SlugExists(slug string, excludeID uuid.UUID) (bool, error)
+	// List returns posts with their author, categories and tags in the
+	// filter's order and the total number of posts matching the filter
+	List(filter PostFilter) ([]models.Post, int64, error)
+	// ListWithRelations also loads the comments of each post
+	ListWithRelations(filter PostFilter) ([]models.Post, int64, error)
+	ListByCategory(categoryID uuid.UUID, filter PostFilter) ([]models.Post, int64, error)
+	ListByTag(tagID uuid.UUID, filter PostFilter) ([]models.Post, int64, error)
+	// Feed returns the posts next to a cursor, ordered by publication date
+	// and ID, newest first, and the cursors of the pages around them. The
+	// filter's sort, offset and limit are ignored.
+	Feed(filter PostFilter, page CursorPage) ([]models.Post, Cursors, error)
+	FeedByCategory(categoryID uuid.UUID, filter PostFilter, page CursorPage) ([]models.Post, Cursors, error)
+	FeedByTag(tagID uuid.UUID, filter PostFilter, page CursorPage) ([]models.Post, Cursors, error)
+	// ListScheduled returns a page of scheduled posts, due first, and the
+	// total number of them. A nil authorID lists every author's.
+	ListScheduled(authorID uuid.UUID, page Page) ([]models.Post, int64, error)
+	// PublishDue publishes the scheduled posts due by now and returns how
+	// many it published. Each post is published once even when several
+	// servers run it at the same time.
+	PublishDue(now time.Time) (int64, error)
+	// CancelSchedule turns a scheduled post back into a draft on behalf of
+	// the user. It returns false if the post wasn't scheduled, such as when
+	// it was just published.
+	CancelSchedule(id uuid.UUID, userID uuid.UUID) (bool, error)
+	// Create saves the post with its tags, creating those that don't exist
+	// yet, all or nothing
+	Create(post *models.Post) error
+	Update(post *models.Post) error
+	// UpdateIfStatus saves the post only if its status is still status. It
+	// returns false if the status was changed in the meantime, such as by
+	// the scheduler publishing it.
+	UpdateIfStatus(post *models.Post, status string) (bool, error)
+	// UpdateWithTagsIfStatus is UpdateIfStatus that also replaces the post's
+	// tags, creating those that don't exist yet. Nothing is saved unless all
+	// of it is.
+	UpdateWithTagsIfStatus(post *models.Post, status string, tags []models.Tag) (bool, error)
+	Delete(post *models.Post) error
+	// SetCategories replaces the post's categories, skipping unknown IDs
+	SetCategories(post *models.Post, categoryIDs []uuid.UUID) error
+	// SetTags replaces the post's tags
+	SetTags(post *models.Post, tags []models.Tag) error
+}
+
+type PostRevisionRepository interface {
+	// Create records the revision with the next number of its post
+	Create(revision *models.PostRevision) error
+	// Latest returns the post's newest revision
+	Latest(postID uuid.UUID) (models.PostRevision, error)
+	FindByNumber(postID uuid.UUID, number int) (models.PostRevision, error)
+	// ListByPost returns a page of the post's revisions, newest first, and
+	// the total number of revisions of the post
+	ListByPost(postID uuid.UUID, page Page) ([]models.PostRevision, int64, error)
+}
+
+type PostTransitionRepository interface {
+	Create(transition *models.PostTransition) error
+	// ListByPost returns a page of the post's status changes, newest first,
+	// and the total number of them
+	ListByPost(postID uuid.UUID, page Page) ([]models.PostTransition, int64, error)
+}
+
+type CommentRepository interface {
+	// FindByID loads the comment with its author and parent
+	FindByID(id uuid.UUID) (models.Comment, error)
+	// FindByIDWithReplies also loads the replies
+	FindByIDWithReplies(id uuid.UUID) (models.Comment, error)
+	// ListByPost returns a page of the post's comments, oldest first, and
+	// the total number of comments on the post
+	ListByPost(postID uuid.UUID, page Page) ([]models.Comment, int64, error)
+	// FeedByPost returns the post's comments next to a cursor, ordered by
+	// creation date and ID, oldest first
+	FeedByPost(postID uuid.UUID, page CursorPage) ([]models.Comment, Cursors, error)
+	Create(comment *models.Comment) error
+	Update(comment *models.Comment) error
+	Delete(comment *models.Comment) error
+}
+
+type CategoryRepository interface {
+	FindByID(id uuid.UUID) (models.Category, error)
+	FindBySlug(slug string) (models.Category, error)
+	SlugExists(slug string, excludeID uuid.UUID) (bool, error)
+	// List returns a page of categories ordered by name and the total number
+	// of categories
+	List(page Page) ([]models.Category, int64, error)
+	Create(category *models.Category) error
+	Update(category *models.Category) error
+	// Delete removes the category from its posts, moves its subcategories
+	// up to its parent and deletes it
+	Delete(category *models.Category) error
+	// Descendants returns the IDs of the category's subcategories, theirs
+	// and so on
+	Descendants(id uuid.UUID) ([]uuid.UUID, error)
+	// Tree returns the top-level categories with their subcategories nested,
+	// each level ordered by name
+	Tree() ([]models.CategoryNode, error)
+}
+
+type TagRepository interface {
+	FindBySlug(slug string) (models.Tag, error)
+	SlugExists(slug string, excludeID uuid.UUID) (bool, error)
+	// List returns a page of tags with their number of published posts,
+	// most used first, and the total number of tags
+	List(page Page) ([]models.TagUsage, int64, error)
+	// FindOrCreate returns the tags with the slugs of tags, creating those
+	// that don't exist yet
+	FindOrCreate(tags []models.Tag) ([]models.Tag, error)
+	Update(tag *models.Tag) error
+	// Merge moves the posts of from to into and deletes from
+	Merge(from, into *models.Tag) error
+}
+
+// Repositories bundles the repositories handlers depend on
+type Repositories struct {
+	Users       UserRepository
+	Posts       PostRepository
+	Revisions   PostRevisionRepository
+	Transitions PostTransitionRepository
+	Comments    CommentRepository
+	Categories  CategoryRepository
+	Tags        TagRepository
+	Search      SearchRepository
+}
+
+// NewGormRepositories returns GORM-backed repositories using db
+func NewGormRepositories(db *gorm.DB) *Repositories {
+	return &Repositories{
+		Users:       NewUserRepository(db),
+		Posts:       NewPostRepository(db),
+		Revisions:   NewPostRevisionRepository(db),
+		Transitions: NewPostTransitionRepository(db),
+		Comments:    NewCommentRepository(db),
+		Categories:  NewCategoryRepository(db),
+		Tags:        NewTagRepository(db),
+		Search:      NewSearchRepository(db),
+	}
+}
+
+// first runs a single-record query, mapping a missing record to ErrNotFound
+func first(query *gorm.DB, dest interface{}) error {
+	err := query.First(dest).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return ErrNotFound
+	}
+	return err
+}
+
+// paginate counts the records matched by query and loads the selected page of
+// them into dest in the given order
+func paginate(query *gorm.DB, model interface{}, page Page, order string, dest interface{}) (int64, error) {
+	query = query.Session(&gorm.Session{})
+
+	var total int64
+	if err := query.Model(model).Count(&total).Error; err != nil {
+		return 0, err
+	}
+
+	if page.Offset > 0 {
+		query = query.Offset(page.Offset)
+	}
+	if page.Limit > 0 {
+		query = query.Limit(page.Limit)
+	}
+
+	return total, query.Order(order).Find(dest).Error
+}
+
+func exists(query *gorm.DB, model interface{}) (bool, error) {
+	var count int64
+	if err := query.Model(model).Count(&count).Error; err != nil {
+		return false, err
+	}
+	return count > 0, nil
+}
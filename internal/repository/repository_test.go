@@ -0,0 +1,347 @@
+package repository_test
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/terkoizmy/go-blog-api/internal/dbtest"
+	"github.com/terkoizmy/go-blog-api/internal/models"
+	"github.com/terkoizmy/go-blog-api/internal/repository"
+)
+
+func newRepos(t *testing.T) *repository.Repositories {
+	t.Helper()
+	return repository.NewGormRepositories(dbtest.Open(t))
+}
+
+func createUser(t *testing.T, repos *repository.Repositories, username string) models.User {
+	t.Helper()
+	user := models.User{Username: username, Email: username + "@example.com", Password: "x", Role: "user"}
+	if err := repos.Users.Create(&user); err != nil {
+		t.Fatalf("create user: %v", err)
+	}
+	return user
+}
+
+func createPost(t *testing.T, repos *repository.Repositories, author models.User, title string, publishedAt *time.Time) models.Post {
+	t.Helper()
+	post := models.Post{
+		Title:       title,
+		Content:     title + " content",
+		Slug:        fmt.Sprintf("post-%s", uuid.NewString()[:8]),
+		AuthorID:    author.ID,
+		Status:      "draft",
+		PublishedAt: publishedAt,
+	}
+	if publishedAt != nil {
+		post.Status = "published"
+	}
+	if err := repos.Posts.Create(&post); err != nil {
+		t.Fatalf("create post: %v", err)
+	}
+	return post
+}
+
+func TestUsers(t *testing.T) {
+	repos := newRepos(t)
+	alice := createUser(t, repos, "alice")
+	createUser(t, repos, "bob")
+
+	found, err := repos.Users.FindByUsername("alice")
+	if err != nil || found.ID != alice.ID {
+		t.Fatalf("find by username: %+v, %v", found, err)
+	}
+	if _, err := repos.Users.FindByEmail("nobody@example.com"); !errors.Is(err, repository.ErrNotFound) {
+		t.Errorf("missing user: got %v, want ErrNotFound", err)
+	}
+
+	if exists, _ := repos.Users.EmailExists("alice@example.com", uuid.Nil); !exists {
+		t.Error("alice's email doesn't exist")
+	}
+	if exists, _ := repos.Users.EmailExists("alice@example.com", alice.ID); exists {
+		t.Error("alice's email exists when excluding alice")
+	}
+
+	users, total, err := repos.Users.List(repository.Page{Limit: 1})
+	if err != nil || total != 2 || len(users) != 1 {
+		t.Fatalf("list: %d of %d, %v", len(users), total, err)
+	}
+
+	if err := repos.Users.Delete(&alice); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := repos.Users.FindByID(alice.ID); !errors.Is(err, repository.ErrNotFound) {
+		t.Errorf("deleted user: got %v, want ErrNotFound", err)
+	}
+}
+
+func TestPostListsAndFeed(t *testing.T) {
+	repos := newRepos(t)
+	author := createUser(t, repos, "author")
+
+	base := time.Date(2026, 1, 1, 12, 0, 0, 0, time.UTC)
+	var published []models.Post
+	for i := 0; i < 5; i++ {
+		at := base.Add(time.Duration(i) * time.Hour)
+		published = append(published, createPost(t, repos, author, fmt.Sprintf("Post %d", i), &at))
+	}
+	createPost(t, repos, author, "Draft", nil)
+
+	posts, total, err := repos.Posts.List(repository.PostFilter{Status: "published", Limit: 2})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if total != 5 || len(posts) != 2 || posts[0].ID != published[4].ID {
+		t.Fatalf("list: %d of %d, first %s", len(posts), total, posts[0].Title)
+	}
+	if posts[0].Author.ID != author.ID {
+		t.Error("list didn't load the author")
+	}
+
+	// Walk the feed forwards with cursors, then back
+	var seen []uuid.UUID
+	page := repository.CursorPage{Limit: 2}
+	for {
+		posts, cursors, err := repos.Posts.Feed(repository.PostFilter{Status: "published"}, page)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, post := range posts {
+			seen = append(seen, post.ID)
+		}
+		if cursors.Next == nil {
+			break
+		}
+		page.Cursor = cursors.Next
+	}
+	if len(seen) != 5 {
+		t.Fatalf("feed returned %d posts, want 5", len(seen))
+	}
+	for i, id := range seen {
+		if id != published[4-i].ID {
+			t.Fatalf("feed position %d is %s, want %s", i, id, published[4-i].ID)
+		}
+	}
+}
+
+func TestPublishDue(t *testing.T) {
+	repos := newRepos(t)
+	author := createUser(t, repos, "author")
+
+	now := time.Now()
+	due := now.Add(-time.Minute)
+	later := now.Add(time.Hour)
+	for _, at := range []time.Time{due, later} {
+		post := createPost(t, repos, author, "Scheduled", nil)
+		post.Status = "scheduled"
+		post.PublishAt = &at
+		if err := repos.Posts.Update(&post); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	published, err := repos.Posts.PublishDue(now)
+	if err != nil || published != 1 {
+		t.Fatalf("published %d, %v; want 1", published, err)
+	}
+	if published, _ := repos.Posts.PublishDue(now); published != 0 {
+		t.Errorf("published %d again", published)
+	}
+
+	scheduled, total, err := repos.Posts.ListScheduled(uuid.Nil, repository.Page{})
+	if err != nil || total != 1 || !scheduled[0].PublishAt.Equal(later) {
+		t.Errorf("still scheduled: %d, %v", total, err)
+	}
+}
+
+func TestUpdateIfStatus(t *testing.T) {
+	repos := newRepos(t)
+	author := createUser(t, repos, "author")
+
+	post := createPost(t, repos, author, "Scheduled", nil)
+	due := time.Now().Add(-time.Minute)
+	post.Status = "scheduled"
+	post.PublishAt = &due
+	if updated, err := repos.Posts.UpdateIfStatus(&post, "draft"); err != nil || !updated {
+		t.Fatalf("schedule draft: %v, %v", updated, err)
+	}
+
+	// The scheduler publishes the post while it is being edited
+	if _, err := repos.Posts.PublishDue(time.Now()); err != nil {
+		t.Fatal(err)
+	}
+	post.Title = "Edited"
+	if updated, err := repos.Posts.UpdateIfStatus(&post, "scheduled"); err != nil || updated {
+		t.Fatalf("stale update: %v, %v", updated, err)
+	}
+	if updated, err := repos.Posts.UpdateWithTagsIfStatus(&post, "scheduled", []models.Tag{{Name: "New", Slug: "new"}}); err != nil || updated {
+		t.Fatalf("stale update with tags: %v, %v", updated, err)
+	}
+	if _, err := repos.Tags.FindBySlug("new"); !errors.Is(err, repository.ErrNotFound) {
+		t.Errorf("stale update created its tags: %v", err)
+	}
+
+	saved, err := repos.Posts.FindByID(post.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if saved.Status != "published" || saved.Title != "Scheduled" {
+		t.Errorf("post is %s %q, want the published original", saved.Status, saved.Title)
+	}
+}
+
+func TestCategoriesAndTags(t *testing.T) {
+	repos := newRepos(t)
+	author := createUser(t, repos, "author")
+
+	tech := models.Category{Name: "Tech", Slug: "tech"}
+	if err := repos.Categories.Create(&tech); err != nil {
+		t.Fatal(err)
+	}
+	golang := models.Category{Name: "Go", Slug: "go", ParentID: &tech.ID}
+	if err := repos.Categories.Create(&golang); err != nil {
+		t.Fatal(err)
+	}
+	generics := models.Category{Name: "Generics", Slug: "generics", ParentID: &golang.ID}
+	if err := repos.Categories.Create(&generics); err != nil {
+		t.Fatal(err)
+	}
+
+	descendants, err := repos.Categories.Descendants(tech.ID)
+	if err != nil || len(descendants) != 2 {
+		t.Fatalf("descendants: %v, %v", descendants, err)
+	}
+
+	tree, err := repos.Categories.Tree()
+	if err != nil || len(tree) != 1 || len(tree[0].Children) != 1 || len(tree[0].Children[0].Children) != 1 {
+		t.Fatalf("tree: %+v, %v", tree, err)
+	}
+
+	at := time.Now()
+	post := createPost(t, repos, author, "Tagged", &at)
+	if err := repos.Posts.SetCategories(&post, []uuid.UUID{generics.ID, uuid.New()}); err != nil {
+		t.Fatal(err)
+	}
+	tags, err := repos.Tags.FindOrCreate([]models.Tag{{Name: "Go", Slug: "go"}, {Name: "Testing", Slug: "testing"}})
+	if err != nil || len(tags) != 2 {
+		t.Fatalf("find or create: %v, %v", tags, err)
+	}
+	if err := repos.Posts.SetTags(&post, tags); err != nil {
+		t.Fatal(err)
+	}
+
+	loaded, err := repos.Posts.FindByIDWithAuthor(post.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(loaded.Categories) != 1 || len(loaded.Tags) != 2 {
+		t.Fatalf("loaded %d categories and %d tags", len(loaded.Categories), len(loaded.Tags))
+	}
+	if path := loaded.Categories[0].Path; len(path) != 3 || path[0].ID != tech.ID || path[2].ID != generics.ID {
+		t.Errorf("breadcrumb path %+v", path)
+	}
+
+	posts, total, err := repos.Posts.List(repository.PostFilter{CategoryIDs: append([]uuid.UUID{tech.ID}, descendants...)})
+	if err != nil || total != 1 || posts[0].ID != post.ID {
+		t.Errorf("posts under tech: %d, %v", total, err)
+	}
+
+	// Merging moves the posts over and deleting a category moves its
+	// subcategories up
+	if err := repos.Tags.Merge(&tags[1], &tags[0]); err != nil {
+		t.Fatal(err)
+	}
+	usage, _, err := repos.Tags.List(repository.Page{})
+	if err != nil || len(usage) != 1 || usage[0].PostCount != 1 {
+		t.Errorf("tags after merge: %+v, %v", usage, err)
+	}
+
+	if err := repos.Categories.Delete(&golang); err != nil {
+		t.Fatal(err)
+	}
+	moved, err := repos.Categories.FindByID(generics.ID)
+	if err != nil || moved.ParentID == nil || *moved.ParentID != tech.ID {
+		t.Errorf("subcategory of deleted category: %+v, %v", moved.ParentID, err)
+	}
+}
+
+func TestSearch(t *testing.T) {
+	repos := newRepos(t)
+	author := createUser(t, repos, "author")
+
+	at := time.Now()
+	match := createPost(t, repos, author, "Gardening tips", &at)
+	createPost(t, repos, author, "Cooking", &at)
+	createPost(t, repos, author, "Gardening draft", nil)
+
+	results, total, err := repos.Search.Search(repository.SearchFilter{Query: "gardening", Type: repository.SearchPosts, Limit: 10})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if total != 1 || results[0].ID != match.ID {
+		t.Fatalf("search returned %d results: %+v", total, results)
+	}
+
+	// Snippets are escaped text, whatever the content
+	match.Content = "Gardening <b>tips</b> & <img src=x onerror=alert(1)> 1 < 2"
+	match.ContentFormat = "html"
+	if err := repos.Posts.Update(&match); err != nil {
+		t.Fatal(err)
+	}
+	results, _, err = repos.Search.Search(repository.SearchFilter{Query: "gardening", Type: repository.SearchPosts, Limit: 10})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "<mark>Gardening</mark> tips &amp; 1 &lt; 2"; results[0].Snippet != want {
+		t.Errorf("snippet %q, want %q", results[0].Snippet, want)
+	}
+}
+
+func TestRenderContent(t *testing.T) {
+	conn := dbtest.Open(t)
+	repos := repository.NewGormRepositories(conn)
+	author := createUser(t, repos, "author")
+
+	at := time.Now()
+	post := createPost(t, repos, author, "Cached", &at)
+	comment := models.Comment{Content: "*Nice*", PostID: post.ID, AuthorID: author.ID}
+	if err := repos.Comments.Create(&comment); err != nil {
+		t.Fatal(err)
+	}
+
+	// As left by the migrations that added and cleared the caches
+	if err := conn.Exec("UPDATE posts SET content_html = NULL, excerpt = NULL").Error; err != nil {
+		t.Fatal(err)
+	}
+	if err := conn.Exec("UPDATE comments SET content_html = NULL").Error; err != nil {
+		t.Fatal(err)
+	}
+
+	counts, err := repository.RenderContent(conn)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if counts.Posts != 1 || counts.Comments != 1 {
+		t.Errorf("rendered %+v, want a post and a comment", counts)
+	}
+
+	var cached struct {
+		ContentHTML string
+		Excerpt     string
+	}
+	conn.Raw("SELECT content_html, excerpt FROM posts WHERE id = ?", post.ID).Scan(&cached)
+	if cached.ContentHTML != "<p>Cached content</p>\n" || cached.Excerpt != "Cached content" {
+		t.Errorf("post cache %+v", cached)
+	}
+	conn.Raw("SELECT content_html FROM comments WHERE id = ?", comment.ID).Scan(&cached.ContentHTML)
+	if cached.ContentHTML != "<p><em>Nice</em></p>\n" {
+		t.Errorf("comment cache %q", cached.ContentHTML)
+	}
+
+	if counts, _ := repository.RenderContent(conn); counts.Posts != 0 || counts.Comments != 0 {
+		t.Errorf("rendered %+v again", counts)
+	}
+}
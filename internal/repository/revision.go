@@ -0,0 +1,51 @@
+package repository
+
+import (
+	"github.com/google/uuid"
+	"github.com/terkoizmy/go-blog-api/internal/models"
+	"gorm.io/gorm"
+)
+
+type gormPostRevisionRepository struct {
+	db *gorm.DB
+}
+
+// NewPostRevisionRepository returns a PostRevisionRepository backed by GORM
+func NewPostRevisionRepository(db *gorm.DB) PostRevisionRepository {
+	return &gormPostRevisionRepository{db: db}
+}
+
+func (r *gormPostRevisionRepository) Create(revision *models.PostRevision) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		var latest int
+		err := tx.Model(&models.PostRevision{}).
+			Where("post_id = ?", revision.PostID).
+			Select("COALESCE(MAX(number), 0)").
+			Scan(&latest).Error
+		if err != nil {
+			return err
+		}
+
+		revision.Number = latest + 1
+		return tx.Create(revision).Error
+	})
+}
+
+func (r *gormPostRevisionRepository) Latest(postID uuid.UUID) (models.PostRevision, error) {
+	var revision models.PostRevision
+	err := first(r.db.Preload("Author").Where("post_id = ?", postID).Order("number DESC"), &revision)
+	return revision, err
+}
+
+func (r *gormPostRevisionRepository) FindByNumber(postID uuid.UUID, number int) (models.PostRevision, error) {
+	var revision models.PostRevision
+	err := first(r.db.Preload("Author").Where("post_id = ? AND number = ?", postID, number), &revision)
+	return revision, err
+}
+
+func (r *gormPostRevisionRepository) ListByPost(postID uuid.UUID, page Page) ([]models.PostRevision, int64, error) {
+	var revisions []models.PostRevision
+	query := r.db.Preload("Author").Where("post_id = ?", postID)
+	total, err := paginate(query, &models.PostRevision{}, page, "number DESC", &revisions)
+	return revisions, total, err
+}
@@ -0,0 +1,416 @@
+package repository
+
+import (
+	"html"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/google/uuid"
+	"github.com/terkoizmy/go-blog-api/internal/markup"
+	"github.com/terkoizmy/go-blog-api/internal/models"
+	"gorm.io/gorm"
+)
+
+// Search result types
+const (
+	SearchPosts    = "posts"
+	SearchComments = "comments"
+)
+
+// SearchFilter describes a search. Zero values mean no restriction.
+type SearchFilter struct {
+	Query string
+	// Type is SearchPosts, SearchComments or empty for both
+	Type       string
+	CategoryID uuid.UUID
+	AuthorID   uuid.UUID
+	// From is inclusive and To exclusive. Posts are matched on their
+	// publication date, comments on their creation date.
+	From   *time.Time
+	To     *time.Time
+	Offset int
+	Limit  int
+}
+
+type SearchRepository interface {
+	// Search returns one page of matches, best first, and the total number
+	// of matches
+	Search(filter SearchFilter) ([]models.SearchResult, int64, error)
+}
+
+// NewSearchRepository returns a SearchRepository that uses full-text search
+// on Postgres and falls back to substring matching on other databases
+func NewSearchRepository(db *gorm.DB) SearchRepository {
+	if db.Dialector.Name() == "postgres" {
+		return &postgresSearchRepository{db: db}
+	}
+	return &likeSearchRepository{db: db}
+}
+
+// searchConditions restricts matches to published posts and applies the
+// filters. authorColumn and dateColumn are those of the matched record.
+func searchConditions(filter SearchFilter, authorColumn, dateColumn string) (string, []interface{}) {
+	conditions := []string{"p.deleted_at IS NULL", "p.status = ?"}
+	args := []interface{}{"published"}
+
+	if filter.CategoryID != uuid.Nil {
+		conditions = append(conditions, "EXISTS (SELECT 1 FROM post_categories pc WHERE pc.post_id = p.id AND pc.category_id = ?)")
+		args = append(args, filter.CategoryID)
+	}
+	if filter.AuthorID != uuid.Nil {
+		conditions = append(conditions, authorColumn+" = ?")
+		args = append(args, filter.AuthorID)
+	}
+	if filter.From != nil {
+		conditions = append(conditions, dateColumn+" >= ?")
+		args = append(args, *filter.From)
+	}
+	if filter.To != nil {
+		conditions = append(conditions, dateColumn+" < ?")
+		args = append(args, *filter.To)
+	}
+
+	return strings.Join(conditions, " AND "), args
+}
+
+type postgresSearchRepository struct {
+	db *gorm.DB
+}
+
+// Snippets are built from the text of the content and only marked up after
+// it has been escaped. ts_headline surrounds matches with these private use
+// characters, which are removed from the text beforehand, and they are then
+// swapped for <mark> tags.
+const (
+	markStart = "\uE000"
+	markStop  = "\uE001"
+)
+
+// searchHeadlineOptions configures the snippets built by ts_headline
+const searchHeadlineOptions = `StartSel="` + markStart + `", StopSel="` + markStop + `", MaxWords=35, MinWords=15, MaxFragments=2, FragmentDelimiter=" … "`
+
+// Matches use the generated search_vector columns and their GIN indexes.
+// Snippets are only built for the requested page.
+func (r *postgresSearchRepository) Search(filter SearchFilter) ([]models.SearchResult, int64, error) {
+	var selects []string
+	var args []interface{}
+
+	if filter.Type != SearchComments {
+		where, whereArgs := searchConditions(filter, "p.author_id", "COALESCE(p.published_at, p.created_at)")
+		selects = append(selects, `SELECT 'post' AS type, p.id, p.id AS post_id, p.title, p.slug,
+			p.content AS body, p.content_format AS body_format, p.content_html AS body_html, p.author_id, u.username AS author,
+			ts_rank(p.search_vector, websearch_to_tsquery('english', ?)) AS rank,
+			COALESCE(p.published_at, p.created_at) AS created_at
+			FROM posts p JOIN users u ON u.id = p.author_id
+			WHERE p.search_vector @@ websearch_to_tsquery('english', ?) AND `+where)
+		args = append(args, filter.Query, filter.Query)
+		args = append(args, whereArgs...)
+	}
+
+	if filter.Type != SearchPosts {
+		where, whereArgs := searchConditions(filter, "c.author_id", "c.created_at")
+		selects = append(selects, `SELECT 'comment' AS type, c.id, p.id AS post_id, p.title, p.slug,
+			c.content AS body, 'markdown' AS body_format, c.content_html AS body_html, c.author_id, u.username AS author,
+			ts_rank(c.search_vector, websearch_to_tsquery('english', ?)) AS rank,
+			c.created_at
+			FROM comments c JOIN posts p ON p.id = c.post_id JOIN users u ON u.id = c.author_id
+			WHERE c.search_vector @@ websearch_to_tsquery('english', ?) AND c.deleted_at IS NULL AND `+where)
+		args = append(args, filter.Query, filter.Query)
+		args = append(args, whereArgs...)
+	}
+
+	matches := "(" + strings.Join(selects, " UNION ALL ") + ") AS matches"
+
+	var total int64
+	if err := r.db.Raw("SELECT COUNT(*) FROM "+matches, args...).Scan(&total).Error; err != nil {
+		return nil, 0, err
+	}
+	if total == 0 {
+		return []models.SearchResult{}, 0, nil
+	}
+
+	pageArgs := append(args, searchLimit(filter.Limit), filter.Offset)
+
+	var rows []rankedSearchRow
+	err := r.db.Raw("SELECT * FROM "+matches+" ORDER BY rank DESC, created_at DESC LIMIT ? OFFSET ?", pageArgs...).
+		Scan(&rows).Error
+	if err != nil {
+		return nil, 0, err
+	}
+
+	texts := make([]string, len(rows))
+	for i, row := range rows {
+		texts[i] = row.text()
+	}
+	snippets, err := r.headlines(filter.Query, texts)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	results := make([]models.SearchResult, len(rows))
+	for i, row := range rows {
+		results[i] = row.result(row.Type, markSnippet(snippets[i]), row.Rank, row.CreatedAt)
+	}
+	return results, total, nil
+}
+
+// rankedSearchRow is a match ranked by Postgres
+type rankedSearchRow struct {
+	searchRow
+	Type string
+	Rank float64
+}
+
+// headlines builds a snippet of each text in a single query
+func (r *postgresSearchRepository) headlines(query string, texts []string) ([]string, error) {
+	if len(texts) == 0 {
+		return nil, nil
+	}
+
+	values := make([]string, len(texts))
+	args := []interface{}{query, searchHeadlineOptions}
+	for i, text := range texts {
+		values[i] = "(?::int, ?::text)"
+		args = append(args, i, text)
+	}
+
+	var snippets []string
+	err := r.db.Raw(`SELECT ts_headline('english', t.body, websearch_to_tsquery('english', ?), ?)
+		FROM (VALUES `+strings.Join(values, ", ")+`) AS t(position, body)
+		ORDER BY t.position`, args...).Scan(&snippets).Error
+	return snippets, err
+}
+
+// likeSearchRepository matches every search term as a case-insensitive
+// substring and ranks in Go. It reads every match, so it is meant for small
+// databases such as local development and tests.
+type likeSearchRepository struct {
+	db *gorm.DB
+}
+
+// maxSearchTerms bounds the number of LIKE conditions of a query
+const maxSearchTerms = 10
+
+// minSearchTermLength is the shortest term worth matching
+const minSearchTermLength = 2
+
+// snippetLength is roughly how many bytes of context a snippet shows
+const snippetLength = 200
+
+type searchRow struct {
+	ID          uuid.UUID
+	PostID      uuid.UUID
+	Title       string
+	Slug        string
+	Body        string
+	BodyFormat  string
+	BodyHTML    string
+	AuthorID    uuid.UUID
+	Author      string
+	PublishedAt *time.Time
+	CreatedAt   time.Time
+}
+
+// text returns the text of the rendered body on a single line, without the
+// characters that mark matches
+func (row searchRow) text() string {
+	body := row.BodyHTML
+	if body == "" {
+		body = markup.Render(row.BodyFormat, row.Body)
+	}
+	text := strings.NewReplacer(markStart, "", markStop, "").Replace(markup.Text(body))
+	return strings.Join(strings.Fields(text), " ")
+}
+
+func (r *likeSearchRepository) Search(filter SearchFilter) ([]models.SearchResult, int64, error) {
+	terms := searchTerms(filter.Query)
+	if len(terms) == 0 {
+		return []models.SearchResult{}, 0, nil
+	}
+
+	quoted := make([]string, len(terms))
+	for i, term := range terms {
+		quoted[i] = regexp.QuoteMeta(term)
+	}
+	pattern := regexp.MustCompile("(?i)" + strings.Join(quoted, "|"))
+
+	results := []models.SearchResult{}
+
+	if filter.Type != SearchComments {
+		where, whereArgs := searchConditions(filter, "p.author_id", "COALESCE(p.published_at, p.created_at)")
+		query := r.db.Table("posts p").
+			Select("p.id, p.id AS post_id, p.title, p.slug, p.content AS body, p.content_format AS body_format, p.content_html AS body_html, p.author_id, u.username AS author, p.published_at, p.created_at").
+			Joins("JOIN users u ON u.id = p.author_id").
+			Where(where, whereArgs...)
+		for _, term := range terms {
+			like := likePattern(term)
+			query = query.Where("(LOWER(p.title) LIKE ? ESCAPE '\\' OR LOWER(p.content) LIKE ? ESCAPE '\\')", like, like)
+		}
+
+		var rows []searchRow
+		if err := query.Scan(&rows).Error; err != nil {
+			return nil, 0, err
+		}
+		for _, row := range rows {
+			text := row.text()
+			// Title matches count double, like the weights used on Postgres
+			rank := 2*len(pattern.FindAllStringIndex(row.Title, -1)) + len(pattern.FindAllStringIndex(text, -1))
+			createdAt := row.CreatedAt
+			if row.PublishedAt != nil {
+				createdAt = *row.PublishedAt
+			}
+			results = append(results, row.result("post", highlightSnippet(text, pattern), float64(rank), createdAt))
+		}
+	}
+
+	if filter.Type != SearchPosts {
+		where, whereArgs := searchConditions(filter, "c.author_id", "c.created_at")
+		query := r.db.Table("comments c").
+			Select("c.id, p.id AS post_id, p.title, p.slug, c.content AS body, 'markdown' AS body_format, c.content_html AS body_html, c.author_id, u.username AS author, c.created_at").
+			Joins("JOIN posts p ON p.id = c.post_id").
+			Joins("JOIN users u ON u.id = c.author_id").
+			Where("c.deleted_at IS NULL").
+			Where(where, whereArgs...)
+		for _, term := range terms {
+			query = query.Where("LOWER(c.content) LIKE ? ESCAPE '\\'", likePattern(term))
+		}
+
+		var rows []searchRow
+		if err := query.Scan(&rows).Error; err != nil {
+			return nil, 0, err
+		}
+		for _, row := range rows {
+			text := row.text()
+			rank := len(pattern.FindAllStringIndex(text, -1))
+			results = append(results, row.result("comment", highlightSnippet(text, pattern), float64(rank), row.CreatedAt))
+		}
+	}
+
+	sort.SliceStable(results, func(i, j int) bool {
+		if results[i].Rank != results[j].Rank {
+			return results[i].Rank > results[j].Rank
+		}
+		return results[i].CreatedAt.After(results[j].CreatedAt)
+	})
+
+	total := int64(len(results))
+	start := filter.Offset
+	if start > len(results) {
+		start = len(results)
+	}
+	end := start + searchLimit(filter.Limit)
+	if end > len(results) {
+		end = len(results)
+	}
+
+	return results[start:end], total, nil
+}
+
+func (row searchRow) result(resultType, snippet string, rank float64, createdAt time.Time) models.SearchResult {
+	return models.SearchResult{
+		Type:      resultType,
+		ID:        row.ID,
+		PostID:    row.PostID,
+		Title:     row.Title,
+		Slug:      row.Slug,
+		Snippet:   snippet,
+		AuthorID:  row.AuthorID,
+		Author:    row.Author,
+		Rank:      rank,
+		CreatedAt: createdAt,
+	}
+}
+
+// searchTerms splits a query into distinct lowercase words, skipping single
+// letters
+func searchTerms(query string) []string {
+	words := strings.FieldsFunc(strings.ToLower(query), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+
+	seen := make(map[string]bool, len(words))
+	var terms []string
+	for _, word := range words {
+		// Single letters match nearly everything
+		if seen[word] || utf8.RuneCountInString(word) < minSearchTermLength {
+			continue
+		}
+		seen[word] = true
+		terms = append(terms, word)
+		if len(terms) == maxSearchTerms {
+			break
+		}
+	}
+	return terms
+}
+
+// likePattern matches term anywhere, escaping LIKE wildcards
+func likePattern(term string) string {
+	escaped := strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`).Replace(term)
+	return "%" + escaped + "%"
+}
+
+// highlightSnippet cuts the text around the first match, escapes it and
+// marks every match in it
+func highlightSnippet(text string, pattern *regexp.Regexp) string {
+	start, end := 0, len(text)
+	if end > snippetLength {
+		if loc := pattern.FindStringIndex(text); loc != nil && loc[0] > snippetLength/3 {
+			start = loc[0] - snippetLength/3
+		}
+		if start+snippetLength < end {
+			end = start + snippetLength
+		}
+
+		// Don't cut words or characters in half
+		if start > 0 {
+			if i := strings.IndexByte(text[start:], ' '); i >= 0 && start+i < end {
+				start += i + 1
+			}
+			for start < end && !utf8.RuneStart(text[start]) {
+				start++
+			}
+		}
+		if end < len(text) {
+			if i := strings.LastIndexByte(text[start:end], ' '); i > 0 {
+				end = start + i
+			}
+			for end > start && !utf8.RuneStart(text[end]) {
+				end--
+			}
+		}
+	}
+
+	var b strings.Builder
+	if start > 0 {
+		b.WriteString("… ")
+	}
+	last := start
+	for _, loc := range pattern.FindAllStringIndex(text[start:end], -1) {
+		b.WriteString(html.EscapeString(text[last : start+loc[0]]))
+		b.WriteString("<mark>" + html.EscapeString(text[start+loc[0]:start+loc[1]]) + "</mark>")
+		last = start + loc[1]
+	}
+	b.WriteString(html.EscapeString(text[last:end]))
+	if end < len(text) {
+		b.WriteString(" …")
+	}
+	return b.String()
+}
+
+// markSnippet escapes a snippet built by ts_headline and marks its matches
+func markSnippet(snippet string) string {
+	return strings.NewReplacer(markStart, "<mark>", markStop, "</mark>").Replace(html.EscapeString(snippet))
+}
+
+// searchLimit applies the default page size
+func searchLimit(limit int) int {
+	if limit <= 0 {
+		return 10
+	}
+	return limit
+}
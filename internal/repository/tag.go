@@ -0,0 +1,85 @@
+package repository
+
+import (
+	"github.com/google/uuid"
+	"github.com/terkoizmy/go-blog-api/internal/models"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// tagPostCount counts the published posts a tag is on
+const tagPostCount = "(SELECT COUNT(*) FROM post_tags JOIN posts ON posts.id = post_tags.post_id" +
+	" WHERE post_tags.tag_id = tags.id AND posts.status = 'published' AND posts.deleted_at IS NULL)"
+
+type gormTagRepository struct {
+	db *gorm.DB
+}
+
+// NewTagRepository returns a TagRepository backed by GORM
+func NewTagRepository(db *gorm.DB) TagRepository {
+	return &gormTagRepository{db: db}
+}
+
+func (r *gormTagRepository) FindBySlug(slug string) (models.Tag, error) {
+	var tag models.Tag
+	err := first(r.db.Where("slug = ?", slug), &tag)
+	return tag, err
+}
+
+func (r *gormTagRepository) SlugExists(slug string, excludeID uuid.UUID) (bool, error) {
+	return exists(r.db.Where("slug = ? AND id != ?", slug, excludeID), &models.Tag{})
+}
+
+func (r *gormTagRepository) List(page Page) ([]models.TagUsage, int64, error) {
+	var tags []models.TagUsage
+	query := r.db.Model(&models.Tag{}).Select("tags.*, " + tagPostCount + " AS post_count")
+	total, err := paginate(query, &models.Tag{}, page, "post_count DESC, tags.name, tags.id", &tags)
+	return tags, total, err
+}
+
+func (r *gormTagRepository) FindOrCreate(tags []models.Tag) ([]models.Tag, error) {
+	return findOrCreateTags(r.db, tags)
+}
+
+// findOrCreateTags is FindOrCreate on tx, so posts can create their tags in
+// the same transaction as themselves
+func findOrCreateTags(tx *gorm.DB, tags []models.Tag) ([]models.Tag, error) {
+	if len(tags) == 0 {
+		return nil, nil
+	}
+
+	// Tags created meanwhile by someone else are kept
+	err := tx.Clauses(clause.OnConflict{Columns: []clause.Column{{Name: "slug"}}, DoNothing: true}).
+		Create(&tags).Error
+	if err != nil {
+		return nil, err
+	}
+
+	slugs := make([]string, len(tags))
+	for i, tag := range tags {
+		slugs[i] = tag.Slug
+	}
+	var found []models.Tag
+	err = tx.Where("slug IN ?", slugs).Order("name").Find(&found).Error
+	return found, err
+}
+
+func (r *gormTagRepository) Update(tag *models.Tag) error {
+	return r.db.Save(tag).Error
+}
+
+func (r *gormTagRepository) Merge(from, into *models.Tag) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		err := tx.Exec("INSERT INTO post_tags (tag_id, post_id) SELECT ?, post_id FROM post_tags"+
+			" WHERE tag_id = ? AND post_id NOT IN (SELECT post_id FROM post_tags WHERE tag_id = ?)",
+			into.ID, from.ID, into.ID).Error
+		if err != nil {
+			return err
+		}
+		if err := tx.Table("post_tags").Where("tag_id = ?", from.ID).Delete(nil).Error; err != nil {
+			return err
+		}
+		// Deleted for good so its slug can be used again
+		return tx.Unscoped().Delete(from).Error
+	})
+}
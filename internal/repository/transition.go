@@ -0,0 +1,27 @@
+package repository
+
+import (
+	"github.com/google/uuid"
+	"github.com/terkoizmy/go-blog-api/internal/models"
+	"gorm.io/gorm"
+)
+
+type gormPostTransitionRepository struct {
+	db *gorm.DB
+}
+
+// NewPostTransitionRepository returns a PostTransitionRepository backed by GORM
+func NewPostTransitionRepository(db *gorm.DB) PostTransitionRepository {
+	return &gormPostTransitionRepository{db: db}
+}
+
+func (r *gormPostTransitionRepository) Create(transition *models.PostTransition) error {
+	return r.db.Create(transition).Error
+}
+
+func (r *gormPostTransitionRepository) ListByPost(postID uuid.UUID, page Page) ([]models.PostTransition, int64, error) {
+	var transitions []models.PostTransition
+	query := r.db.Preload("User").Where("post_id = ?", postID)
+	total, err := paginate(query, &models.PostTransition{}, page, "created_at DESC, id DESC", &transitions)
+	return transitions, total, err
+}
@@ -0,0 +1,60 @@
+package repository
+
+import (
+	"github.com/google/uuid"
+	"github.com/terkoizmy/go-blog-api/internal/models"
+	"gorm.io/gorm"
+)
+
+type gormUserRepository struct {
+	db *gorm.DB
+}
+
+// NewUserRepository returns a UserRepository backed by GORM
+func NewUserRepository(db *gorm.DB) UserRepository {
+	return &gormUserRepository{db: db}
+}
+
+func (r *gormUserRepository) FindByID(id uuid.UUID) (models.User, error) {
+	var user models.User
+	err := first(r.db.Where("id = ?", id), &user)
+	return user, err
+}
+
+func (r *gormUserRepository) FindByUsername(username string) (models.User, error) {
+	var user models.User
+	err := first(r.db.Where("username = ?", username), &user)
+	return user, err
+}
+
+func (r *gormUserRepository) FindByEmail(email string) (models.User, error) {
+	var user models.User
+	err := first(r.db.Where("email = ?", email), &user)
+	return user, err
+}
+
+func (r *gormUserRepository) UsernameExists(username string, excludeID uuid.UUID) (bool, error) {
+	return exists(r.db.Where("username = ? AND id != ?", username, excludeID), &models.User{})
+}
+
+func (r *gormUserRepository) EmailExists(email string, excludeID uuid.UUID) (bool, error) {
+	return exists(r.db.Where("email = ? AND id != ?", email, excludeID), &models.User{})
+}
+
+func (r *gormUserRepository) List(page Page) ([]models.User, int64, error) {
+	var users []models.User
+	total, err := paginate(r.db, &models.User{}, page, "created_at", &users)
+	return users, total, err
+}
+
+func (r *gormUserRepository) Create(user *models.User) error {
+	return r.db.Create(user).Error
+}
+
+func (r *gormUserRepository) Update(user *models.User) error {
+	return r.db.Save(user).Error
+}
+
+func (r *gormUserRepository) Delete(user *models.User) error {
+	return r.db.Delete(user).Error
+}
@@ -0,0 +1,41 @@
+// Package scheduler publishes scheduled posts once they are due.
+package scheduler
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"github.com/terkoizmy/go-blog-api/internal/repository"
+)
+
+// Start publishes due posts right away and then every interval until ctx is
+// done. Several servers can run it against the same database.
+func Start(ctx context.Context, posts repository.PostRepository, interval time.Duration) {
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for {
+			PublishDue(posts)
+
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+}
+
+// PublishDue publishes the posts that are due now, logging the outcome
+func PublishDue(posts repository.PostRepository) {
+	published, err := posts.PublishDue(time.Now())
+	if err != nil {
+		log.Printf("Failed to publish scheduled posts: %v", err)
+		return
+	}
+	if published > 0 {
+		log.Printf("Published %d scheduled post(s)", published)
+	}
+}
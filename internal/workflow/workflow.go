@@ -0,0 +1,99 @@
+// Package workflow defines the statuses a post goes through and which moves
+// between them are allowed.
+package workflow
+
+import (
+	"errors"
+	"fmt"
+)
+
+// Post statuses
+const (
+	Draft     = "draft"
+	InReview  = "in_review"
+	Scheduled = "scheduled"
+	Published = "published"
+	Archived  = "archived"
+)
+
+var (
+	ErrInvalidStatus     = errors.New("status must be draft, in_review, scheduled, published or archived")
+	ErrInvalidTransition = errors.New("status change not allowed")
+)
+
+// Requirement is who may make a transition
+type Requirement int
+
+const (
+	// RequireEdit allows the author and anyone who can edit any post
+	RequireEdit Requirement = iota
+	// RequirePublish also needs the permission to publish
+	RequirePublish
+	// RequireReview allows reviewers
+	RequireReview
+	// RequireAuthorOrReview allows the author, withdrawing a post from
+	// review, and reviewers, sending it back
+	RequireAuthorOrReview
+)
+
+// transitions maps each status to the ones a post can move to from it
+var transitions = map[string]map[string]Requirement{
+	Draft: {
+		InReview:  RequireEdit,
+		Scheduled: RequirePublish,
+		Published: RequirePublish,
+		Archived:  RequireEdit,
+	},
+	InReview: {
+		Draft:     RequireAuthorOrReview,
+		Scheduled: RequireReview,
+		Published: RequireReview,
+	},
+	Scheduled: {
+		Draft: RequireEdit,
+		// Rescheduling
+		Scheduled: RequirePublish,
+		Published: RequirePublish,
+	},
+	Published: {
+		Draft:    RequirePublish,
+		Archived: RequireEdit,
+	},
+	Archived: {
+		Draft:     RequireEdit,
+		Published: RequirePublish,
+	},
+}
+
+// IsValid reports whether status is one of the post statuses
+func IsValid(status string) bool {
+	_, ok := transitions[status]
+	return ok
+}
+
+// Check returns who may move a post from one status to another. New posts
+// come from the empty status and may start out as anything but archived.
+// Unknown statuses, left from before they were checked, count as drafts.
+func Check(from, to string) (Requirement, error) {
+	if !IsValid(to) {
+		return 0, ErrInvalidStatus
+	}
+
+	if from == "" {
+		if to == Draft {
+			return RequireEdit, nil
+		}
+		if to == Archived {
+			return 0, fmt.Errorf("%w: new posts can't be archived", ErrInvalidTransition)
+		}
+	}
+	if !IsValid(from) {
+		from = Draft
+	}
+
+	requirement, ok := transitions[from][to]
+	if !ok {
+		return 0, fmt.Errorf("%w: %s to %s", ErrInvalidTransition, from, to)
+	}
+	return requirement, nil
+}